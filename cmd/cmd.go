@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/vflame6/sharefinder/logger"
 	"github.com/vflame6/sharefinder/scanner"
 	"github.com/vflame6/sharefinder/utils"
@@ -15,7 +16,7 @@ import (
 	"time"
 )
 
-func CreateScanner(version string, commandLine []string, timeStart time.Time, outputRaw, outputXML, outputAll string, outputHTML bool, threads int, timeout time.Duration, exclude string, list, recurse bool, smbPort int, proxyStr string) (*scanner.Scanner, error) {
+func CreateScanner(version string, commandLine []string, timeStart time.Time, outputRaw, outputXML, outputAll string, outputHTML bool, threads int, timeout time.Duration, exclude string, list, recurse, fingerprint, pipes, adminAudit, bruteforceShares bool, shareWordlist, lifecycleTable string, smbPort int, proxyStr string, netbiosFallback, sweep, sweepOnly bool, sweepThreads int, sweepTimeout time.Duration) (*scanner.Scanner, error) {
 	var outputRawFileName string
 	var outputXMLFileName string
 	var outputHTMLFileName string
@@ -41,6 +42,23 @@ func CreateScanner(version string, commandLine []string, timeStart time.Time, ou
 		return nil, errors.New("cannot use --recurse without --list")
 	}
 
+	if sweepThreads < 1 {
+		return nil, errors.New("--sweep-threads must be a positive number")
+	}
+	if sweepTimeout <= 0 {
+		return nil, errors.New("--sweep-timeout must be a positive duration")
+	}
+
+	// the built-in share names are tried if srvsvc is denied, even without --bruteforce-shares
+	shareNames, err := scanner.ShareWordlist(shareWordlist)
+	if err != nil {
+		return nil, fmt.Errorf("share wordlist %s: %w", shareWordlist, err)
+	}
+	lifecycle, err := scanner.LoadLifecycleTable(lifecycleTable)
+	if err != nil {
+		return nil, fmt.Errorf("lifecycle table %s: %w", lifecycleTable, err)
+	}
+
 	outputWriter = scanner.NewOutputWriter()
 	if outputRaw != "" {
 		// trim suffix of output filename if it matches with txt/xml/html
@@ -122,12 +140,16 @@ func CreateScanner(version string, commandLine []string, timeStart time.Time, ou
 	// scanner options are created without credentials just to specify global flags
 	// the credentials will be specified on execution of authenticated modules
 	options := &scanner.Options{
+		AdminAudit:         adminAudit,
+		BruteforceShares:   bruteforceShares,
 		DCHostname:         "",
 		Domain:             "",
 		DomainController:   net.IPv4zero,
 		Exclude:            excludeList,
 		FileTXT:            file,
 		FileXML:            fileXML,
+		Fingerprint:        fingerprint,
+		Lifecycle:          lifecycle,
 		Hash:               "",
 		HashBytes:          []byte{},
 		Kerberos:           false,
@@ -138,10 +160,17 @@ func CreateScanner(version string, commandLine []string, timeStart time.Time, ou
 		OutputHTMLFileName: outputHTMLFileName,
 		OutputRawFileName:  outputRawFileName,
 		OutputXMLFileName:  outputXMLFileName,
+		NetBIOSFallback:    netbiosFallback,
 		Password:           "",
+		Pipes:              pipes,
 		ProxyDialer:        proxyDialer,
 		Recurse:            recurse,
+		ShareWordlist:      shareNames,
 		SmbPort:            smbPort,
+		Sweep:              sweep || sweepOnly,
+		SweepOnly:          sweepOnly,
+		SweepThreads:       sweepThreads,
+		SweepTimeout:       sweepTimeout,
 		Target:             make(chan scanner.DNHost, 256),
 		Timeout:            timeout,
 		Username:           "",
@@ -153,11 +182,14 @@ func CreateScanner(version string, commandLine []string, timeStart time.Time, ou
 	return s, nil
 }
 
-func ExecuteNull(s *scanner.Scanner, target string) error {
+func ExecuteNull(s *scanner.Scanner, target string, accounts bool, ridRange string) error {
 	logger.Warn("Executing null module")
 
 	// configure options to use null session
 	s.Options.NullSession = true
+	if err := setAccountOptions(s, accounts, ridRange); err != nil {
+		return err
+	}
 
 	var wg sync.WaitGroup
 	s.RunSMBEnumeration(&wg)
@@ -174,7 +206,28 @@ func ExecuteNull(s *scanner.Scanner, target string) error {
 	return nil
 }
 
-func ExecuteGuest(s *scanner.Scanner, target, username string) error {
+func ExecuteInfo(s *scanner.Scanner, target string) error {
+	logger.Warn("Executing info module")
+
+	// only negotiate and read the NTLM challenge of targets
+	s.Options.Info = true
+
+	var wg sync.WaitGroup
+	s.RunSMBEnumeration(&wg)
+	err := s.ParseTargets(target)
+	if err != nil {
+		return err
+	}
+	wg.Wait()
+
+	// finish the execution
+	s.TimeEnd = time.Now()
+	logger.Warnf("Finished executing info module at %s", s.TimeEnd.Format("02/01/2006 15:04:05"))
+	s.CloseOutputter()
+	return nil
+}
+
+func ExecuteGuest(s *scanner.Scanner, target, username string, accounts bool, ridRange string) error {
 	logger.Warn("Executing guest module")
 
 	if username != "" {
@@ -185,6 +238,10 @@ func ExecuteGuest(s *scanner.Scanner, target, username string) error {
 		s.Options.Username = "anonymous_" + utils.RandSeq(8)
 	}
 	logger.Warnf("Using username for Guest access: %s", s.Options.Username)
+	s.Options.Guest = true
+	if err := setAccountOptions(s, accounts, ridRange); err != nil {
+		return err
+	}
 
 	var wg sync.WaitGroup
 	s.RunSMBEnumeration(&wg)
@@ -201,7 +258,7 @@ func ExecuteGuest(s *scanner.Scanner, target, username string) error {
 	return nil
 }
 
-func ExecuteAuth(s *scanner.Scanner, target, username, password, hash string, localAuth, kerberos bool, dcHostname string, dcIP net.IP) error {
+func ExecuteAuth(s *scanner.Scanner, target, username, password, hash, credentials string, maxAttempts int, lockoutCheck, localAuth, kerberos bool, aesKey, keytab, ccache, krb5Config string, dcHostname string, dcIP net.IP, compareUser, comparePassword, compareHash string) error {
 	var targetDomain string
 	var targetUsername string
 	var err error
@@ -214,8 +271,12 @@ func ExecuteAuth(s *scanner.Scanner, target, username, password, hash string, lo
 		return errors.New("--password can't be used with --hashes")
 	}
 
+	if username == "" && credentials == "" {
+		return errors.New("--username or --credentials is required")
+	}
+
 	// check for local authentication option to parse username correctly
-	if localAuth {
+	if localAuth || username == "" {
 		targetDomain = ""
 		targetUsername = username
 	} else {
@@ -241,6 +302,21 @@ func ExecuteAuth(s *scanner.Scanner, target, username, password, hash string, lo
 		return errors.New("--kerberos can't be used without --dc-hostname")
 	}
 
+	err = setKerberosCredentials(s, kerberos, password, hash, aesKey, keytab, ccache, krb5Config)
+	if err != nil {
+		return err
+	}
+
+	err = setCredentials(s, credentials, maxAttempts, targetDomain, localAuth, kerberos)
+	if err != nil {
+		return err
+	}
+
+	err = setCompareCredential(s, compareUser, comparePassword, compareHash, maxAttempts, targetDomain, targetUsername, localAuth, credentials != "")
+	if err != nil {
+		return err
+	}
+
 	s.Options.Username = targetUsername
 	s.Options.Password = password
 	s.Options.Hash = hash
@@ -253,6 +329,22 @@ func ExecuteAuth(s *scanner.Scanner, target, username, password, hash string, lo
 		s.Options.DomainController = dcIP
 	}
 
+	s.Options.LockoutCheck = lockoutCheck
+	if lockoutCheck {
+		// per-account failures of --credentials are capped by the tracker instead
+		if credentials == "" {
+			s.Options.LockoutGuard = scanner.NewLockoutGuard()
+		}
+		if !localAuth && dcIP != nil {
+			err = s.CheckLockoutPolicy()
+			if err != nil {
+				return err
+			}
+		} else {
+			warnLockoutPolicyNotRead(localAuth, credentials != "")
+		}
+	}
+
 	var wg sync.WaitGroup
 
 	// run the enumeration threads
@@ -266,12 +358,109 @@ func ExecuteAuth(s *scanner.Scanner, target, username, password, hash string, lo
 
 	// finish the execution
 	s.TimeEnd = time.Now()
+	if err = s.Options.LockoutGuard.Err(); err != nil {
+		s.CloseOutputter()
+		return err
+	}
 	logger.Warnf("Finished executing auth module at %s", s.TimeEnd.Format("02/01/2006 15:04:05"))
 	s.CloseOutputter()
 	return nil
 }
 
-func ExecuteHunt(s *scanner.Scanner, username, password, hash string, dc, resolver net.IP, forest, kerberos bool, dcHostname string) error {
+func ExecuteMatrix(s *scanner.Scanner, target, guestUsername, username, password, hash, credentials string, maxAttempts int, lockoutCheck, localAuth bool, dcIP net.IP) error {
+	var targetDomain string
+	var targetUsername string
+	var err error
+
+	logger.Warn("Executing matrix module")
+
+	// if both password and hashes are empty, will use blank password to authenticate
+	// check if both password and hash are provided
+	if password != "" && hash != "" {
+		return errors.New("--password can't be used with --hashes")
+	}
+
+	// check for local authentication option to parse username correctly
+	if localAuth || username == "" {
+		targetDomain = ""
+		targetUsername = username
+	} else {
+		trySplit := strings.Split(username, "\\")
+		if len(trySplit) != 2 {
+			return errors.New("invalid username. Try DOMAIN\\username")
+		}
+		targetDomain = trySplit[0]
+		targetUsername = trySplit[1]
+	}
+
+	// try to decode hash
+	var hashBytes []byte
+	if hash != "" {
+		hashBytes, err = hex.DecodeString(hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setCredentials(s, credentials, maxAttempts, targetDomain, localAuth, false)
+	if err != nil {
+		return err
+	}
+
+	if guestUsername == "" {
+		// generate a random username for Guest access check
+		guestUsername = "anonymous_" + utils.RandSeq(8)
+	}
+	logger.Warnf("Using username for Guest access: %s", guestUsername)
+
+	s.Options.Matrix = true
+	s.Options.GuestUsername = guestUsername
+	s.Options.Username = targetUsername
+	s.Options.Password = password
+	s.Options.Hash = hash
+	s.Options.HashBytes = hashBytes
+	s.Options.Domain = targetDomain
+	s.Options.LocalAuth = localAuth
+	if dcIP != nil {
+		s.Options.DomainController = dcIP
+	}
+
+	s.Options.LockoutCheck = lockoutCheck
+	// null and guest sessions don't count towards the account lockout
+	if lockoutCheck && (targetUsername != "" || credentials != "") {
+		if targetUsername != "" {
+			s.Options.LockoutGuard = scanner.NewLockoutGuard()
+		}
+		if !localAuth && dcIP != nil {
+			err = s.CheckLockoutPolicy()
+			if err != nil {
+				return err
+			}
+		} else {
+			warnLockoutPolicyNotRead(localAuth, credentials != "")
+		}
+	}
+
+	var wg sync.WaitGroup
+	s.RunSMBEnumeration(&wg)
+	err = s.ParseTargets(target)
+	if err != nil {
+		return err
+	}
+	wg.Wait()
+
+	// finish the execution
+	s.TimeEnd = time.Now()
+	if err = s.Options.LockoutGuard.Err(); err != nil {
+		s.CloseOutputter()
+		return err
+	}
+	logger.Warnf("Finished executing matrix module at %s", s.TimeEnd.Format("02/01/2006 15:04:05"))
+	s.CloseOutputter()
+	return nil
+}
+
+func ExecuteHunt(s *scanner.Scanner, username, password, hash, dc string, resolver net.IP, resolverThreads int, forest, dnsZones, trusts, huntTrusts bool, credentials string, maxAttempts int, lockoutCheck, laps bool, lapsUsername string, kerberos bool, aesKey, keytab, ccache, krb5Config string, dcHostname string, compareUser, comparePassword, compareHash string) error {
 	var targetDomain string
 	var targetUsername string
 	var err error
@@ -284,11 +473,39 @@ func ExecuteHunt(s *scanner.Scanner, username, password, hash string, dc, resolv
 		return errors.New("--password can't be used with --hashes")
 	}
 
+	// the dc argument is either an IP address or a domain name to locate DCs via DNS
+	dcIP, err := scanner.ParseDomainControllerAddress(dc)
+	if err != nil {
+		return err
+	}
+
 	// check if dcHostname is provided with kerberos authentication
-	if kerberos && dcHostname == "" {
+	// located domain controllers provide their own hostnames
+	if kerberos && dcHostname == "" && dcIP != nil {
 		return errors.New("--kerberos can't be used without --dc-hostname")
 	}
 
+	if resolverThreads < 1 {
+		return errors.New("--resolver-threads must be a positive number")
+	}
+
+	if laps && lapsUsername == "" {
+		return errors.New("--laps-username can't be empty")
+	}
+
+	err = setKerberosCredentials(s, kerberos, password, hash, aesKey, keytab, ccache, krb5Config)
+	if err != nil {
+		return err
+	}
+
+	if laps && credentials != "" {
+		return errors.New("--laps can't be used with --credentials")
+	}
+
+	if laps && compareUser != "" {
+		return errors.New("--laps can't be used with --compare-user")
+	}
+
 	// try to parse username in format DOMAIN\username
 	trySplit := strings.Split(username, "\\")
 	if len(trySplit) != 2 {
@@ -297,6 +514,16 @@ func ExecuteHunt(s *scanner.Scanner, username, password, hash string, dc, resolv
 	targetDomain = strings.ToLower(trySplit[0])
 	targetUsername = strings.ToLower(trySplit[1])
 
+	err = setCredentials(s, credentials, maxAttempts, targetDomain, false, kerberos)
+	if err != nil {
+		return err
+	}
+
+	err = setCompareCredential(s, compareUser, comparePassword, compareHash, maxAttempts, targetDomain, targetUsername, false, credentials != "")
+	if err != nil {
+		return err
+	}
+
 	// try to decode hash
 	var hashBytes []byte
 	if hash != "" {
@@ -313,10 +540,30 @@ func ExecuteHunt(s *scanner.Scanner, username, password, hash string, dc, resolv
 	s.Options.Kerberos = kerberos
 	s.Options.Domain = targetDomain
 	s.Options.LocalAuth = false
-	s.Options.DomainController = dc
 	s.Options.DCHostname = dcHostname
 	s.Options.CustomResolver = resolver
+	s.Options.ResolverThreads = resolverThreads
 	s.Options.Forest = forest
+	s.Options.DNSZones = dnsZones
+	s.Options.Trusts = trusts || huntTrusts
+	s.Options.HuntTrusts = huntTrusts
+	s.Options.LAPS = laps
+	s.Options.LAPSUsername = lapsUsername
+	// the policy is read once the domain controller is connected
+	s.Options.LockoutCheck = lockoutCheck
+	if lockoutCheck && credentials == "" {
+		s.Options.LockoutGuard = scanner.NewLockoutGuard()
+	}
+
+	if dcIP != nil {
+		s.Options.DomainController = dcIP
+	} else {
+		logger.Warnf("Locating domain controllers of %s", dc)
+		err = s.DiscoverDomainControllers(strings.ToLower(dc))
+		if err != nil {
+			return err
+		}
+	}
 
 	var wg sync.WaitGroup
 
@@ -326,23 +573,181 @@ func ExecuteHunt(s *scanner.Scanner, username, password, hash string, dc, resolv
 		logger.Warnf("Starting %s domain enumeration", s.Options.Domain)
 	}
 
-	// enumerate possible targets via domain controller
-	possibleTargets, err := s.RunEnumerateDomainComputers()
+	// check for shares and permissions on targets as soon as they are resolved
+	s.RunSMBEnumeration(&wg)
+	// enumerate domain computers via domain controller and send them to targets channel
+	_, err = s.RunEnumerateDomainComputers()
+	wg.Wait()
+	if guardErr := s.Options.LockoutGuard.Err(); guardErr != nil {
+		s.TimeEnd = time.Now()
+		s.CloseOutputter()
+		return guardErr
+	}
 	if err != nil {
 		return err
 	}
 
-	logger.Warnf("Found %d domain computers. Starting SMB shares enumeration...", len(possibleTargets))
-
-	// check for shares and permissions on identified targets
-	s.RunSMBEnumeration(&wg)
-	// send identified targets for processing channel
-	s.ParseTargetsInMemory(possibleTargets)
-	wg.Wait()
-
 	// finish the execution
 	s.TimeEnd = time.Now()
 	logger.Warnf("Finished executing hunt module at %s", s.TimeEnd.Format("02/01/2006 15:04:05"))
 	s.CloseOutputter()
 	return nil
 }
+
+// setKerberosCredentials validates the Kerberos key material and config flags
+// and sets them in the scanner options. The credential cache defaults to KRB5CCNAME, so a
+// ticket obtained beforehand is used without specifying it explicitly.
+func setKerberosCredentials(s *scanner.Scanner, kerberos bool, password, hash, aesKey, keytab, ccache, krb5Config string) error {
+	if !kerberos {
+		if aesKey != "" || keytab != "" || ccache != "" || krb5Config != "" {
+			return errors.New("--aes-key, --keytab, --ccache and --krb5-config can't be used without --kerberos")
+		}
+		return nil
+	}
+
+	// a real krb5.conf replaces the generated in-memory config
+	config, err := scanner.LoadKrb5Config(krb5Config)
+	if err != nil {
+		return err
+	}
+	s.Options.Krb5Config = config
+
+	specified := 0
+	for _, value := range []string{password, hash, aesKey, keytab, ccache} {
+		if value != "" {
+			specified++
+		}
+	}
+	if specified > 1 {
+		return errors.New("only one of --password, --hashes, --aes-key, --keytab and --ccache can be used")
+	}
+
+	if aesKey != "" {
+		key, err := hex.DecodeString(aesKey)
+		if err != nil {
+			return errors.New("invalid AES key, expected a hex string")
+		}
+		if len(key) != 16 && len(key) != 32 {
+			return errors.New("invalid AES key, expected an AES128 or AES256 key")
+		}
+		s.Options.AESKey = key
+	}
+	s.Options.Keytab = keytab
+
+	// fall back to the KRB5CCNAME cache only if no other secret is provided
+	if specified == 0 || ccache != "" {
+		s.Options.CCache = scanner.KerberosCCachePath(ccache)
+	}
+	if s.Options.CCache != "" {
+		if _, err := os.Stat(s.Options.CCache); err != nil {
+			return fmt.Errorf("kerberos credential cache: %w", err)
+		}
+		logger.Warnf("Using Kerberos credential cache %s", s.Options.CCache)
+	}
+	return nil
+}
+
+// setCredentials loads the accounts of the credentials file that are tried on
+// every host, with at most maxAttempts failed logons per account.
+func setCredentials(s *scanner.Scanner, path string, maxAttempts int, defaultDomain string, localAuth, kerberos bool) error {
+	if path == "" {
+		return nil
+	}
+	if kerberos {
+		return errors.New("--credentials can't be used with --kerberos")
+	}
+	if maxAttempts < 0 {
+		return errors.New("--max-attempts can't be negative")
+	}
+
+	credentials, err := scanner.LoadCredentials(path, defaultDomain, localAuth)
+	if err != nil {
+		return fmt.Errorf("credentials file %s: %w", path, err)
+	}
+	logger.Warnf("Loaded %d credentials from %s", len(credentials), path)
+
+	s.Options.Credentials = credentials
+	s.Options.CredentialTracker = scanner.NewCredentialTracker(maxAttempts)
+	return nil
+}
+
+// warnLockoutPolicyNotRead warns that the lockout guard uses the default
+// allowance and --credentials accounts are only capped by --max-attempts, as
+// the lockout policy is only read from --dc-ip.
+func warnLockoutPolicyNotRead(localAuth, credentials bool) {
+	limit := fmt.Sprintf("assuming %d failed logons are allowed", scanner.DefaultLockoutAllowance)
+	if credentials {
+		limit = "accounts from --credentials are only capped by --max-attempts"
+	}
+	if localAuth {
+		logger.Warnf("Lockout policy of local accounts is not read, %s", limit)
+		return
+	}
+	logger.Warnf("Lockout policy not read without --dc-ip, %s", limit)
+}
+
+// setCompareCredential sets the --compare-user account every host is enumerated
+// with besides the scan account, with at most maxAttempts failed logons. The
+// account is authenticated with NTLM, in the domain of the scan account unless
+// specified.
+func setCompareCredential(s *scanner.Scanner, compareUser, comparePassword, compareHash string, maxAttempts int, defaultDomain, username string, localAuth, credentials bool) error {
+	if compareUser == "" {
+		if comparePassword != "" || compareHash != "" {
+			return errors.New("--compare-password and --compare-hashes can't be used without --compare-user")
+		}
+		return nil
+	}
+	if credentials {
+		return errors.New("--compare-user can't be used with --credentials")
+	}
+	if comparePassword != "" && compareHash != "" {
+		return errors.New("--compare-password can't be used with --compare-hashes")
+	}
+	if maxAttempts < 0 {
+		return errors.New("--max-attempts can't be negative")
+	}
+
+	credential := &scanner.Credential{Source: scanner.CredentialSourceCompare, LocalAuth: localAuth, Password: comparePassword}
+	if domain, user, found := strings.Cut(compareUser, "\\"); found && !localAuth {
+		credential.Domain, credential.Username = domain, user
+	} else {
+		credential.Username = compareUser
+		if !localAuth {
+			credential.Domain = defaultDomain
+		}
+	}
+	if credential.Username == "" {
+		return errors.New("invalid --compare-user. Try DOMAIN\\username")
+	}
+	if strings.EqualFold(credential.Domain, defaultDomain) && strings.EqualFold(credential.Username, username) {
+		return errors.New("--compare-user must be another account than --username")
+	}
+	if compareHash != "" {
+		hash, err := hex.DecodeString(compareHash)
+		if err != nil {
+			return err
+		}
+		credential.Hash = hash
+	}
+	logger.Warnf("Comparing the access of %s", credential)
+
+	s.Options.CompareCredential = credential
+	s.Options.CredentialTracker = scanner.NewCredentialTracker(maxAttempts)
+	return nil
+}
+
+// setAccountOptions enables LSA and SAMR account enumeration of hosts that
+// allow anonymous access, with RID cycling over ridRange.
+func setAccountOptions(s *scanner.Scanner, accounts bool, ridRange string) error {
+	if !accounts {
+		return nil
+	}
+	first, last, err := scanner.ParseRIDRange(ridRange)
+	if err != nil {
+		return err
+	}
+	s.Options.Accounts = true
+	s.Options.RIDFirst = first
+	s.Options.RIDLast = last
+	return nil
+}
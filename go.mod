@@ -7,6 +7,7 @@ require (
 	github.com/go-ldap/ldap/v3 v3.4.13
 	github.com/jcmturner/gokrb5/v8 v8.4.4
 	github.com/jfjallid/go-smb v0.7.0
+	github.com/jfjallid/gokrb5/v8 v8.5.1
 	github.com/jfjallid/golog v0.3.5
 	golang.org/x/net v0.53.0
 )
@@ -24,7 +25,6 @@ require (
 	github.com/jcmturner/goidentity/v6 v6.0.1 // indirect
 	github.com/jcmturner/rpc/v2 v2.0.3 // indirect
 	github.com/jfjallid/gofork v1.7.6 // indirect
-	github.com/jfjallid/gokrb5/v8 v8.5.1 // indirect
 	github.com/jfjallid/mstypes v0.0.1 // indirect
 	github.com/jfjallid/ndr v0.0.2 // indirect
 	github.com/xhit/go-str2duration/v2 v2.1.0 // indirect
@@ -17,6 +17,8 @@ import (
 var goSmbLogPackages = []string{
 	"github.com/jfjallid/go-smb/smb",
 	"github.com/jfjallid/go-smb/dcerpc",
+	"github.com/jfjallid/go-smb/dcerpc/mslsad",
+	"github.com/jfjallid/go-smb/dcerpc/mssamr",
 	"github.com/jfjallid/go-smb/dcerpc/msrrp",
 	"github.com/jfjallid/go-smb/dcerpc/msscmr",
 	"github.com/jfjallid/go-smb/dcerpc/mssrvs",
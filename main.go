@@ -32,46 +32,108 @@ var (
 	timeoutFlag = app.Flag("timeout", "Seconds to wait for connection").Default("5s").Duration()
 	smbPortFlag = app.Flag("smb-port", "Target port of SMB service").Default("445").Int()
 	proxyFlag   = app.Flag("proxy", "SOCKS-proxy address to use for connection in format IP:PORT").Default("").String()
+	netbiosFlag = app.Flag("netbios-fallback", "Fall back to the NetBIOS session service on port 139 if --smb-port refuses the connection, after a timeout only if --sweep found 139 open").Default("true").Bool()
+
+	// port sweep flags
+	sweepFlag        = app.Flag("sweep", "TCP connect sweep of --smb-port and 139 to skip dead hosts before SMB enumeration").Default("false").Bool()
+	sweepOnlyFlag    = app.Flag("sweep-only", "Only print hosts with an open SMB port, implies --sweep").Default("false").Bool()
+	sweepThreadsFlag = app.Flag("sweep-threads", "Number of concurrent port sweep connections").Default("256").Int()
+	sweepTimeoutFlag = app.Flag("sweep-timeout", "Time to wait for a port sweep connection").Default("1s").Duration()
 
 	// SMB interaction flags
-	excludeFlag = app.Flag("exclude", "Exclude list").Short('e').Default("IPC$,NETLOGON,ADMIN$,print$,C$").String()
-	listFlag    = app.Flag("list", "List readable shares").Default("false").Bool()
-	recurseFlag = app.Flag("recurse", "List readable shares recursively").Default("false").Bool()
+	excludeFlag     = app.Flag("exclude", "Exclude list").Short('e').Default("IPC$,NETLOGON,ADMIN$,print$,C$").String()
+	listFlag        = app.Flag("list", "List readable shares").Default("false").Bool()
+	recurseFlag     = app.Flag("recurse", "List readable shares recursively").Default("false").Bool()
+	fingerprintFlag = app.Flag("fingerprint", "Record SMB dialect, SMBv1, encryption and NTLM target info of hosts, logs on once more per host to read the encryption").Default("false").Bool()
+	pipesFlag       = app.Flag("pipes", "Probe well-known named pipes on IPC$ and report the reachable RPC interfaces").Default("false").Bool()
+	adminAuditFlag  = app.Flag("admin-audit", "Query service states and SMB/NTLM registry settings of hosts with local admin rights").Default("false").Bool()
+	bruteforceFlag  = app.Flag("bruteforce-shares", "Also try common share names, done anyway if listing shares via srvsvc is denied").Default("false").Bool()
+	wordlistFlag    = app.Flag("share-wordlist", "File with share names to try besides the built-in list").Default("").String()
+	lifecycleFlag   = app.Flag("lifecycle-table", "JSON file with Windows support dates replacing the built-in lifecycle table").Default("").String()
+
+	// info command
+	// read host information from the NTLM challenge without authentication
+	infoCommand   = app.Command("info", "unauthenticated host information module")
+	infoTargetArg = infoCommand.Arg("target", "Target, IP range or filename").Required().String()
 
 	// null command
 	// find null sessions shares and permissions
-	nullCommand   = app.Command("null", "null session module")
-	nullTargetArg = nullCommand.Arg("target", "Target, IP range of filename").Required().String()
+	nullCommand      = app.Command("null", "null session module")
+	nullTargetArg    = nullCommand.Arg("target", "Target, IP range of filename").Required().String()
+	nullAccountsFlag = nullCommand.Flag("accounts", "Enumerate users and groups via LSA RID cycling and SAMR").Default("false").Bool()
+	nullRIDRangeFlag = nullCommand.Flag("rid-range", "RIDs to translate via LsarLookupSids in format FIRST-LAST").Default("500-4000").String()
 
 	// guest command
 	// find guest authentication shares and permissions
 	guestCommand      = app.Command("guest", "guest module")
 	guestTargetArg    = guestCommand.Arg("target", "Target, IP range or filename").Required().String()
 	guestUsernameFlag = guestCommand.Flag("username", "Username to authenticate as Guest").String()
+	guestAccountsFlag = guestCommand.Flag("accounts", "Enumerate users and groups via LSA RID cycling and SAMR").Default("false").Bool()
+	guestRIDRangeFlag = guestCommand.Flag("rid-range", "RIDs to translate via LsarLookupSids in format FIRST-LAST").Default("500-4000").String()
 
 	// auth command
 	// find authenticated shares and permissions
-	authCommand        = app.Command("auth", "authenticated module")
-	authTargetArg      = authCommand.Arg("target", "Target, IP range or filename").Required().String()
-	authUsernameFlag   = authCommand.Flag("username", "Username in format DOMAIN\\username for domain auth, and just username for local auth").Short('u').Required().String()
-	authPasswordFlag   = authCommand.Flag("password", "User's password").Short('p').String()
-	authHashFlag       = authCommand.Flag("hashes", "NTLM hash of password to authenticate").Short('H').String()
-	authLocalAuthFlag  = authCommand.Flag("local-auth", "Enable local authentication, the username is passed without domain").Bool()
-	authKerberosFlag   = authCommand.Flag("kerberos", "Use Kerberos authentication").Short('k').Bool()
-	authDcHostnameFlag = authCommand.Flag("dc-hostname", "Hostname of domain controller for Kerberos authentication").String()
-	authDcIPFlag       = authCommand.Flag("dc-ip", "IP of KDC when using Kerberos authentication").IP()
+	authCommand         = app.Command("auth", "authenticated module")
+	authTargetArg       = authCommand.Arg("target", "Target, IP range or filename").Required().String()
+	authUsernameFlag    = authCommand.Flag("username", "Username in format DOMAIN\\username for domain auth, and just username for local auth").Short('u').String()
+	authPasswordFlag    = authCommand.Flag("password", "User's password").Short('p').String()
+	authHashFlag        = authCommand.Flag("hashes", "NTLM hash of password to authenticate").Short('H').String()
+	authCredsFlag       = authCommand.Flag("credentials", "File with DOMAIN\\user:password or user:hash entries to try on every host").String()
+	authMaxAttempts     = authCommand.Flag("max-attempts", "Failed logons per account from --credentials or --compare-user before it is skipped, lowered by the lockout policy, 0 for no limit").Default("3").Int()
+	authLockoutFlag     = authCommand.Flag("lockout-check", "Read the lockout policy via --dc-ip, cap failed logons of --credentials accounts by it and abort on failed logons of the account").Default("true").Bool()
+	authLocalAuthFlag   = authCommand.Flag("local-auth", "Enable local authentication, the username is passed without domain").Bool()
+	authKerberosFlag    = authCommand.Flag("kerberos", "Use Kerberos authentication").Short('k').Bool()
+	authDcHostnameFlag  = authCommand.Flag("dc-hostname", "Hostname of domain controller for Kerberos authentication").String()
+	authDcIPFlag        = authCommand.Flag("dc-ip", "IP of KDC when using Kerberos authentication").IP()
+	authAESKeyFlag      = authCommand.Flag("aes-key", "AES128 or AES256 key (hex) for Kerberos authentication").String()
+	authKeytabFlag      = authCommand.Flag("keytab", "Keytab file for Kerberos authentication").String()
+	authCCacheFlag      = authCommand.Flag("ccache", "Kerberos credential cache file, defaults to KRB5CCNAME").String()
+	authKrb5ConfigFlag  = authCommand.Flag("krb5-config", "krb5.conf file for Kerberos authentication, defaults to KRB5_CONFIG").String()
+	authCompareUserFlag = authCommand.Flag("compare-user", "Second account in format DOMAIN\\username to report the access differences to --username").String()
+	authComparePassFlag = authCommand.Flag("compare-password", "Password of --compare-user").String()
+	authCompareHashFlag = authCommand.Flag("compare-hashes", "NTLM hash of the password of --compare-user").String()
+
+	// matrix command
+	// find the shares and permissions of null, guest and authenticated sessions in one pass
+	matrixCommand           = app.Command("matrix", "access matrix module")
+	matrixTargetArg         = matrixCommand.Arg("target", "Target, IP range or filename").Required().String()
+	matrixGuestUsernameFlag = matrixCommand.Flag("guest-username", "Username to authenticate as Guest").String()
+	matrixUsernameFlag      = matrixCommand.Flag("username", "Username in format DOMAIN\\username for domain auth, and just username for local auth").Short('u').String()
+	matrixPasswordFlag      = matrixCommand.Flag("password", "User's password").Short('p').String()
+	matrixHashFlag          = matrixCommand.Flag("hashes", "NTLM hash of password to authenticate").Short('H').String()
+	matrixCredsFlag         = matrixCommand.Flag("credentials", "File with DOMAIN\\user:password or user:hash entries to add to the matrix").String()
+	matrixMaxAttempts       = matrixCommand.Flag("max-attempts", "Failed logons per account from --credentials before it is skipped, lowered by the lockout policy, 0 for no limit").Default("3").Int()
+	matrixLockoutFlag       = matrixCommand.Flag("lockout-check", "Read the lockout policy via --dc-ip, cap failed logons of --credentials accounts by it and abort on failed logons of the account").Default("true").Bool()
+	matrixLocalAuthFlag     = matrixCommand.Flag("local-auth", "Enable local authentication, the username is passed without domain").Bool()
+	matrixDcIPFlag          = matrixCommand.Flag("dc-ip", "IP of domain controller to read the lockout policy from").IP()
 
 	// hunt command
 	// hunt for targets from AD and find shares and permissions
-	huntCommand        = app.Command("hunt", "hunting module")
-	huntDcArg          = huntCommand.Arg("dc", "Domain Controller IP").Required().IP()
-	huntUsernameFlag   = huntCommand.Flag("username", "Domain username in format DOMAIN\\username").Short('u').Required().String()
-	huntPasswordFlag   = huntCommand.Flag("password", "Domain user's password").Short('p').String()
-	huntHashFlag       = huntCommand.Flag("hashes", "NTLM hash of password to authenticate").Short('H').String()
-	huntResolverFlag   = huntCommand.Flag("resolver", "Custom DNS resolver IP address").Short('r').IP()
-	huntForestFlag     = huntCommand.Flag("forest", "Search all available domains in the current forest").Default("false").Bool()
-	huntKerberosFlag   = huntCommand.Flag("kerberos", "Use Kerberos authentication").Short('k').Bool()
-	huntDcHostnameFlag = huntCommand.Flag("dc-hostname", "Hostname of domain controller for Kerberos authentication").String()
+	huntCommand             = app.Command("hunt", "hunting module")
+	huntDcArg               = huntCommand.Arg("dc", "Domain Controller IP, or domain name to locate domain controllers via DNS").Required().String()
+	huntUsernameFlag        = huntCommand.Flag("username", "Domain username in format DOMAIN\\username").Short('u').Required().String()
+	huntPasswordFlag        = huntCommand.Flag("password", "Domain user's password").Short('p').String()
+	huntHashFlag            = huntCommand.Flag("hashes", "NTLM hash of password to authenticate").Short('H').String()
+	huntResolverFlag        = huntCommand.Flag("resolver", "Custom DNS resolver IP address").Short('r').IP()
+	huntResolverThreadsFlag = huntCommand.Flag("resolver-threads", "Number of concurrent DNS resolution workers").Default("20").Int()
+	huntForestFlag          = huntCommand.Flag("forest", "Search all available domains in the current forest").Default("false").Bool()
+	huntDNSZonesFlag        = huntCommand.Flag("dns-zones", "Also discover hosts from AD-integrated DNS zones").Default("false").Bool()
+	huntTrustsFlag          = huntCommand.Flag("trusts", "Enumerate domain trusts and report the trust graph").Default("false").Bool()
+	huntHuntTrustsFlag      = huntCommand.Flag("hunt-trusts", "Also hunt trusted domains the credentials can bind to (implies --trusts)").Default("false").Bool()
+	huntCredsFlag           = huntCommand.Flag("credentials", "File with DOMAIN\\user:password or user:hash entries to try on every host").String()
+	huntMaxAttempts         = huntCommand.Flag("max-attempts", "Failed logons per account from --credentials or --compare-user before it is skipped, lowered by the lockout policy, 0 for no limit").Default("3").Int()
+	huntLockoutFlag         = huntCommand.Flag("lockout-check", "Read the lockout policy, cap failed logons of --credentials accounts by it and abort on failed logons of the account").Default("true").Bool()
+	huntLAPSFlag            = huntCommand.Flag("laps", "Authenticate to each host with its LAPS local administrator password if readable").Default("false").Bool()
+	huntLAPSUsernameFlag    = huntCommand.Flag("laps-username", "Local administrator account name for legacy LAPS passwords").Default("Administrator").String()
+	huntKerberosFlag        = huntCommand.Flag("kerberos", "Use Kerberos authentication").Short('k').Bool()
+	huntDcHostnameFlag      = huntCommand.Flag("dc-hostname", "Hostname of domain controller for Kerberos authentication").String()
+	huntAESKeyFlag          = huntCommand.Flag("aes-key", "AES128 or AES256 key (hex) for Kerberos authentication").String()
+	huntKeytabFlag          = huntCommand.Flag("keytab", "Keytab file for Kerberos authentication").String()
+	huntCCacheFlag          = huntCommand.Flag("ccache", "Kerberos credential cache file, defaults to KRB5CCNAME").String()
+	huntKrb5ConfigFlag      = huntCommand.Flag("krb5-config", "krb5.conf file for Kerberos authentication, defaults to KRB5_CONFIG").String()
+	huntCompareUserFlag     = huntCommand.Flag("compare-user", "Second account in format DOMAIN\\username to report the access differences to --username").String()
+	huntComparePassFlag     = huntCommand.Flag("compare-password", "Password of --compare-user").String()
+	huntCompareHashFlag     = huntCommand.Flag("compare-hashes", "NTLM hash of the password of --compare-user").String()
 )
 
 func main() {
@@ -109,8 +171,19 @@ func main() {
 		*excludeFlag,
 		*listFlag,
 		*recurseFlag,
+		*fingerprintFlag,
+		*pipesFlag,
+		*adminAuditFlag,
+		*bruteforceFlag,
+		*wordlistFlag,
+		*lifecycleFlag,
 		*smbPortFlag,
 		*proxyFlag,
+		*netbiosFlag,
+		*sweepFlag,
+		*sweepOnlyFlag,
+		*sweepThreadsFlag,
+		*sweepTimeoutFlag,
 	)
 	if err != nil {
 		logger.Fatal(err)
@@ -128,17 +201,23 @@ func main() {
 	}()
 
 	// execute specified command
+	if command == infoCommand.FullCommand() {
+		err = cmd.ExecuteInfo(scanner, *infoTargetArg)
+	}
 	if command == nullCommand.FullCommand() {
-		err = cmd.ExecuteNull(scanner, *nullTargetArg)
+		err = cmd.ExecuteNull(scanner, *nullTargetArg, *nullAccountsFlag, *nullRIDRangeFlag)
 	}
 	if command == guestCommand.FullCommand() {
-		err = cmd.ExecuteGuest(scanner, *guestTargetArg, *guestUsernameFlag)
+		err = cmd.ExecuteGuest(scanner, *guestTargetArg, *guestUsernameFlag, *guestAccountsFlag, *guestRIDRangeFlag)
 	}
 	if command == authCommand.FullCommand() {
-		err = cmd.ExecuteAuth(scanner, *authTargetArg, *authUsernameFlag, *authPasswordFlag, *authHashFlag, *authLocalAuthFlag, *authKerberosFlag, *authDcHostnameFlag, *authDcIPFlag)
+		err = cmd.ExecuteAuth(scanner, *authTargetArg, *authUsernameFlag, *authPasswordFlag, *authHashFlag, *authCredsFlag, *authMaxAttempts, *authLockoutFlag, *authLocalAuthFlag, *authKerberosFlag, *authAESKeyFlag, *authKeytabFlag, *authCCacheFlag, *authKrb5ConfigFlag, *authDcHostnameFlag, *authDcIPFlag, *authCompareUserFlag, *authComparePassFlag, *authCompareHashFlag)
+	}
+	if command == matrixCommand.FullCommand() {
+		err = cmd.ExecuteMatrix(scanner, *matrixTargetArg, *matrixGuestUsernameFlag, *matrixUsernameFlag, *matrixPasswordFlag, *matrixHashFlag, *matrixCredsFlag, *matrixMaxAttempts, *matrixLockoutFlag, *matrixLocalAuthFlag, *matrixDcIPFlag)
 	}
 	if command == huntCommand.FullCommand() {
-		err = cmd.ExecuteHunt(scanner, *huntUsernameFlag, *huntPasswordFlag, *huntHashFlag, *huntDcArg, *huntResolverFlag, *huntForestFlag, *huntKerberosFlag, *huntDcHostnameFlag)
+		err = cmd.ExecuteHunt(scanner, *huntUsernameFlag, *huntPasswordFlag, *huntHashFlag, *huntDcArg, *huntResolverFlag, *huntResolverThreadsFlag, *huntForestFlag, *huntDNSZonesFlag, *huntTrustsFlag, *huntHuntTrustsFlag, *huntCredsFlag, *huntMaxAttempts, *huntLockoutFlag, *huntLAPSFlag, *huntLAPSUsernameFlag, *huntKerberosFlag, *huntAESKeyFlag, *huntKeytabFlag, *huntCCacheFlag, *huntKrb5ConfigFlag, *huntDcHostnameFlag, *huntCompareUserFlag, *huntComparePassFlag, *huntCompareHashFlag)
 	}
 	if err != nil {
 		logger.Fatal(err)
@@ -0,0 +1,339 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jfjallid/go-smb/dcerpc"
+	"github.com/jfjallid/go-smb/dcerpc/mslsad"
+	"github.com/jfjallid/go-smb/dcerpc/mssamr"
+	"github.com/jfjallid/go-smb/dcerpc/smbtransport"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Sources of enumerated accounts
+const (
+	AccountSourceLSA  = "lsa"  // RID cycling with LsarLookupSids2
+	AccountSourceSAMR = "samr" // SamrEnumDomainUsers, groups and aliases
+)
+
+const (
+	// ridBatchSize is the number of SIDs translated per LsarLookupSids2 request
+	ridBatchSize = 100
+
+	lsaStatusSomeNotMapped = 0x00000107
+	lsaStatusNoneMapped    = 0xc0000073
+)
+
+// AccountEnumeration is the outcome of enumerating the accounts of a host
+// through LSA and SAMR, errors are recorded per interface
+type AccountEnumeration struct {
+	RIDRange  string          `xml:"rid_range,attr"`
+	LSAError  string          `xml:"lsa_error,attr,omitempty"`
+	SAMRError string          `xml:"samr_error,attr,omitempty"`
+	Domains   []AccountDomain `xml:"domain"`
+	Accounts  []Account       `xml:"account"`
+
+	known map[string]bool // domains and RIDs of Accounts
+}
+
+// AccountDomain is an account domain of a host with its SID
+type AccountDomain struct {
+	Name string `xml:"name,attr"`
+	SID  string `xml:"sid,attr"`
+}
+
+// Account is a user, group or alias of an account domain
+type Account struct {
+	Domain string `xml:"domain,attr"`
+	Name   string `xml:"name,attr"`
+	RID    uint32 `xml:"rid,attr"`
+	Type   string `xml:"type,attr"`
+	Source string `xml:"source,attr"`
+}
+
+// FullName returns the account name in DOMAIN\name format.
+func (a Account) FullName() string {
+	if a.Domain == "" {
+		return a.Name
+	}
+	return a.Domain + `\` + a.Name
+}
+
+// Users returns the user accounts of the enumeration.
+func (e *AccountEnumeration) Users() []Account {
+	return e.accountsOfType("user")
+}
+
+// Groups returns the groups and aliases of the enumeration.
+func (e *AccountEnumeration) Groups() []Account {
+	return append(e.accountsOfType("group"), e.accountsOfType("alias")...)
+}
+
+func (e *AccountEnumeration) accountsOfType(accountType string) []Account {
+	var accounts []Account
+	for _, account := range e.Accounts {
+		if account.Type == accountType {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts
+}
+
+// add records account unless an account with the same domain and RID is
+// already known.
+func (e *AccountEnumeration) add(account Account) {
+	key := strings.ToLower(account.Domain) + `\` + strconv.FormatUint(uint64(account.RID), 10)
+	if e.known == nil {
+		e.known = make(map[string]bool)
+	}
+	if !e.known[key] {
+		e.known[key] = true
+		e.Accounts = append(e.Accounts, account)
+	}
+}
+
+func (e *AccountEnumeration) addDomain(name, sid string) {
+	if !slices.ContainsFunc(e.Domains, func(known AccountDomain) bool { return known.SID == sid }) {
+		e.Domains = append(e.Domains, AccountDomain{Name: name, SID: sid})
+	}
+}
+
+// ParseRIDRange parses a RID range in FIRST-LAST format.
+func ParseRIDRange(value string) (uint32, uint32, error) {
+	firstValue, lastValue, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("invalid RID range %q, try 500-4000", value)
+	}
+	first, err := strconv.ParseUint(strings.TrimSpace(firstValue), 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid RID range %q, try 500-4000", value)
+	}
+	last, err := strconv.ParseUint(strings.TrimSpace(lastValue), 10, 32)
+	if err != nil || last < first {
+		return 0, 0, fmt.Errorf("invalid RID range %q, try 500-4000", value)
+	}
+	return uint32(first), uint32(last), nil
+}
+
+// accountType returns the report name of a SID type, empty for SIDs that
+// don't map to an account.
+func accountType(use mslsad.SidNameUse) string {
+	switch use {
+	case mslsad.SidTypeUser:
+		return "user"
+	case mslsad.SidTypeGroup:
+		return "group"
+	case mslsad.SidTypeAlias:
+		return "alias"
+	case mslsad.SidTypeWellKnownGroup:
+		return "well_known_group"
+	case mslsad.SidTypeComputer:
+		return "computer"
+	case mslsad.SidTypeDeletedAccount:
+		return "deleted"
+	default:
+		return ""
+	}
+}
+
+// ridOfSID returns the last sub-authority of a SID string.
+func ridOfSID(sid string) (uint32, error) {
+	index := strings.LastIndex(sid, "-")
+	if index < 0 {
+		return 0, fmt.Errorf("invalid SID %s", sid)
+	}
+	rid, err := strconv.ParseUint(sid[index+1:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SID %s", sid)
+	}
+	return uint32(rid), nil
+}
+
+// translatedAccounts returns the accounts of a LsarLookupSids2 result.
+func translatedAccounts(res mslsad.SidTranslations) []Account {
+	var accounts []Account
+	for _, translation := range res.TranslatedNames {
+		accountType := accountType(translation.Use)
+		if accountType == "" {
+			continue
+		}
+		rid, err := ridOfSID(translation.Sid)
+		if err != nil {
+			continue
+		}
+		account := Account{Name: translation.Name, RID: rid, Type: accountType, Source: AccountSourceLSA}
+		if index := int(translation.DomainIndex); index >= 0 && index < len(res.ReferencedDomains) {
+			account.Domain = res.ReferencedDomains[index].Name
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// EnumerateAccounts looks up the account domains of the host through LSA and
+// translates the SIDs of RIDs first to last, then enumerates the users,
+// groups and aliases through SAMR. computerName is the NetBIOS name of the
+// host, used to find its local account domain.
+func (conn *Connection) EnumerateAccounts(computerName string, first, last uint32) *AccountEnumeration {
+	result := &AccountEnumeration{RIDRange: fmt.Sprintf("%d-%d", first, last)}
+	if err := conn.cycleRIDs(result, computerName, first, last); err != nil {
+		result.LSAError = err.Error()
+	}
+	if err := conn.enumerateSAMR(result); err != nil {
+		result.SAMRError = err.Error()
+	}
+	return result
+}
+
+// cycleRIDs translates the SIDs of RIDs first to last in the local account
+// domain and the primary domain of the host.
+func (conn *Connection) cycleRIDs(result *AccountEnumeration, computerName string, first, last uint32) error {
+	share := "IPC$"
+	if err := conn.session.TreeConnect(share); err != nil {
+		return err
+	}
+	defer conn.session.TreeDisconnect(share)
+
+	f, err := conn.session.OpenFile(share, mslsad.MSRPCLsaRpcPipe)
+	if err != nil {
+		return err
+	}
+	defer f.CloseFile()
+
+	transport, err := smbtransport.NewSMBTransport(f)
+	if err != nil {
+		return err
+	}
+	bind, err := dcerpc.Bind(transport, mslsad.MSRPCUuidLsaRpc, mslsad.MSRPCLsaRpcMajorVersion, mslsad.MSRPCLsaRpcMinorVersion, dcerpc.MSRPCUuidNdr)
+	if err != nil {
+		return err
+	}
+	rpccon := mslsad.NewRPCCon(bind)
+
+	// the computer name translates to the local account domain, which is
+	// the domain itself on domain controllers
+	var domainErr error
+	if computerName != "" {
+		names, err := rpccon.LsarLookupNames3(mslsad.LsapLookupWksta, []string{computerName})
+		if err != nil {
+			domainErr = err
+		} else if len(names.TranslatedSids) > 0 && names.TranslatedSids[0].Use == mslsad.SidTypeDomain {
+			result.addDomain(computerName, names.TranslatedSids[0].Sid)
+		}
+	}
+	primary, err := rpccon.GetPrimaryDomainInfo()
+	if err != nil {
+		domainErr = err
+	} else if primary != nil && primary.Sid != nil {
+		result.addDomain(primary.Name, primary.Sid.ToString())
+	}
+	if len(result.Domains) == 0 {
+		if domainErr != nil {
+			return domainErr
+		}
+		return errors.New("no account domain found")
+	}
+
+	for _, domain := range result.Domains {
+		for batchStart := uint64(first); batchStart <= uint64(last); batchStart += ridBatchSize {
+			batchEnd := min(batchStart+ridBatchSize-1, uint64(last))
+			var sids []string
+			for rid := batchStart; rid <= batchEnd; rid++ {
+				sids = append(sids, fmt.Sprintf("%s-%d", domain.SID, rid))
+			}
+			res, err := rpccon.LsarLookupSids2(mslsad.LsapLookupWksta, sids)
+			if err != nil {
+				return err
+			}
+			switch res.ReturnCode {
+			case mslsad.StatusSuccess, lsaStatusSomeNotMapped, lsaStatusNoneMapped:
+			case mslsad.StatusAccessDenied:
+				return mslsad.ResponseCodeMap[mslsad.StatusAccessDenied]
+			default:
+				return fmt.Errorf("LsarLookupSids2 returned 0x%08x", res.ReturnCode)
+			}
+			for _, account := range translatedAccounts(res) {
+				result.add(account)
+			}
+		}
+	}
+	return nil
+}
+
+// enumerateSAMR lists the users, groups and aliases of every SAMR domain of
+// the host.
+func (conn *Connection) enumerateSAMR(result *AccountEnumeration) error {
+	share := "IPC$"
+	if err := conn.session.TreeConnect(share); err != nil {
+		return err
+	}
+	defer conn.session.TreeDisconnect(share)
+
+	f, err := conn.session.OpenFile(share, mssamr.MSRPCSamrPipe)
+	if err != nil {
+		return err
+	}
+	defer f.CloseFile()
+
+	transport, err := smbtransport.NewSMBTransport(f)
+	if err != nil {
+		return err
+	}
+	bind, err := dcerpc.Bind(transport, mssamr.MSRPCUuidSamr, mssamr.MSRPCSamrMajorVersion, mssamr.MSRPCSamrMinorVersion, dcerpc.MSRPCUuidNdr)
+	if err != nil {
+		return err
+	}
+	rpccon := mssamr.NewRPCCon(bind)
+
+	handle, err := rpccon.SamrConnect5("")
+	if err != nil {
+		return err
+	}
+	defer rpccon.SamrCloseHandle(handle)
+	domains, err := rpccon.SamrEnumDomains(handle)
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range domains {
+		sid, err := rpccon.SamrLookupDomain(handle, domain)
+		if err != nil {
+			return err
+		}
+		domainHandle, err := rpccon.SamrOpenDomain(handle, mssamr.MaximumAllowed, sid)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(domain, "Builtin") {
+			result.addDomain(domain, sid.ToString())
+			// the builtin domain only has aliases
+			users, err := rpccon.SamrEnumDomainUsers(domainHandle, 0, 0)
+			if err != nil {
+				rpccon.SamrCloseHandle(domainHandle)
+				return err
+			}
+			for _, user := range users {
+				result.add(Account{Domain: domain, Name: user.Name, RID: user.RelativeId, Type: "user", Source: AccountSourceSAMR})
+			}
+			groups, err := rpccon.SamrEnumerateGroupsInDomain(domainHandle, 0)
+			if err != nil {
+				rpccon.SamrCloseHandle(domainHandle)
+				return err
+			}
+			for _, group := range groups {
+				result.add(Account{Domain: domain, Name: group.Name, RID: group.RelativeId, Type: "group", Source: AccountSourceSAMR})
+			}
+		}
+		aliases, err := rpccon.SamrEnumAliasesInDomain(domainHandle, 0)
+		rpccon.SamrCloseHandle(domainHandle)
+		if err != nil {
+			return err
+		}
+		for _, alias := range aliases {
+			result.add(Account{Domain: domain, Name: alias.Name, RID: alias.RelativeId, Type: "alias", Source: AccountSourceSAMR})
+		}
+	}
+	return nil
+}
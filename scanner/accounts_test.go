@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"github.com/jfjallid/go-smb/dcerpc/mslsad"
+	"strings"
+	"testing"
+)
+
+func TestParseRIDRange(t *testing.T) {
+	first, last, err := ParseRIDRange("500-4000")
+	if err != nil || first != 500 || last != 4000 {
+		t.Errorf("unexpected range %d-%d: %v", first, last, err)
+	}
+	for _, value := range []string{"500", "4000-500", "a-b", "-1-10", ""} {
+		if _, _, err := ParseRIDRange(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestTranslatedAccounts(t *testing.T) {
+	domainSID := "S-1-5-21-1004336348-1177238915-682003330"
+	res := mslsad.SidTranslations{
+		ReferencedDomains: []mslsad.DomainTranslation{{Name: "CORP", Sid: domainSID}},
+		TranslatedNames: []mslsad.SidNameTranslation{
+			{Use: mslsad.SidTypeUser, Name: "Administrator", Sid: domainSID + "-500", DomainIndex: 0},
+			{Use: mslsad.SidTypeGroup, Name: "Domain Admins", Sid: domainSID + "-512", DomainIndex: 0},
+			{Use: mslsad.SidTypeUnknown, Name: "", Sid: domainSID + "-513", DomainIndex: -1},
+			{Use: mslsad.SidTypeComputer, Name: "FS01$", Sid: domainSID + "-1104", DomainIndex: 0},
+		},
+	}
+
+	accounts := translatedAccounts(res)
+	if len(accounts) != 3 {
+		t.Fatalf("expected 3 accounts, got %+v", accounts)
+	}
+	expected := Account{Domain: "CORP", Name: "Administrator", RID: 500, Type: "user", Source: AccountSourceLSA}
+	if accounts[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, accounts[0])
+	}
+	if accounts[2].Type != "computer" || accounts[2].FullName() != `CORP\FS01$` {
+		t.Errorf("unexpected account %+v", accounts[2])
+	}
+}
+
+func TestAccountEnumerationAdd(t *testing.T) {
+	var enumeration AccountEnumeration
+	enumeration.add(Account{Domain: "CORP", Name: "Administrator", RID: 500, Type: "user", Source: AccountSourceLSA})
+	// the same account found through SAMR is only recorded once
+	enumeration.add(Account{Domain: "corp", Name: "Administrator", RID: 500, Type: "user", Source: AccountSourceSAMR})
+	enumeration.add(Account{Domain: "FS01", Name: "Administrator", RID: 500, Type: "user", Source: AccountSourceSAMR})
+	enumeration.add(Account{Domain: "Builtin", Name: "Users", RID: 545, Type: "alias", Source: AccountSourceSAMR})
+	if len(enumeration.Accounts) != 3 {
+		t.Fatalf("expected 3 accounts, got %+v", enumeration.Accounts)
+	}
+	if len(enumeration.Users()) != 2 || len(enumeration.Groups()) != 1 {
+		t.Errorf("unexpected users %v and groups %v", enumeration.Users(), enumeration.Groups())
+	}
+
+	enumeration.addDomain("CORP", "S-1-5-21-1-2-3")
+	enumeration.addDomain("CORP", "S-1-5-21-1-2-3")
+	if len(enumeration.Domains) != 1 {
+		t.Errorf("expected a single domain, got %+v", enumeration.Domains)
+	}
+
+	output := SprintAccounts(&enumeration)
+	if !strings.Contains(output, `CORP\Administrator`) || !strings.Contains(output, "Builtin\\Users") {
+		t.Errorf("unexpected output %q", output)
+	}
+	if SprintAccounts(nil) != "" {
+		t.Error("expected no output without accounts")
+	}
+
+	run := SharefinderRun{Hosts: []Host{{IP: "10.0.0.1", Accounts: &enumeration}, {IP: "10.0.0.2"}}}
+	if len(run.AccountHosts()) != 1 || run.AccountCount() != 3 {
+		t.Errorf("unexpected account hosts %d and count %d", len(run.AccountHosts()), run.AccountCount())
+	}
+}
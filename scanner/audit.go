@@ -0,0 +1,386 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jfjallid/go-smb/dcerpc"
+	"github.com/jfjallid/go-smb/dcerpc/msrrp"
+	"github.com/jfjallid/go-smb/dcerpc/msscmr"
+	"github.com/jfjallid/go-smb/dcerpc/smbtransport"
+	"github.com/jfjallid/go-smb/msdtyp"
+	"strings"
+)
+
+// ServiceNotInstalled is the state of an audited service missing on the host
+const ServiceNotInstalled = "not_installed"
+
+// auditedService is a service queried by the admin audit and the weakness
+// reported while it is running
+type auditedService struct {
+	Name    string
+	Finding string
+}
+
+// auditedServices are the services queried over svcctl by the admin audit
+var auditedServices = []auditedService{
+	{"Spooler", "print spooler allows PrinterBug coercion"},
+	{"WebClient", "WebDAV client allows coercion over HTTP"},
+	{"RemoteRegistry", ""},
+	{"EFS", "encrypting file system allows PetitPotam coercion"},
+}
+
+// auditedValue is a registry value read by the admin audit. finding returns a
+// description of the weakness of a value, empty if the value is safe. set is
+// false when the value doesn't exist and the Windows default applies. Values
+// without finding are only recorded.
+type auditedValue struct {
+	Key     string
+	Name    string
+	finding func(value any, set bool) string
+}
+
+const (
+	lsaKey                = `SYSTEM\CurrentControlSet\Control\Lsa`
+	lanmanServerKey       = `SYSTEM\CurrentControlSet\Services\LanmanServer\Parameters`
+	lanmanWorkstationKey  = `SYSTEM\CurrentControlSet\Services\LanmanWorkstation\Parameters`
+	ntlmv1FindingMaxLevel = 2
+)
+
+// auditedValues are the registry values read over winreg by the admin audit
+var auditedValues = []auditedValue{
+	{lsaKey, "LmCompatibilityLevel", func(value any, set bool) string {
+		if level, ok := value.(uint32); set && ok && level <= ntlmv1FindingMaxLevel {
+			return "LM and NTLMv1 responses are sent"
+		}
+		return ""
+	}},
+	{lsaKey, "RestrictAnonymous", nil},
+	{lanmanServerKey, "RequireSecuritySignature", func(value any, set bool) string {
+		if dword, ok := value.(uint32); !set || (ok && dword == 0) {
+			return "server doesn't require SMB signing"
+		}
+		return ""
+	}},
+	{lanmanServerKey, "RestrictNullSessAccess", func(value any, set bool) string {
+		if dword, ok := value.(uint32); set && ok && dword == 0 {
+			return "null sessions aren't restricted"
+		}
+		return ""
+	}},
+	{lanmanServerKey, "NullSessionShares", func(value any, set bool) string {
+		if names, ok := value.([]string); set && ok && len(nonEmpty(names)) > 0 {
+			return "shares are accessible with null sessions"
+		}
+		return ""
+	}},
+	{lanmanServerKey, "NullSessionPipes", nil},
+	{lanmanWorkstationKey, "RequireSecuritySignature", func(value any, set bool) string {
+		if dword, ok := value.(uint32); !set || (ok && dword == 0) {
+			return "client doesn't require SMB signing"
+		}
+		return ""
+	}},
+}
+
+// AdminAudit is the configuration of a host read with local admin rights
+type AdminAudit struct {
+	ServiceError  string          `xml:"service_error,attr,omitempty"`
+	RegistryError string          `xml:"registry_error,attr,omitempty"`
+	Services      []ServiceState  `xml:"service"`
+	Registry      []RegistryValue `xml:"registry"`
+}
+
+// ServiceState is the state of a service on a host
+type ServiceState struct {
+	Name      string `xml:"name,attr"`
+	State     string `xml:"state,attr"`
+	StartType string `xml:"start_type,attr,omitempty"`
+	Finding   string `xml:"finding,attr,omitempty"`
+}
+
+// RegistryValue is a registry value of HKLM on a host. Set is false when the
+// value doesn't exist.
+type RegistryValue struct {
+	Key     string `xml:"key,attr"`
+	Name    string `xml:"name,attr"`
+	Value   string `xml:"value,attr"`
+	Set     bool   `xml:"set,attr"`
+	Finding string `xml:"finding,attr,omitempty"`
+}
+
+// Path returns the full path of the value below HKLM.
+func (v RegistryValue) Path() string {
+	return `HKLM\` + v.Key + `\` + v.Name
+}
+
+// Findings returns the weaknesses found by the audit.
+func (a *AdminAudit) Findings() []string {
+	var findings []string
+	for _, service := range a.Services {
+		if service.Finding != "" {
+			findings = append(findings, service.Name+": "+service.Finding)
+		}
+	}
+	for _, value := range a.Registry {
+		if value.Finding != "" {
+			findings = append(findings, value.Name+": "+value.Finding)
+		}
+	}
+	return findings
+}
+
+// serviceState returns the short name of a MS-SCMR service state.
+func serviceState(state uint32) string {
+	name, found := msscmr.ServiceStatusMap[state]
+	if !found {
+		return fmt.Sprintf("0x%x", state)
+	}
+	return strings.ToLower(strings.TrimPrefix(name, "SERVICE_"))
+}
+
+// serviceStartType returns the short name of a MS-SCMR start type, go-smb
+// spells the auto start type SERIVCE_AUTO_START.
+func serviceStartType(startType string) string {
+	if startType == msscmr.StartTypeStatusMap[msscmr.ServiceAutoStart] {
+		return "auto"
+	}
+	return strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(startType, "SERVICE_"), "_START"))
+}
+
+// registryValueString formats a registry value for the output.
+func registryValueString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case uint32:
+		return fmt.Sprintf("%d", v)
+	case uint64:
+		return fmt.Sprintf("%d", v)
+	case []string:
+		return strings.Join(nonEmpty(v), ",")
+	case []byte:
+		return fmt.Sprintf("%x", v)
+	default:
+		return ""
+	}
+}
+
+// nonEmpty returns the non-empty strings of values, REG_MULTI_SZ values end
+// with an empty string.
+func nonEmpty(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// auditRegistryValue returns the audit result of a value read from the
+// registry, a nil value means the value doesn't exist.
+func auditRegistryValue(audited auditedValue, value any) RegistryValue {
+	result := RegistryValue{Key: audited.Key, Name: audited.Name, Value: registryValueString(value), Set: value != nil}
+	if audited.finding != nil {
+		result.Finding = audited.finding(value, result.Set)
+	}
+	return result
+}
+
+// AuditHost queries the audited services over svcctl and reads the audited
+// registry values over winreg. It requires local admin rights on the host.
+func (conn *Connection) AuditHost() *AdminAudit {
+	result := &AdminAudit{}
+	share := "IPC$"
+	if err := conn.session.TreeConnect(share); err != nil {
+		result.ServiceError = err.Error()
+		result.RegistryError = err.Error()
+		return result
+	}
+	defer conn.session.TreeDisconnect(share)
+
+	if err := conn.auditServices(share, result); err != nil {
+		result.ServiceError = err.Error()
+	}
+	if err := conn.auditRegistry(share, result); err != nil {
+		result.RegistryError = err.Error()
+	}
+	return result
+}
+
+func (conn *Connection) auditServices(share string, result *AdminAudit) error {
+	f, err := conn.session.OpenFile(share, msscmr.MSRPCSvcCtlPipe)
+	if err != nil {
+		return err
+	}
+	defer f.CloseFile()
+
+	transport, err := smbtransport.NewSMBTransport(f)
+	if err != nil {
+		return err
+	}
+	bind, err := dcerpc.Bind(transport, msscmr.MSRPCUuidSvcCtl, 2, 0, dcerpc.MSRPCUuidNdr)
+	if err != nil {
+		return err
+	}
+
+	rpccon := msscmr.NewRPCCon(bind)
+	for _, service := range auditedServices {
+		config, err := rpccon.GetServiceConfig(service.Name)
+		if errors.Is(err, msscmr.ServiceResponseCodeMap[msscmr.ErrorServiceDoesNotExist]) {
+			result.Services = append(result.Services, ServiceState{Name: service.Name, State: ServiceNotInstalled})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		status, err := rpccon.GetServiceStatus(service.Name)
+		if err != nil {
+			return err
+		}
+		state := ServiceState{Name: service.Name, State: serviceState(status), StartType: serviceStartType(config.StartType)}
+		if status == msscmr.ServiceRunning {
+			state.Finding = service.Finding
+		}
+		result.Services = append(result.Services, state)
+	}
+	return nil
+}
+
+func (conn *Connection) auditRegistry(share string, result *AdminAudit) error {
+	f, err := conn.session.OpenFile(share, msrrp.MSRRPPipe)
+	if err != nil {
+		return err
+	}
+	defer f.CloseFile()
+
+	transport, err := smbtransport.NewSMBTransport(f)
+	if err != nil {
+		return err
+	}
+	bind, err := dcerpc.Bind(transport, msrrp.MSRRPUuid, msrrp.MSRRPMajorVersion, msrrp.MSRRPMinorVersion, dcerpc.MSRPCUuidNdr)
+	if err != nil {
+		return err
+	}
+
+	rpccon := msrrp.NewRPCCon(bind)
+	hklm, err := rpccon.OpenBaseKey(msrrp.HKEYLocalMachine)
+	if err != nil {
+		return err
+	}
+	defer rpccon.CloseKeyHandle(hklm)
+
+	keys := make(map[string][]byte)
+	defer func() {
+		for _, key := range keys {
+			rpccon.CloseKeyHandle(key)
+		}
+	}()
+	var queryErrs []error
+	for _, audited := range auditedValues {
+		key, opened := keys[audited.Key]
+		if !opened {
+			key, err = rpccon.OpenSubKey(hklm, audited.Key)
+			if err != nil {
+				return err
+			}
+			keys[audited.Key] = key
+		}
+		value, err := queryRegistryValue(rpccon, key, audited.Name)
+		if err != nil && !isRegistryValueNotFound(err) {
+			// the value is left out rather than reported as not set
+			queryErrs = append(queryErrs, fmt.Errorf("%s: %w", audited.Name, err))
+			continue
+		}
+		result.Registry = append(result.Registry, auditRegistryValue(audited, value))
+	}
+	return errors.Join(queryErrs...)
+}
+
+// isRegistryValueNotFound reports whether err is the ERROR_FILE_NOT_FOUND of
+// querying a value that isn't set.
+func isRegistryValueNotFound(err error) bool {
+	return errors.Is(err, msrrp.ReturnCodeMap[msrrp.ErrorFileNotFound])
+}
+
+// queryRegistryValue reads a registry value like msrrp.RPCCon.QueryValueExt,
+// which replaces the ERROR_FILE_NOT_FOUND of a missing value with an error of
+// its own. The return code of the server is kept here instead.
+func queryRegistryValue(rpccon *msrrp.RPCCon, key []byte, name string) (any, error) {
+	name = msdtyp.NullTerminate(name)
+	req := msrrp.BaseRegQueryValueReq{
+		HKey:      key,
+		ValueName: msrrp.RRPUnicodeStr{MaxLength: uint16(len(name)), S: name},
+		Type:      1024,
+		MaxLen:    1024,
+	}
+	res, err := requestRegistryValue(rpccon, &req)
+	if err == nil && res.ReturnCode == msrrp.ErrorMoreData {
+		// retry with the size of the value returned by the server
+		req.MaxLen = res.DataLen
+		res, err = requestRegistryValue(rpccon, &req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.ReturnCode != msrrp.ErrorSuccess {
+		if err, ok := msrrp.ReturnCodeMap[res.ReturnCode]; ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("return code 0x%08x", res.ReturnCode)
+	}
+	return registryData(res.Type, res.Data)
+}
+
+func requestRegistryValue(rpccon *msrrp.RPCCon, req *msrrp.BaseRegQueryValueReq) (*msrrp.BaseRegQueryValueRes, error) {
+	reqBuf, err := req.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buffer, err := rpccon.MakeRequest(msrrp.BaseRegQueryValue, reqBuf)
+	if err != nil {
+		return nil, err
+	}
+	res := &msrrp.BaseRegQueryValueRes{}
+	if err = res.UnmarshalBinary(buffer); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// registryData converts the data of a registry value to the Go type of its
+// registry type, as msrrp.RPCCon.QueryValueExt does.
+func registryData(dataType uint32, data []byte) (any, error) {
+	switch dataType {
+	case msrrp.RegSz, msrrp.RegExpandSz:
+		value, err := msdtyp.FromUnicodeString(data)
+		if err != nil {
+			return nil, err
+		}
+		return strings.TrimRight(value, "\x00"), nil
+	case msrrp.RegMultiSz:
+		value, err := msdtyp.FromUnicodeString(data)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(value, "\x00"), nil
+	case msrrp.RegDword:
+		if len(data) != 4 {
+			return nil, fmt.Errorf("invalid length %d of DWORD registry value", len(data))
+		}
+		return binary.LittleEndian.Uint32(data), nil
+	case msrrp.RegDwordBigEndian:
+		if len(data) != 4 {
+			return nil, fmt.Errorf("invalid length %d of DWORD registry value", len(data))
+		}
+		return binary.BigEndian.Uint32(data), nil
+	case msrrp.RegQword:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("invalid length %d of QWORD registry value", len(data))
+		}
+		return binary.LittleEndian.Uint64(data), nil
+	default:
+		return data, nil
+	}
+}
@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/jfjallid/go-smb/dcerpc/msrrp"
+	"github.com/jfjallid/go-smb/dcerpc/msscmr"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestServiceStateNames(t *testing.T) {
+	if got := serviceState(msscmr.ServiceRunning); got != "running" {
+		t.Errorf("expected running, got %s", got)
+	}
+	if got := serviceState(msscmr.ServiceStartPending); got != "start_pending" {
+		t.Errorf("expected start_pending, got %s", got)
+	}
+	tests := map[string]string{
+		msscmr.StartTypeStatusMap[msscmr.ServiceAutoStart]:   "auto",
+		msscmr.StartTypeStatusMap[msscmr.ServiceDemandStart]: "demand",
+		msscmr.StartTypeStatusMap[msscmr.ServiceDisabled]:    "disabled",
+		msscmr.StartTypeStatusMap[msscmr.ServiceBootStart]:   "boot",
+	}
+	for startType, expected := range tests {
+		if got := serviceStartType(startType); got != expected {
+			t.Errorf("%s: expected %s, got %s", startType, expected, got)
+		}
+	}
+}
+
+func TestAuditRegistryValue(t *testing.T) {
+	values := make(map[string]auditedValue)
+	for _, audited := range auditedValues {
+		values[audited.Key+`\`+audited.Name] = audited
+	}
+	lmCompatibility := values[lsaKey+`\LmCompatibilityLevel`]
+	serverSigning := values[lanmanServerKey+`\RequireSecuritySignature`]
+	nullShares := values[lanmanServerKey+`\NullSessionShares`]
+	nullPipes := values[lanmanServerKey+`\NullSessionPipes`]
+
+	tests := []struct {
+		audited  auditedValue
+		value    any
+		expected string
+		weak     bool
+	}{
+		{lmCompatibility, uint32(1), "1", true},
+		{lmCompatibility, uint32(5), "5", false},
+		// Windows defaults to NTLMv2 only responses
+		{lmCompatibility, nil, "", false},
+		{serverSigning, uint32(0), "0", true},
+		{serverSigning, nil, "", true},
+		{serverSigning, uint32(1), "1", false},
+		{nullShares, []string{"Public", "Scans", ""}, "Public,Scans", true},
+		{nullShares, []string{""}, "", false},
+		{nullPipes, []string{"netlogon", "samr", ""}, "netlogon,samr", false},
+	}
+	for _, test := range tests {
+		result := auditRegistryValue(test.audited, test.value)
+		if result.Value != test.expected || result.Set != (test.value != nil) || (result.Finding != "") != test.weak {
+			t.Errorf("%s %v: unexpected result %+v", test.audited.Name, test.value, result)
+		}
+	}
+}
+
+func TestIsRegistryValueNotFound(t *testing.T) {
+	if !isRegistryValueNotFound(msrrp.ReturnCodeMap[msrrp.ErrorFileNotFound]) {
+		t.Error("expected a missing value")
+	}
+	if !isRegistryValueNotFound(fmt.Errorf("query: %w", msrrp.ReturnCodeMap[msrrp.ErrorFileNotFound])) {
+		t.Error("expected ERROR_FILE_NOT_FOUND to be a missing value")
+	}
+	if isRegistryValueNotFound(msrrp.ReturnCodeMap[msrrp.ErrorAccessDenied]) {
+		t.Error("access denied must not be reported as a missing value")
+	}
+}
+
+func TestRegistryData(t *testing.T) {
+	utf16le := func(s string) []byte {
+		var data []byte
+		for _, char := range utf16.Encode([]rune(s)) {
+			data = binary.LittleEndian.AppendUint16(data, char)
+		}
+		return data
+	}
+	tests := []struct {
+		dataType uint32
+		data     []byte
+		expected string
+	}{
+		{msrrp.RegSz, utf16le("NTLM\x00"), "NTLM"},
+		{msrrp.RegMultiSz, utf16le("srvsvc\x00lsarpc\x00\x00"), "srvsvc,lsarpc"},
+		{msrrp.RegDword, []byte{1, 0, 0, 0}, "1"},
+		{msrrp.RegQword, []byte{2, 0, 0, 0, 0, 0, 0, 0}, "2"},
+		{msrrp.RegBinary, []byte{0xca, 0xfe}, "cafe"},
+	}
+	for _, test := range tests {
+		value, err := registryData(test.dataType, test.data)
+		if err != nil {
+			t.Fatalf("type %d: %v", test.dataType, err)
+		}
+		if got := registryValueString(value); got != test.expected {
+			t.Errorf("type %d: expected %q, got %q", test.dataType, test.expected, got)
+		}
+	}
+	if _, err := registryData(msrrp.RegDword, []byte{1}); err == nil {
+		t.Error("expected an error for a truncated DWORD")
+	}
+}
+
+func TestAdminAuditFindings(t *testing.T) {
+	audit := &AdminAudit{
+		Services: []ServiceState{
+			{Name: "Spooler", State: "running", StartType: "auto", Finding: "print spooler allows PrinterBug coercion"},
+			{Name: "WebClient", State: ServiceNotInstalled},
+		},
+		Registry: []RegistryValue{
+			{Key: lsaKey, Name: "LmCompatibilityLevel", Value: "1", Set: true, Finding: "LM and NTLMv1 responses are sent"},
+			{Key: lanmanServerKey, Name: "RestrictNullSessAccess", Value: "1", Set: true},
+		},
+	}
+	if findings := audit.Findings(); len(findings) != 2 || !strings.HasPrefix(findings[1], "LmCompatibilityLevel:") {
+		t.Errorf("unexpected findings %v", findings)
+	}
+
+	output := SprintAudit(audit)
+	if !strings.Contains(output, `HKLM\SYSTEM\CurrentControlSet\Control\Lsa\LmCompatibilityLevel`) || !strings.Contains(output, ServiceNotInstalled) {
+		t.Errorf("unexpected output %q", output)
+	}
+	if SprintAudit(nil) != "" {
+		t.Error("expected no output without audit")
+	}
+
+	run := SharefinderRun{Hosts: []Host{{IP: "10.0.0.1", Audit: audit}, {IP: "10.0.0.2", Audit: &AdminAudit{}}, {IP: "10.0.0.3"}}}
+	if len(run.AuditHosts()) != 2 || run.AuditFindingHostCount() != 1 {
+		t.Errorf("unexpected audit hosts %d and finding hosts %d", len(run.AuditHosts()), run.AuditFindingHostCount())
+	}
+}
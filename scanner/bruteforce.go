@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"github.com/jfjallid/go-smb/smb"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ShareDiscoveryBruteforce marks shares found by connecting to names from the
+// share wordlist instead of listing them via srvsvc
+const ShareDiscoveryBruteforce = "bruteforce"
+
+// defaultShareNames are common share names tried when brute-forcing shares,
+// including hidden administrative and deployment shares
+var defaultShareNames = []string{
+	"ADMIN$", "C$", "D$", "E$", "F$", "IPC$", "print$",
+	"NETLOGON", "SYSVOL", "CertEnroll", "REMINST", "SCCMContentLib$", "SMS_DP$", "SMSPKGC$", "SMSSIG$", "WsusContent", "UpdateServicesPackages",
+	"Users", "Users$", "Home", "Home$", "Homes", "HomeDirs", "Profiles", "Profiles$", "RedirectedFolders", "Desktop$",
+	"Public", "Share", "Shared", "Shares", "Common", "Data", "Data$", "Files", "Documents", "Docs", "Transfer", "Exchange", "Scans", "Scan",
+	"Finance", "Accounting", "HR", "IT", "IT$", "Legal", "Sales", "Marketing", "Management", "Projects", "Departments",
+	"Backup", "Backup$", "Backups", "Veeam", "Archive", "Archives",
+	"Software", "Software$", "Install", "Installs", "Deploy", "Deploy$", "DeploymentShare$", "Distribution", "Apps", "Applications", "Tools", "Scripts", "Scripts$", "Setup", "ISO", "Drivers",
+	"Temp", "Tmp", "Logs", "Reports", "SQL", "SQLBackup", "Database", "Dev", "Test", "Web", "wwwroot", "inetpub", "Media", "Printers",
+}
+
+// ParseShareWordlist reads one share name per line. Empty lines and lines
+// starting with # are skipped.
+func ParseShareWordlist(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, scanner.Err()
+}
+
+// ShareWordlist returns the built-in share names followed by the names of the
+// wordlist file at path, without duplicates.
+func ShareWordlist(path string) ([]string, error) {
+	names := slices.Clone(defaultShareNames)
+	if path == "" {
+		return names, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	extra, err := ParseShareWordlist(file)
+	if err != nil {
+		return nil, err
+	}
+	return mergeShareNames(names, extra), nil
+}
+
+// mergeShareNames appends the names of extra missing from names, share names
+// are case-insensitive.
+func mergeShareNames(names, extra []string) []string {
+	for _, name := range extra {
+		if !slices.ContainsFunc(names, func(known string) bool { return strings.EqualFold(known, name) }) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// BruteforceShares tries to connect to every name of wordlist that isn't in
+// known and returns the shares that exist. Shares that deny the connection
+// exist as well and are returned.
+func (conn *Connection) BruteforceShares(wordlist, known []string) []string {
+	var found []string
+	for _, name := range wordlist {
+		if slices.ContainsFunc(known, func(knownName string) bool { return strings.EqualFold(knownName, name) }) {
+			continue
+		}
+		err := conn.session.TreeConnect(name)
+		if err == nil {
+			_ = conn.session.TreeDisconnect(name)
+			found = append(found, name)
+			continue
+		}
+		if errors.Is(err, smb.StatusMap[smb.StatusAccessDenied]) {
+			found = append(found, name)
+		}
+	}
+	return found
+}
@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseShareWordlist(t *testing.T) {
+	names, err := ParseShareWordlist(strings.NewReader("# departments\nFinance\r\n\n  Payroll$ \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "Finance" || names[1] != "Payroll$" {
+		t.Errorf("unexpected names %q", names)
+	}
+}
+
+func TestShareWordlist(t *testing.T) {
+	names, err := ShareWordlist("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(defaultShareNames) {
+		t.Errorf("expected the built-in names, got %d names", len(names))
+	}
+
+	path := filepath.Join(t.TempDir(), "shares.txt")
+	if err := os.WriteFile(path, []byte("finance\nPayroll$\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	names, err = ShareWordlist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Finance is built in, share names are case-insensitive
+	if len(names) != len(defaultShareNames)+1 || names[len(names)-1] != "Payroll$" {
+		t.Errorf("unexpected names %q", names[len(defaultShareNames):])
+	}
+
+	if _, err := ShareWordlist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing wordlist")
+	}
+}
+
+func TestSprintHost_Bruteforce(t *testing.T) {
+	h := Host{
+		IP: "10.0.0.1",
+		Shares: []Share{
+			{ShareName: "Data", Type: "disk", ReadPermission: true},
+			{ShareName: "Finance", Discovery: ShareDiscoveryBruteforce, ReadPermission: true},
+		},
+	}
+	result := SprintHost(h, nil)
+	if !strings.Contains(result, "Finance          bruteforce") {
+		t.Errorf("expected the brute-forced share to be labelled:\n%s", result)
+	}
+}
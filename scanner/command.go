@@ -4,10 +4,13 @@ import "strings"
 
 // credentialFlags lists CLI flags whose value must never reach reports.
 var credentialFlags = map[string]struct{}{
-	"-p":         {},
-	"--password": {},
-	"-H":         {},
-	"--hashes":   {},
+	"-p":                 {},
+	"--password":         {},
+	"-H":                 {},
+	"--hashes":           {},
+	"--aes-key":          {},
+	"--compare-password": {},
+	"--compare-hashes":   {},
 }
 
 const maskedCredential = "***"
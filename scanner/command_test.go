@@ -21,6 +21,11 @@ func TestMaskCredentials(t *testing.T) {
 			in:   []string{"auth", "-u", "north\\hodor", "-p", "hodor", "1.2.3.4"},
 			want: []string{"auth", "-u", "north\\hodor", "-p", "***", "1.2.3.4"},
 		},
+		{
+			name: "aes key flag",
+			in:   []string{"auth", "-k", "--aes-key=00112233445566778899aabbccddeeff", "1.2.3.4"},
+			want: []string{"auth", "-k", "--aes-key=***", "1.2.3.4"},
+		},
 		{
 			name: "long password flag",
 			in:   []string{"hunt", "-u", "u", "--password", "secret", "1.2.3.4"},
@@ -41,6 +46,26 @@ func TestMaskCredentials(t *testing.T) {
 			in:   []string{"auth", "--hashes=deadbeef", "1.2.3.4"},
 			want: []string{"auth", "--hashes=***", "1.2.3.4"},
 		},
+		{
+			name: "compare password flag",
+			in:   []string{"auth", "--compare-user", "north\\arya", "--compare-password", "needle", "1.2.3.4"},
+			want: []string{"auth", "--compare-user", "north\\arya", "--compare-password", "***", "1.2.3.4"},
+		},
+		{
+			name: "compare password with equals",
+			in:   []string{"hunt", "--compare-password=needle", "1.2.3.4"},
+			want: []string{"hunt", "--compare-password=***", "1.2.3.4"},
+		},
+		{
+			name: "compare hash flag",
+			in:   []string{"hunt", "--compare-hashes", "deadbeef", "1.2.3.4"},
+			want: []string{"hunt", "--compare-hashes", "***", "1.2.3.4"},
+		},
+		{
+			name: "compare hash with equals",
+			in:   []string{"auth", "--compare-hashes=deadbeef", "1.2.3.4"},
+			want: []string{"auth", "--compare-hashes=***", "1.2.3.4"},
+		},
 		{
 			name: "value containing equals is unaffected",
 			in:   []string{"hunt", "--username=DOMAIN\\u=ser", "-p", "x"},
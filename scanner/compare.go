@@ -0,0 +1,197 @@
+package scanner
+
+import (
+	"github.com/vflame6/sharefinder/logger"
+	"slices"
+	"strings"
+)
+
+// Comparison is the difference between the access of the scan account and the
+// --compare-user account to the shares of a host
+type Comparison struct {
+	Baseline    string             `xml:"baseline,attr"`
+	Compare     string             `xml:"compare,attr"`
+	Error       string             `xml:"error,attr,omitempty"` // the compare account couldn't log on
+	Differences []AccessDifference `xml:"difference"`
+}
+
+// AccessDifference is a share, or a file of a share both accounts can read,
+// the accounts have different access to. Access is RW, R, W, - or n/a as in
+// the access matrix.
+type AccessDifference struct {
+	Share    string `xml:"share,attr"`
+	Path     string `xml:"path,attr,omitempty"` // empty for the permissions of the share
+	Baseline string `xml:"baseline,attr"`
+	Compare  string `xml:"compare,attr"`
+}
+
+// filePaths returns the paths of the files and directories listed in a share.
+func filePaths(share Share) []string {
+	var paths []string
+	add := func(file File) {
+		path := file.Name
+		if file.Parent != "" {
+			path = file.Parent + `\` + file.Name
+		}
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	for _, file := range share.Files {
+		add(file)
+	}
+	for _, directory := range share.Directories {
+		for _, file := range directory.Files {
+			add(file)
+		}
+	}
+	return paths
+}
+
+// identityShare returns the share of identity with the name, false if the
+// identity didn't find it.
+func (h Host) identityShare(identity, name string) (Share, bool) {
+	for _, share := range h.Shares {
+		if share.Credential == identity && strings.EqualFold(share.ShareName, name) {
+			return share, true
+		}
+	}
+	return Share{}, false
+}
+
+// AccessDifferences returns the shares the identities baseline and compare
+// have different access to, followed by the files listed in shares both can
+// read that only one of them sees.
+func (h Host) AccessDifferences(baseline, compare string) []AccessDifference {
+	var differences []AccessDifference
+	for _, row := range h.AccessMatrix([]string{baseline, compare}) {
+		baselineAccess, compareAccess := row.Cells[0], row.Cells[1]
+		if baselineAccess.String() != compareAccess.String() {
+			differences = append(differences, AccessDifference{Share: row.Share, Baseline: baselineAccess.String(), Compare: compareAccess.String()})
+		}
+		if !baselineAccess.Read || !compareAccess.Read {
+			continue
+		}
+
+		baselineShare, _ := h.identityShare(baseline, row.Share)
+		compareShare, _ := h.identityShare(compare, row.Share)
+		baselinePaths, comparePaths := filePaths(baselineShare), filePaths(compareShare)
+		for _, path := range baselinePaths {
+			if !slices.Contains(comparePaths, path) {
+				differences = append(differences, AccessDifference{Share: row.Share, Path: path, Baseline: "R", Compare: "-"})
+			}
+		}
+		for _, path := range comparePaths {
+			if !slices.Contains(baselinePaths, path) {
+				differences = append(differences, AccessDifference{Share: row.Share, Path: path, Baseline: "-", Compare: "R"})
+			}
+		}
+	}
+	return differences
+}
+
+// enumerateHostCompared enumerates the host with the scan account and the
+// --compare-user account, merges the results like enumerateHostWithCredentials
+// and records the differences of their access. Only the shares with
+// differences are kept, unless the compare account couldn't log on. The host
+// is fingerprinted once and the compare account reuses the transport of the
+// scan account.
+func enumerateHostCompared(host DNHost, options *Options) (Host, error) {
+	var merged Host
+	var results []CredentialResult
+	compare := options.CompareCredential
+	baseline := (&Credential{Domain: options.Domain, Username: options.Username}).String()
+	comparison := &Comparison{Baseline: baseline, Compare: compare.String()}
+
+	hostResult, err := enumerateHost(host, options)
+	result := CredentialResult{Credential: baseline, Success: hostResult.IP != ""}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if hostResult.IP != "" {
+		result.Admin, result.Session = hostResult.Admin, hostResult.Session
+		merged.merge(hostResult, baseline)
+	} else if !isNTStatus(err) {
+		// the host is unreachable, the compare account would fail the same way
+		return hostResult, err
+	}
+	results = append(results, result)
+
+	if options.CredentialTracker.Acquire(compare) {
+		compareOptions := *options
+		target := host
+		target.Credential = compare
+		if merged.IP != "" {
+			compareOptions.Fingerprint = false
+			target = pinTransport(target, merged.Transport, options)
+		}
+		hostResult, compareErr := enumerateHost(target, &compareOptions)
+		var authErr error
+		if hostResult.IP == "" {
+			authErr = compareErr
+		}
+		if reason, stopped := options.CredentialTracker.Record(compare, authErr); stopped {
+			logger.Warnf("Stopped using %s: %s", compare, reason)
+		}
+
+		result := CredentialResult{Credential: comparison.Compare, Success: hostResult.IP != ""}
+		if compareErr != nil {
+			result.Error = compareErr.Error()
+		}
+		if hostResult.IP != "" {
+			result.Admin, result.Session = hostResult.Admin, hostResult.Session
+			merged.merge(hostResult, comparison.Compare)
+		} else {
+			comparison.Error = compareErr.Error()
+		}
+		results = append(results, result)
+	} else {
+		comparison.Error = "stopped after failed logons"
+	}
+
+	if merged.IP == "" {
+		return merged, err
+	}
+	merged.Credentials = results
+	if comparison.Error == "" {
+		comparison.Differences = merged.AccessDifferences(comparison.Baseline, comparison.Compare)
+		// the report only keeps the shares the accounts have different access to
+		merged.Shares = differingShares(merged.Shares, comparison.Differences)
+	}
+	merged.Comparison = comparison
+	return merged, nil
+}
+
+// differingShares returns the shares, of either account, with a difference.
+func differingShares(shares []Share, differences []AccessDifference) []Share {
+	var result []Share
+	for _, share := range shares {
+		if slices.ContainsFunc(differences, func(difference AccessDifference) bool {
+			return strings.EqualFold(difference.Share, share.ShareName)
+		}) {
+			result = append(result, share)
+		}
+	}
+	return result
+}
+
+// ComparedHosts returns hosts enumerated with --compare-user.
+func (r *SharefinderRun) ComparedHosts() []Host {
+	var hosts []Host
+	for _, h := range r.Hosts {
+		if h.Comparison != nil {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// AccessDifferenceCount returns the differences between the accounts on all
+// hosts.
+func (r *SharefinderRun) AccessDifferenceCount() int {
+	n := 0
+	for _, h := range r.ComparedHosts() {
+		n += len(h.Comparison.Differences)
+	}
+	return n
+}
@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFilePaths(t *testing.T) {
+	share := Share{
+		Files: []File{{Type: "dir", Name: "IT"}, {Type: "file", Name: "readme.txt"}},
+		Directories: []Directory{
+			{Name: "IT", Files: []File{{Type: "file", Parent: "IT", Name: "passwords.kdbx"}}},
+			{Name: `IT\old`, Parent: "IT", Files: []File{{Type: "file", Parent: `IT\old`, Name: "backup.zip"}, {Type: "file", Parent: "IT", Name: "passwords.kdbx"}}},
+		},
+	}
+	want := []string{"IT", "readme.txt", `IT\passwords.kdbx`, `IT\old\backup.zip`}
+	if got := filePaths(share); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAccessDifferences(t *testing.T) {
+	host := Host{
+		IP: "10.0.0.1",
+		Credentials: []CredentialResult{
+			{Credential: `CORP\admin`, Success: true},
+			{Credential: `CORP\intern`, Success: true},
+		},
+		Shares: []Share{
+			{ShareName: "Public", ReadPermission: true, Credential: `CORP\admin`, Files: []File{{Name: "a.txt"}}},
+			{ShareName: "Finance", ReadPermission: true, Credential: `CORP\admin`, Files: []File{{Name: "salaries.xlsx"}}},
+			{ShareName: "Scans", ReadPermission: true, Credential: `CORP\admin`},
+			{ShareName: "Public", ReadPermission: true, Credential: `CORP\intern`, Files: []File{{Name: "a.txt"}, {Name: "b.txt"}}},
+			{ShareName: "Finance", Credential: `CORP\intern`},
+			{ShareName: "Scans", ReadPermission: true, WritePermission: true, Credential: `CORP\intern`},
+		},
+	}
+
+	want := []AccessDifference{
+		{Share: "Public", Path: "b.txt", Baseline: "-", Compare: "R"},
+		{Share: "Finance", Baseline: "R", Compare: "-"},
+		{Share: "Scans", Baseline: "R", Compare: "RW"},
+	}
+	differences := host.AccessDifferences(`CORP\admin`, `CORP\intern`)
+	if !slices.Equal(differences, want) {
+		t.Fatalf("expected %v, got %v", want, differences)
+	}
+
+	host.Shares = append(host.Shares, Share{ShareName: "Software", ReadPermission: true, Credential: `CORP\admin`})
+	shares := differingShares(host.Shares, differences)
+	if len(shares) != 6 || slices.ContainsFunc(shares, func(share Share) bool { return share.ShareName == "Software" }) {
+		t.Errorf("expected only the shares with differences of both accounts, got %v", shares)
+	}
+
+	host.Comparison = &Comparison{Baseline: `CORP\admin`, Compare: `CORP\intern`, Differences: differences}
+	run := SharefinderRun{Hosts: []Host{host, {IP: "10.0.0.2"}}}
+	if len(run.ComparedHosts()) != 1 || run.AccessDifferenceCount() != 3 {
+		t.Errorf("expected 1 compared host with 3 differences, got %d and %d", len(run.ComparedHosts()), run.AccessDifferenceCount())
+	}
+
+	text := SprintComparison(host.Comparison)
+	if !strings.Contains(text, "Finance") || !strings.Contains(text, "b.txt") || strings.Contains(text, "a.txt") {
+		t.Errorf("unexpected comparison:\n%s", text)
+	}
+}
+
+func TestAccessDifferencesFailedLogon(t *testing.T) {
+	// shares of the compare account are reported against a failed scan account
+	host := Host{
+		Credentials: []CredentialResult{{Credential: "a"}, {Credential: "b", Success: true}},
+		Shares:      []Share{{ShareName: "Data", Credential: "b"}},
+	}
+	want := []AccessDifference{{Share: "Data", Baseline: "n/a", Compare: "-"}}
+	if got := host.AccessDifferences("a", "b"); !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if text := SprintComparison(&Comparison{Compare: "b", Error: "logon failure"}); !strings.Contains(text, "logon failure") {
+		t.Errorf("expected the error of the compare account, got %q", text)
+	}
+	if text := SprintComparison(&Comparison{Baseline: "a", Compare: "b"}); !strings.Contains(text, "same access") {
+		t.Errorf("expected no differences, got %q", text)
+	}
+}
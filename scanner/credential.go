@@ -0,0 +1,395 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jfjallid/go-smb/smb"
+	"github.com/jfjallid/gokrb5/v8/iana/errorcode"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Credential is a credential used for a single host instead of the global
+// Options credentials. Its secrets must never be written to any output.
+type Credential struct {
+	Domain    string
+	Username  string
+	Password  string
+	Hash      []byte
+	LocalAuth bool
+	Source    string // where the credential came from, e.g. LAPS
+}
+
+// Sources of per-host credentials
+const (
+	CredentialSourceLegacyLAPS  = "legacy"    // ms-Mcs-AdmPwd
+	CredentialSourceWindowsLAPS = "windows"   // msLAPS-Password
+	CredentialSourceEncrypted   = "encrypted" // msLAPS-EncryptedPassword, not decrypted
+	CredentialSourceFile        = "file"      // --credentials
+	CredentialSourceCompare     = "compare"   // --compare-user
+)
+
+// Usable reports whether the credential can be used to authenticate
+func (c *Credential) Usable() bool {
+	return c != nil && c.Username != ""
+}
+
+// IsLAPS reports whether the credential is a LAPS password the host can be
+// authenticated with. Encrypted passwords aren't decrypted and aren't usable.
+func (c *Credential) IsLAPS() bool {
+	return c.Usable() && (c.Source == CredentialSourceLegacyLAPS || c.Source == CredentialSourceWindowsLAPS)
+}
+
+// String returns the account name of the credential without its secret
+func (c *Credential) String() string {
+	if c.Domain == "" {
+		return c.Username
+	}
+	return c.Domain + "\\" + c.Username
+}
+
+// LoadCredentials reads a credentials file, see ParseCredentials.
+func LoadCredentials(path, defaultDomain string, localAuth bool) ([]*Credential, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseCredentials(file, defaultDomain, localAuth)
+}
+
+// ParseCredentials reads one DOMAIN\user:secret or user:secret entry per line.
+// The secret is treated as an NT hash if it is 32 hex characters, optionally
+// prefixed with an LM hash as LM:NT, and as a password otherwise. Entries
+// without a domain use defaultDomain, or are local accounts if localAuth is
+// set. Empty lines and lines starting with # are skipped.
+func ParseCredentials(r io.Reader, defaultDomain string, localAuth bool) ([]*Credential, error) {
+	var credentials []*Credential
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(entry) == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		account, secret, found := strings.Cut(entry, ":")
+		if !found || account == "" {
+			return nil, fmt.Errorf("line %d: expected DOMAIN\\user:password or user:hash", line)
+		}
+
+		credential := &Credential{Source: CredentialSourceFile, LocalAuth: localAuth}
+		if domain, username, ok := strings.Cut(account, "\\"); ok && !localAuth {
+			credential.Domain = domain
+			credential.Username = username
+		} else {
+			credential.Username = account
+			if !localAuth {
+				credential.Domain = defaultDomain
+			}
+		}
+		if credential.Username == "" {
+			return nil, fmt.Errorf("line %d: empty username", line)
+		}
+
+		if hash, ok := parseNTHash(secret); ok {
+			credential.Hash = hash
+		} else {
+			credential.Password = secret
+		}
+		credentials = append(credentials, credential)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, errors.New("no credentials found")
+	}
+	return credentials, nil
+}
+
+// parseNTHash decodes an NT hash in NT or LM:NT form.
+func parseNTHash(secret string) ([]byte, bool) {
+	if lm, nt, ok := strings.Cut(secret, ":"); ok && len(lm) == 32 {
+		secret = nt
+	}
+	if len(secret) != 32 {
+		return nil, false
+	}
+	hash, err := hex.DecodeString(secret)
+	if err != nil {
+		return nil, false
+	}
+	return hash, true
+}
+
+// isLogonFailure reports whether err is a failed logon that counts towards the
+// account lockout threshold.
+func isLogonFailure(err error) bool {
+	return errors.Is(err, smb.StatusMap[smb.StatusLogonFailure])
+}
+
+// isAccountLockedOut reports whether err is STATUS_ACCOUNT_LOCKED_OUT.
+func isAccountLockedOut(err error) bool {
+	return errors.Is(err, smb.StatusMap[smb.StatusAccountLockedOut])
+}
+
+// isNTStatus reports whether err is an NT status returned by the server, as
+// opposed to a network error, so other accounts are worth trying on the host.
+func isNTStatus(err error) bool {
+	for _, status := range smb.StatusMap {
+		if errors.Is(err, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// CredentialTracker counts failed logons per account and stops the use of an
+// account once it reaches the attempt cap or is reported as locked out.
+// Logons in flight count towards the cap until their result is recorded, so
+// concurrent workers never exceed it.
+type CredentialTracker struct {
+	mutex       sync.Mutex
+	released    *sync.Cond
+	maxFailures int
+	limits      map[string]int // caps set from the domain lockout policy
+	failures    map[string]int
+	inFlight    map[string]int
+	disabled    map[string]string
+}
+
+// NewCredentialTracker returns a tracker allowing maxFailures failed logons
+// per account, with 0 meaning no cap.
+func NewCredentialTracker(maxFailures int) *CredentialTracker {
+	t := &CredentialTracker{
+		maxFailures: maxFailures,
+		limits:      make(map[string]int),
+		failures:    make(map[string]int),
+		inFlight:    make(map[string]int),
+		disabled:    make(map[string]string),
+	}
+	t.released = sync.NewCond(&t.mutex)
+	return t
+}
+
+// SetPolicy caps the failed logons of the account below the lockout threshold
+// of the domain policy, keeping one logon as a safety margin for logons the
+// count doesn't include. The lower of this cap and maxFailures applies. If the
+// account is already close to lockout, it is stopped and the reason is
+// returned with stopped set.
+func (t *CredentialTracker) SetPolicy(c *Credential, policy LockoutPolicy, badPwdCount int) (reason string, stopped bool) {
+	if policy.Threshold == 0 {
+		return "", false
+	}
+	key := strings.ToLower(c.String())
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	defer t.released.Broadcast()
+	if _, disabled := t.disabled[key]; disabled {
+		return t.disabled[key], false
+	}
+
+	t.limits[key] = policy.Threshold - badPwdCount - 1
+	if t.failures[key] >= t.limits[key] {
+		t.disabled[key] = fmt.Sprintf("%d of %d failed logons before lockout", badPwdCount, policy.Threshold)
+		return t.disabled[key], true
+	}
+	return "", false
+}
+
+// limit returns the failed logon cap of the account, 0 meaning no cap.
+func (t *CredentialTracker) limit(key string) int {
+	limit, ok := t.limits[key]
+	if !ok || (t.maxFailures > 0 && t.maxFailures < limit) {
+		return t.maxFailures
+	}
+	return limit
+}
+
+// Acquire reserves a logon with the account and reports whether the account
+// can still be used. If every remaining attempt is in flight, Acquire waits
+// for one of them to be recorded. Every successful Acquire must be followed
+// by Record.
+func (t *CredentialTracker) Acquire(c *Credential) bool {
+	key := strings.ToLower(c.String())
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for {
+		if _, disabled := t.disabled[key]; disabled {
+			return false
+		}
+		if limit := t.limit(key); limit == 0 || t.failures[key]+t.inFlight[key] < limit {
+			t.inFlight[key]++
+			return true
+		}
+		t.released.Wait()
+	}
+}
+
+// Record releases the logon reserved by Acquire and updates the account state
+// with its result. Successful logons don't reset the failure count. If this
+// attempt made the account unusable, the reason is returned with stopped set.
+func (t *CredentialTracker) Record(c *Credential, err error) (reason string, stopped bool) {
+	key := strings.ToLower(c.String())
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	defer t.released.Broadcast()
+	if t.inFlight[key] > 0 {
+		t.inFlight[key]--
+	}
+	if _, disabled := t.disabled[key]; disabled {
+		return t.disabled[key], false
+	}
+
+	switch {
+	case isAccountLockedOut(err):
+		t.disabled[key] = "account is locked out"
+	case isLogonFailure(err):
+		t.failures[key]++
+		if limit := t.limit(key); limit > 0 && t.failures[key] >= limit {
+			t.disabled[key] = fmt.Sprintf("%d failed logons", t.failures[key])
+		}
+	}
+	reason, stopped = t.disabled[key]
+	return reason, stopped
+}
+
+// DefaultLockoutAllowance is the number of failed logons tolerated after a
+// successful one if the domain lockout policy could not be read.
+const DefaultLockoutAllowance = 3
+
+// isKerberosLogonFailure reports whether err is a KDC error for a wrong key or
+// a revoked, e.g. locked out, account.
+func isKerberosLogonFailure(err error) bool {
+	code, ok := kerberosErrorCode(err)
+	return ok && (code == errorcode.KDC_ERR_PREAUTH_FAILED || code == errorcode.KDC_ERR_CLIENT_REVOKED)
+}
+
+// LockoutGuard aborts the scan when the scan account is rejected, before
+// every worker adds a failed logon towards the domain lockout threshold. A
+// failed logon before any successful one means the credentials are wrong, so
+// the scan is aborted at once. Later failures may be host specific and are
+// tolerated up to the allowance. Logons in flight count towards the allowance
+// until their result is recorded.
+type LockoutGuard struct {
+	mutex     sync.Mutex
+	released  *sync.Cond
+	allowance int
+	failures  int
+	successes int
+	inFlight  int
+	err       error
+}
+
+// NewLockoutGuard returns a guard with the default allowance.
+func NewLockoutGuard() *LockoutGuard {
+	g := &LockoutGuard{allowance: DefaultLockoutAllowance}
+	g.released = sync.NewCond(&g.mutex)
+	return g
+}
+
+// SetPolicy sets the allowance from the domain lockout policy and the current
+// failed logon count of the account. One attempt is kept as a safety margin.
+// An error is returned if the account is already too close to lockout.
+func (g *LockoutGuard) SetPolicy(policy LockoutPolicy, badPwdCount int) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if policy.Threshold == 0 {
+		// accounts are never locked out
+		g.allowance = 0
+		return nil
+	}
+	g.allowance = policy.Threshold - badPwdCount - 1
+	if g.allowance < 1 {
+		return fmt.Errorf("account has %d of %d failed logons allowed by the lockout policy, wait %s for the count to reset", badPwdCount, policy.Threshold, policy.ObservationWindow)
+	}
+	return nil
+}
+
+// Authenticate runs login through the guard. Until the account has logged on
+// successfully once, logins are serialized, so wrong credentials are detected
+// by a single failed logon instead of one per worker. Afterwards a login
+// waits while the logons in flight could use up the allowance.
+func (g *LockoutGuard) Authenticate(login func() error) error {
+	if g == nil {
+		return login()
+	}
+	g.mutex.Lock()
+	for g.err == nil && !g.available() {
+		g.released.Wait()
+	}
+	if g.err != nil {
+		err := g.err
+		g.mutex.Unlock()
+		return err
+	}
+	g.inFlight++
+	g.mutex.Unlock()
+
+	err := login()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.inFlight--
+	g.record(err)
+	return err
+}
+
+// available reports whether another logon can be started. The caller must
+// hold the mutex.
+func (g *LockoutGuard) available() bool {
+	if g.successes == 0 {
+		return g.inFlight == 0
+	}
+	return g.allowance == 0 || g.failures+g.inFlight < g.allowance
+}
+
+// Record updates the guard with the result of an authentication with the scan
+// account and reports whether the scan was aborted by this attempt.
+func (g *LockoutGuard) Record(err error) bool {
+	if g == nil {
+		return false
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.record(err)
+}
+
+// record is Record with the mutex held. Logins waiting for the result are
+// woken up.
+func (g *LockoutGuard) record(err error) bool {
+	defer g.released.Broadcast()
+	if g.err != nil {
+		return false
+	}
+
+	switch {
+	case err == nil:
+		g.successes++
+	case isAccountLockedOut(err):
+		g.err = errors.New("aborted: the account is locked out")
+	case isLogonFailure(err) || isKerberosLogonFailure(err):
+		g.failures++
+		if g.successes == 0 {
+			g.err = fmt.Errorf("aborted: logon failure before any successful authentication, check the credentials: %w", err)
+		} else if g.allowance > 0 && g.failures >= g.allowance {
+			g.err = fmt.Errorf("aborted: %d failed logons, the account is close to the lockout threshold", g.failures)
+		}
+	}
+	return g.err != nil
+}
+
+// Err returns the reason the scan was aborted, or nil.
+func (g *LockoutGuard) Err() error {
+	if g == nil {
+		return nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.err
+}
@@ -0,0 +1,333 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jfjallid/go-smb/smb"
+)
+
+func TestParseCredentials(t *testing.T) {
+	input := strings.Join([]string{
+		"# accounts from the engagement",
+		"NORTH\\helpdesk:Summer2024!",
+		"",
+		"svc_backup:64f12cddaa88057e06a81b54e73b949b",
+		"essos\\daenerys:aad3b435b51404eeaad3b435b51404ee:31d6cfe0d16ae931b73c59d7e0c089c0",
+		"jon.snow:pass:with:colons",
+	}, "\n")
+
+	got, err := ParseCredentials(strings.NewReader(input), "north", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 credentials, got %d", len(got))
+	}
+
+	if got[0].String() != "NORTH\\helpdesk" || got[0].Password != "Summer2024!" || got[0].Hash != nil {
+		t.Errorf("unexpected password credential: %+v", got[0])
+	}
+	if got[1].String() != "north\\svc_backup" || got[1].Password != "" || len(got[1].Hash) != 16 {
+		t.Errorf("unexpected hash credential: %+v", got[1])
+	}
+	if got[2].String() != "essos\\daenerys" || !bytes.Equal(got[2].Hash[:2], []byte{0x31, 0xd6}) {
+		t.Errorf("unexpected LM:NT credential: %+v", got[2])
+	}
+	if got[3].Password != "pass:with:colons" {
+		t.Errorf("password with colons not preserved: %+v", got[3])
+	}
+	for _, c := range got {
+		if c.Source != CredentialSourceFile || c.LocalAuth {
+			t.Errorf("unexpected source or local auth: %+v", c)
+		}
+	}
+
+	local, err := ParseCredentials(strings.NewReader("administrator:Passw0rd\n"), "north", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !local[0].LocalAuth || local[0].Domain != "" {
+		t.Errorf("expected a local account: %+v", local[0])
+	}
+
+	for _, invalid := range []string{"no-separator\n", ":password\n", "# only comments\n"} {
+		if _, err := ParseCredentials(strings.NewReader(invalid), "", false); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestCredentialTracker(t *testing.T) {
+	logonFailure := smb.StatusMap[smb.StatusLogonFailure]
+	lockedOut := smb.StatusMap[smb.StatusAccountLockedOut]
+	helpdesk := &Credential{Domain: "NORTH", Username: "helpdesk"}
+	backup := &Credential{Domain: "NORTH", Username: "svc_backup"}
+
+	tracker := NewCredentialTracker(2)
+	attempt := func(c *Credential, err error) (string, bool) {
+		t.Helper()
+		if !tracker.Acquire(c) {
+			t.Fatalf("%s is no longer allowed", c)
+		}
+		return tracker.Record(c, err)
+	}
+	if _, stopped := attempt(helpdesk, logonFailure); stopped {
+		t.Fatal("account stopped before reaching the cap")
+	}
+	if _, stopped := attempt(helpdesk, nil); stopped {
+		t.Fatal("successful logon must not stop the account")
+	}
+	// a successful logon does not reset the count
+	if reason, stopped := attempt(helpdesk, logonFailure); !stopped || reason != "2 failed logons" {
+		t.Fatalf("expected the account to stop at the cap, got %q, %v", reason, stopped)
+	}
+	if tracker.Acquire(helpdesk) {
+		t.Fatal("stopped account is still allowed")
+	}
+	if _, stopped := tracker.Record(helpdesk, logonFailure); stopped {
+		t.Fatal("account must be reported as stopped only once")
+	}
+
+	// network errors do not count towards the cap
+	attempt(backup, errors.New("i/o timeout"))
+	attempt(backup, errors.New("i/o timeout"))
+	if reason, stopped := attempt(backup, lockedOut); !stopped || reason != "account is locked out" {
+		t.Fatalf("expected lockout to stop the account, got %q, %v", reason, stopped)
+	}
+
+	unlimited := NewCredentialTracker(0)
+	for i := 0; i < 10; i++ {
+		if !unlimited.Acquire(helpdesk) {
+			t.Fatal("account stopped without a cap")
+		}
+		unlimited.Record(helpdesk, logonFailure)
+	}
+}
+
+func TestCredentialTrackerPolicy(t *testing.T) {
+	logonFailure := smb.StatusMap[smb.StatusLogonFailure]
+	helpdesk := &Credential{Domain: "NORTH", Username: "helpdesk"}
+	backup := &Credential{Domain: "NORTH", Username: "svc_backup"}
+	audit := &Credential{Domain: "NORTH", Username: "audit"}
+	tracker := NewCredentialTracker(3)
+
+	// 5 - 2 - 1 leaves 2 failed logons, below --max-attempts
+	if _, stopped := tracker.SetPolicy(helpdesk, LockoutPolicy{Threshold: 5}, 2); stopped {
+		t.Fatal("account stopped with logons left before lockout")
+	}
+	for i := 0; i < 2; i++ {
+		if !tracker.Acquire(helpdesk) {
+			t.Fatalf("account stopped after %d failed logons", i)
+		}
+		tracker.Record(helpdesk, logonFailure)
+	}
+	if tracker.Acquire(helpdesk) {
+		t.Fatal("account is still allowed at the policy cap")
+	}
+
+	// a lenient policy doesn't raise --max-attempts
+	tracker.SetPolicy(backup, LockoutPolicy{Threshold: 50}, 0)
+	for i := 0; i < 3; i++ {
+		tracker.Acquire(backup)
+		tracker.Record(backup, logonFailure)
+	}
+	if tracker.Acquire(backup) {
+		t.Fatal("account is still allowed above --max-attempts")
+	}
+
+	if reason, stopped := tracker.SetPolicy(audit, LockoutPolicy{Threshold: 5}, 4); !stopped || reason != "4 of 5 failed logons before lockout" {
+		t.Fatalf("expected the account close to lockout to stop, got %q, %v", reason, stopped)
+	}
+	if tracker.Acquire(audit) {
+		t.Fatal("account close to lockout is still allowed")
+	}
+
+	unlimited := NewCredentialTracker(0)
+	if _, stopped := unlimited.SetPolicy(audit, LockoutPolicy{}, 4); stopped {
+		t.Fatal("account stopped without a lockout threshold")
+	}
+	unlimited.SetPolicy(helpdesk, LockoutPolicy{Threshold: 3}, 0)
+	for i := 0; i < 2; i++ {
+		unlimited.Acquire(helpdesk)
+		unlimited.Record(helpdesk, logonFailure)
+	}
+	if unlimited.Acquire(helpdesk) {
+		t.Fatal("policy cap doesn't apply without --max-attempts")
+	}
+}
+
+func TestCredentialTrackerConcurrent(t *testing.T) {
+	logonFailure := smb.StatusMap[smb.StatusLogonFailure]
+	helpdesk := &Credential{Domain: "NORTH", Username: "helpdesk"}
+	tracker := NewCredentialTracker(3)
+
+	var mutex sync.Mutex
+	inFlight, maxInFlight, attempts := 0, 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !tracker.Acquire(helpdesk) {
+				return
+			}
+			mutex.Lock()
+			attempts++
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mutex.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+			tracker.Record(helpdesk, logonFailure)
+		}()
+	}
+	wg.Wait()
+
+	if attempts != 3 {
+		t.Errorf("expected 3 failed logons, got %d", attempts)
+	}
+	if maxInFlight > 3 {
+		t.Errorf("expected at most 3 logons in flight, got %d", maxInFlight)
+	}
+}
+
+func TestLockoutGuard(t *testing.T) {
+	logonFailure := smb.StatusMap[smb.StatusLogonFailure]
+	lockedOut := smb.StatusMap[smb.StatusAccountLockedOut]
+
+	// wrong credentials are detected by the first failed logon
+	guard := NewLockoutGuard()
+	if !guard.Record(logonFailure) {
+		t.Fatal("logon failure before any success must abort")
+	}
+	if guard.Record(logonFailure) {
+		t.Fatal("abort must be reported only once")
+	}
+	if err := guard.Authenticate(func() error { t.Fatal("login after abort"); return nil }); err == nil {
+		t.Fatal("expected the abort error")
+	}
+
+	// host specific failures are tolerated up to the allowance
+	guard = NewLockoutGuard()
+	if err := guard.SetPolicy(LockoutPolicy{Threshold: 5}, 1); err != nil {
+		t.Fatal(err)
+	}
+	guard.Record(nil)
+	guard.Record(errors.New("i/o timeout"))
+	for i := 0; i < 2; i++ {
+		if guard.Record(logonFailure) {
+			t.Fatalf("aborted after %d failures, allowance is 3", i+1)
+		}
+	}
+	if !guard.Record(logonFailure) || guard.Err() == nil {
+		t.Fatal("expected abort at the allowance")
+	}
+
+	guard = NewLockoutGuard()
+	guard.Record(nil)
+	if !guard.Record(lockedOut) {
+		t.Fatal("locked out account must abort")
+	}
+
+	if err := NewLockoutGuard().SetPolicy(LockoutPolicy{Threshold: 3}, 2); err == nil {
+		t.Fatal("expected an error for an account close to lockout")
+	}
+
+	// accounts are never locked out without a threshold
+	guard = NewLockoutGuard()
+	if err := guard.SetPolicy(LockoutPolicy{}, 10); err != nil {
+		t.Fatal(err)
+	}
+	guard.Record(nil)
+	for i := 0; i < 10; i++ {
+		guard.Record(logonFailure)
+	}
+	if guard.Err() != nil {
+		t.Fatal("aborted without a lockout threshold")
+	}
+
+	var disabled *LockoutGuard
+	if disabled.Record(logonFailure) || disabled.Err() != nil {
+		t.Fatal("nil guard must not abort")
+	}
+}
+
+func TestLockoutGuardConcurrent(t *testing.T) {
+	logonFailure := smb.StatusMap[smb.StatusLogonFailure]
+	guard := NewLockoutGuard()
+	if err := guard.SetPolicy(LockoutPolicy{Threshold: 5}, 0); err != nil {
+		t.Fatal(err)
+	}
+	guard.Record(nil)
+
+	var mutex sync.Mutex
+	inFlight, maxInFlight, logons := 0, 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			guard.Authenticate(func() error {
+				mutex.Lock()
+				logons++
+				inFlight++
+				maxInFlight = max(maxInFlight, inFlight)
+				mutex.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mutex.Lock()
+				inFlight--
+				mutex.Unlock()
+				return logonFailure
+			})
+		}()
+	}
+	wg.Wait()
+
+	if logons != 4 {
+		t.Errorf("expected 4 failed logons, the allowance, got %d", logons)
+	}
+	if maxInFlight > 4 {
+		t.Errorf("expected at most 4 logons in flight, got %d", maxInFlight)
+	}
+	if guard.Err() == nil {
+		t.Error("expected the guard to abort")
+	}
+
+	// logons are serialized until the first success
+	guard = NewLockoutGuard()
+	inFlight, maxInFlight = 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			guard.Authenticate(func() error {
+				mutex.Lock()
+				inFlight++
+				maxInFlight = max(maxInFlight, inFlight)
+				mutex.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mutex.Lock()
+				inFlight--
+				mutex.Unlock()
+				return errors.New("i/o timeout")
+			})
+		}()
+	}
+	wg.Wait()
+	if maxInFlight != 1 {
+		t.Errorf("expected a single logon before the first success, got %d in flight", maxInFlight)
+	}
+}
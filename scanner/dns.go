@@ -4,17 +4,42 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"golang.org/x/net/proxy"
 )
 
 type Resolver struct {
-	resolver *net.Resolver
+	resolver dnsLookup
 }
 
-// NewResolver creates a DNS resolver that uses a specified protocol with optional proxy support
+// dnsLookup is the part of net.Resolver used to locate domain controllers
+type dnsLookup interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// ParseDomainControllerAddress returns the IP of a domain controller argument,
+// nil if it is a domain name. Arguments that look like an IP address but don't
+// parse, e.g. 10.0.0.300, are rejected instead of being looked up as a domain.
+func ParseDomainControllerAddress(dc string) (net.IP, error) {
+	if ip := net.ParseIP(dc); ip != nil {
+		return ip, nil
+	}
+	if strings.Contains(dc, ":") || strings.Trim(dc, "0123456789.") == "" {
+		return nil, fmt.Errorf("invalid domain controller address %s", dc)
+	}
+	return nil, nil
+}
+
+// NewResolver creates a DNS resolver that uses a specified protocol with optional proxy support.
+// If resolverIP is nil, the system resolver configuration is used instead.
 func NewResolver(protocol string, resolverIP net.IP, timeout time.Duration, proxyDialer proxy.Dialer) *Resolver {
+	if resolverIP == nil {
+		return &Resolver{resolver: &net.Resolver{PreferGo: true}}
+	}
+
 	r := &net.Resolver{
 		PreferGo: true,
 		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
@@ -46,3 +71,38 @@ func (r *Resolver) LookupHost(host string) (net.IP, error) {
 
 	return nil, fmt.Errorf("no valid IP found for host %s", host)
 }
+
+// LookupDomainControllers locates domain controllers of a domain via DNS SRV records:
+// _ldap._tcp.dc._msdcs.<domain>, or _gc._tcp.<domain> for Global Catalog servers.
+// Hosts are returned in SRV priority order, targets that do not resolve are skipped.
+func (r *Resolver) LookupDomainControllers(domain string, gc bool) ([]DNHost, error) {
+	name := "_ldap._tcp.dc._msdcs." + domain
+	if gc {
+		name = "_gc._tcp." + domain
+	}
+
+	// records are already sorted by priority and randomized by weight as per RFC 2782
+	_, records, err := r.resolver.LookupSRV(context.Background(), "", "", name)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []DNHost
+	for _, record := range records {
+		hostname := strings.TrimSuffix(record.Target, ".")
+		if hostname == "" {
+			continue
+		}
+		ip, err := r.LookupHost(hostname)
+		if err != nil {
+			continue
+		}
+		results = append(results, DNHost{Hostname: hostname, IP: ip})
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no domain controllers found in %s", name)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,188 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// stubDNS answers SRV and host lookups from maps, names missing from them
+// fail like NXDOMAIN
+type stubDNS struct {
+	srv   map[string][]*net.SRV
+	hosts map[string][]string
+}
+
+func (d stubDNS) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, found := d.hosts[host]; found {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (d stubDNS) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	if records, found := d.srv[name]; found {
+		return name, records, nil
+	}
+	return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+var testDNS = stubDNS{
+	srv: map[string][]*net.SRV{
+		"_ldap._tcp.dc._msdcs.corp.local": {
+			{Target: "dc01.corp.local.", Priority: 0},
+			{Target: "stale.corp.local.", Priority: 0},
+			{Target: "dc02.corp.local.", Priority: 10},
+		},
+		"_gc._tcp.corp.local": {{Target: "dc01.corp.local."}},
+	},
+	hosts: map[string][]string{
+		"dc01.corp.local": {"10.0.0.1"},
+		"dc02.corp.local": {"not an ip", "10.0.0.2"},
+	},
+}
+
+func hostnames(hosts []DNHost) []string {
+	var names []string
+	for _, host := range hosts {
+		names = append(names, host.Hostname+"="+host.IP.String())
+	}
+	return names
+}
+
+func TestParseDomainControllerAddress(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    net.IP
+		wantErr bool
+	}{
+		{input: "10.0.0.1", want: net.ParseIP("10.0.0.1")},
+		{input: "fe80::1", want: net.ParseIP("fe80::1")},
+		{input: "corp.local"},
+		{input: "corp-2.local"},
+		{input: "10.0.0.300", wantErr: true},
+		{input: "10.0.0", wantErr: true},
+		{input: "fe80::zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDomainControllerAddress(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected error %v", tt.input, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestLookupDomainControllers(t *testing.T) {
+	r := &Resolver{resolver: testDNS}
+
+	dcs, err := r.LookupDomainControllers("corp.local", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unresolvable targets are skipped, the SRV order is kept
+	if want := []string{"dc01.corp.local=10.0.0.1", "dc02.corp.local=10.0.0.2"}; !slices.Equal(hostnames(dcs), want) {
+		t.Errorf("expected %v, got %v", want, hostnames(dcs))
+	}
+
+	gcs, err := r.LookupDomainControllers("corp.local", true)
+	if err != nil || !slices.Equal(hostnames(gcs), []string{"dc01.corp.local=10.0.0.1"}) {
+		t.Errorf("unexpected Global Catalogs %v: %v", hostnames(gcs), err)
+	}
+
+	if _, err := r.LookupDomainControllers("other.local", false); err == nil {
+		t.Error("expected an error for a domain without SRV records")
+	}
+	empty := &Resolver{resolver: stubDNS{srv: map[string][]*net.SRV{"_ldap._tcp.dc._msdcs.corp.local": {{Target: "gone.corp.local."}}}}}
+	if _, err := empty.LookupDomainControllers("corp.local", false); err == nil {
+		t.Error("expected an error if no SRV target resolves")
+	}
+}
+
+func TestDiscoverDomainControllers(t *testing.T) {
+	var protocols []string
+	s := NewScanner(&Options{Timeout: time.Second}, nil, time.Now(), 1)
+	s.newResolver = func(protocol string, _ net.IP, _ time.Duration, _ proxy.Dialer) *Resolver {
+		protocols = append(protocols, protocol)
+		return &Resolver{resolver: testDNS}
+	}
+
+	if err := s.DiscoverDomainControllers("corp.local"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Options.DomainControllers) != 2 || len(s.Options.GlobalCatalogs) != 1 {
+		t.Errorf("expected 2 DCs and 1 GC, got %v and %v", hostnames(s.Options.DomainControllers), hostnames(s.Options.GlobalCatalogs))
+	}
+	if !slices.Equal(protocols, []string{"udp"}) {
+		t.Errorf("expected a single UDP resolver, got %v", protocols)
+	}
+
+	// a custom resolver is retried over TCP, e.g. for truncated answers
+	protocols = nil
+	s.Options.CustomResolver = net.ParseIP("10.0.0.53")
+	s.newResolver = func(protocol string, _ net.IP, _ time.Duration, _ proxy.Dialer) *Resolver {
+		protocols = append(protocols, protocol)
+		if protocol == "udp" {
+			return &Resolver{resolver: stubDNS{}}
+		}
+		return &Resolver{resolver: testDNS}
+	}
+	if err := s.DiscoverDomainControllers("corp.local"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(protocols, []string{"udp", "tcp"}) {
+		t.Errorf("expected a TCP retry, got %v", protocols)
+	}
+
+	if err := s.DiscoverDomainControllers("other.local"); err == nil {
+		t.Error("expected an error for a domain without domain controllers")
+	}
+
+	// DNS through the proxy requires a resolver reachable through it
+	s.Options.CustomResolver = nil
+	s.Options.ProxyDialer = proxy.Direct
+	if err := s.DiscoverDomainControllers("corp.local"); err == nil {
+		t.Error("expected an error for --proxy without --resolver")
+	}
+}
+
+func TestDialDomainControllers(t *testing.T) {
+	var dialed []string
+	s := NewScanner(&Options{DCHostname: "fallback"}, nil, time.Now(), 1)
+	s.dialLDAP = func(host net.IP, dcHostname string, _ bool) (*LDAPConnection, error) {
+		dialed = append(dialed, dcHostname+"="+host.String())
+		if host.Equal(net.ParseIP("10.0.0.1")) {
+			return nil, errors.New("connection refused")
+		}
+		return &LDAPConnection{}, nil
+	}
+	s.Options.DomainControllers = []DNHost{
+		{Hostname: "dc01.corp.local", IP: net.ParseIP("10.0.0.1")},
+		{IP: net.ParseIP("10.0.0.2")},
+		{Hostname: "dc03.corp.local", IP: net.ParseIP("10.0.0.3")},
+	}
+
+	// the first domain controller fails, the second is used with --dc-hostname
+	conn, err := s.connectDomainController()
+	if err != nil || conn == nil {
+		t.Fatalf("expected a connection, got %v", err)
+	}
+	if want := []string{"dc01.corp.local=10.0.0.1", "fallback=10.0.0.2"}; !slices.Equal(dialed, want) {
+		t.Errorf("expected %v, got %v", want, dialed)
+	}
+	if !s.Options.DomainController.Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("expected the connected domain controller to be used, got %v", s.Options.DomainController)
+	}
+
+	_, _, err = s.dialDomainControllers(s.Options.DomainControllers[:1], false)
+	if err == nil {
+		t.Error("expected an error if no domain controller accepts the connection")
+	}
+}
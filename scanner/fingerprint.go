@@ -0,0 +1,648 @@
+package scanner
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jfjallid/go-smb/gss"
+	"github.com/jfjallid/go-smb/smb"
+	"github.com/jfjallid/go-smb/spnego"
+	"golang.org/x/net/proxy"
+	"io"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Fingerprint is the SMB protocol configuration of a host, read from the
+// negotiate and session setup responses
+type Fingerprint struct {
+	Dialect             string     `xml:"dialect,attr"`
+	SMBv1               bool       `xml:"smbv1,attr"`
+	EncryptionSupported bool       `xml:"encryption_supported,attr"`
+	EncryptionRequired  *bool      `xml:"encryption_required,attr,omitempty"`
+	ServerGUID          string     `xml:"server_guid,attr,omitempty"`
+	ServerTime          *time.Time `xml:"server_time,attr,omitempty"`
+	BootTime            *time.Time `xml:"boot_time,attr,omitempty"`
+	NativeOS            string     `xml:"native_os,attr,omitempty"`
+	NativeLanMan        string     `xml:"native_lan_man,attr,omitempty"`
+	NTLM                *NTLMInfo  `xml:"ntlm,omitempty"`
+	// Signing is set when the server requires message signing
+	Signing bool `xml:"-"`
+}
+
+// NTLMInfo is the target info of the NTLM challenge of a host
+type NTLMInfo struct {
+	NetBIOSComputer string `xml:"netbios_computer,attr,omitempty"`
+	NetBIOSDomain   string `xml:"netbios_domain,attr,omitempty"`
+	DNSComputer     string `xml:"dns_computer,attr,omitempty"`
+	DNSDomain       string `xml:"dns_domain,attr,omitempty"`
+	DNSTree         string `xml:"dns_tree,attr,omitempty"`
+	OSVersion       string `xml:"os_version,attr,omitempty"`
+}
+
+// GuessedOSVersion returns the Windows version of the challenge in the format
+// of the NTLM target info of authenticated sessions.
+func (n *NTLMInfo) GuessedOSVersion() string {
+	var major, minor, build int
+	if _, err := fmt.Sscanf(n.OSVersion, "%d.%d.%d", &major, &minor, &build); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("Windows NT %d.%d Build %d", major, minor, build)
+}
+
+// Encryption returns the encryption state of the fingerprint for reports.
+func (f *Fingerprint) Encryption() string {
+	switch {
+	case f.EncryptionRequired != nil && *f.EncryptionRequired:
+		return "required"
+	case f.EncryptionSupported:
+		return "supported"
+	default:
+		return "unsupported"
+	}
+}
+
+const (
+	smb2CommandNegotiate    = 0x0000
+	smb2CommandSessionSetup = 0x0001
+
+	smb2NegotiateSigningEnabled  = 0x0001
+	smb2NegotiateSigningRequired = 0x0002
+	smb2GlobalCapEncryption      = 0x00000040
+
+	smb2SessionFlagIsGuest     = 0x0001
+	smb2SessionFlagIsNull      = 0x0002
+	smb2SessionFlagEncryptData = 0x0004
+
+	smb2PreauthIntegrityCapabilities = 0x0001
+	smb2EncryptionCapabilities       = 0x0002
+
+	ntStatusSuccess                = 0x00000000
+	ntStatusPending                = 0x00000103
+	ntStatusMoreProcessingRequired = 0xc0000016
+
+	// smb1NTLMDialect is the only dialect offered by the SMBv1 probe
+	smb1NTLMDialect = "NT LM 0.12"
+)
+
+// smb2Dialects are the dialects offered by the fingerprint negotiate request
+var smb2Dialects = []uint16{0x0202, 0x0210, 0x0300, 0x0302, 0x0311}
+
+// dialectName returns the version string of an SMB2 dialect revision.
+func dialectName(dialect uint16) string {
+	switch dialect {
+	case 0x0202:
+		return "2.0.2"
+	case 0x0210:
+		return "2.1"
+	case 0x0300:
+		return "3.0"
+	case 0x0302:
+		return "3.0.2"
+	case 0x0311:
+		return "3.1.1"
+	default:
+		return fmt.Sprintf("0x%04x", dialect)
+	}
+}
+
+// FingerprintHost negotiates with ip:port and returns its SMB fingerprint.
+// The session setup stops at the NTLM challenge unless initiator is set, in
+// which case the session is authenticated through guard to learn whether the
+// server requires encryption. SMBv1 support and the native OS and LAN manager
+// strings of SMBv1 servers are probed on a separate connection. If the
+// session setup fails after the SMB2 negotiation, the partial fingerprint is
+// returned with the error.
+func FingerprintHost(ip net.IP, port int, timeout time.Duration, proxyDialer proxy.Dialer, initiator *spnego.NTLMInitiator, guard *LockoutGuard) (*Fingerprint, error) {
+	fingerprint, err := fingerprintSMB2(ip, port, timeout, proxyDialer, initiator, guard)
+	smbv1, nativeOS, nativeLanMan := probeSMBv1(ip, port, timeout, proxyDialer)
+	if fingerprint == nil {
+		if !smbv1 {
+			return nil, err
+		}
+		// the host only speaks SMBv1
+		fingerprint, err = &Fingerprint{Dialect: smb1NTLMDialect}, nil
+	}
+	fingerprint.SMBv1 = smbv1
+	fingerprint.NativeOS, fingerprint.NativeLanMan = nativeOS, nativeLanMan
+	return fingerprint, err
+}
+
+// sessionSetupError returns the error of a session setup that failed with
+// status, using the NT status errors of go-smb so logon failures are
+// recognized.
+func sessionSetupError(status uint32) error {
+	if statusErr, ok := smb.StatusMap[status]; ok {
+		return fmt.Errorf("session setup: %w", statusErr)
+	}
+	return fmt.Errorf("session setup: unexpected status 0x%08x", status)
+}
+
+// fingerprintSMB2 reads the SMB2 negotiate response and the NTLM challenge of
+// ip:port.
+func fingerprintSMB2(ip net.IP, port int, timeout time.Duration, proxyDialer proxy.Dialer, initiator *spnego.NTLMInitiator, guard *LockoutGuard) (*Fingerprint, error) {
+	conn, err := dialTCP(ip, port, timeout, proxyDialer)
+	if err != nil {
+		return nil, err
+	}
+	probe := &smbProbe{conn: conn, timeout: timeout}
+	defer probe.Close()
+
+	request, err := probe.negotiateRequest()
+	if err != nil {
+		return nil, err
+	}
+	response, err := probe.roundTrip(request)
+	if err != nil {
+		return nil, fmt.Errorf("negotiate: %w", err)
+	}
+	fingerprint, err := parseNegotiateResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	if initiator == nil {
+		initiator = &spnego.NTLMInitiator{}
+	}
+	client, err := spnego.NewClient([]gss.Mechanism{initiator})
+	if err != nil {
+		return fingerprint, err
+	}
+	token, err := client.InitSecContext(nil)
+	if err != nil {
+		return fingerprint, err
+	}
+	response, err = probe.roundTrip(probe.sessionSetupRequest(token))
+	if err != nil {
+		return fingerprint, fmt.Errorf("session setup: %w", err)
+	}
+	status, _, securityBuffer, err := parseSessionSetupResponse(response)
+	if err != nil {
+		return fingerprint, err
+	}
+	if status != ntStatusMoreProcessingRequired {
+		return fingerprint, sessionSetupError(status)
+	}
+	probe.sessionID = binary.LittleEndian.Uint64(response[40:48])
+	fingerprint.NTLM, err = parseNTLMChallenge(securityBuffer)
+	if err != nil {
+		return fingerprint, err
+	}
+	if initiator.User == "" {
+		return fingerprint, nil
+	}
+
+	// authenticate to learn whether the server encrypts the session
+	token, err = client.InitSecContext(securityBuffer)
+	if err != nil {
+		return fingerprint, err
+	}
+	var sessionFlags uint16
+	err = guard.Authenticate(func() error {
+		response, err := probe.roundTrip(probe.sessionSetupRequest(token))
+		if err != nil {
+			return fmt.Errorf("session setup: %w", err)
+		}
+		status, sessionFlags, _, err = parseSessionSetupResponse(response)
+		if err != nil {
+			return err
+		}
+		if status != ntStatusSuccess {
+			return sessionSetupError(status)
+		}
+		return nil
+	})
+	if err != nil {
+		return fingerprint, err
+	}
+	// guest and anonymous sessions are never encrypted
+	if sessionFlags&(smb2SessionFlagIsGuest|smb2SessionFlagIsNull) == 0 {
+		required := sessionFlags&smb2SessionFlagEncryptData != 0
+		fingerprint.EncryptionRequired = &required
+	}
+	return fingerprint, nil
+}
+
+// probeSMBv1 reports whether ip:port accepts the NT LM 0.12 dialect of SMBv1.
+// If it does, the native OS and LAN manager strings of the SESSION_SETUP_ANDX
+// response to the first NTLM leg are returned as well.
+func probeSMBv1(ip net.IP, port int, timeout time.Duration, proxyDialer proxy.Dialer) (bool, string, string) {
+	conn, err := dialTCP(ip, port, timeout, proxyDialer)
+	if err != nil {
+		return false, "", ""
+	}
+	probe := &smbProbe{conn: conn, timeout: timeout}
+	defer probe.Close()
+
+	if err := probe.send(smb1NegotiateRequest()); err != nil {
+		return false, "", ""
+	}
+	response, err := probe.receive()
+	if err != nil || !smb1DialectAccepted(response) {
+		return false, "", ""
+	}
+
+	client, err := spnego.NewClient([]gss.Mechanism{&spnego.NTLMInitiator{}})
+	if err != nil {
+		return true, "", ""
+	}
+	token, err := client.InitSecContext(nil)
+	if err != nil {
+		return true, "", ""
+	}
+	if err := probe.send(smb1SessionSetupRequest(token)); err != nil {
+		return true, "", ""
+	}
+	response, err = probe.receive()
+	if err != nil {
+		return true, "", ""
+	}
+	nativeOS, nativeLanMan := parseSMB1SessionSetupResponse(response)
+	return true, nativeOS, nativeLanMan
+}
+
+// smbProbe is a raw SMB connection using the direct TCP transport
+type smbProbe struct {
+	conn      net.Conn
+	timeout   time.Duration
+	messageID uint64
+	sessionID uint64
+}
+
+func (p *smbProbe) Close() {
+	_ = p.conn.Close()
+}
+
+// send writes packet with its 4 byte transport header.
+func (p *smbProbe) send(packet []byte) error {
+	if err := p.conn.SetWriteDeadline(time.Now().Add(p.timeout)); err != nil {
+		return err
+	}
+	frame := make([]byte, 4, 4+len(packet))
+	binary.BigEndian.PutUint32(frame, uint32(len(packet)))
+	_, err := p.conn.Write(append(frame, packet...))
+	return err
+}
+
+// receive reads a single packet without its transport header.
+func (p *smbProbe) receive() ([]byte, error) {
+	if err := p.conn.SetReadDeadline(time.Now().Add(p.timeout)); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(p.conn, header); err != nil {
+		return nil, err
+	}
+	packet := make([]byte, binary.BigEndian.Uint32(header)&0x00ffffff)
+	if _, err := io.ReadFull(p.conn, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// roundTrip sends an SMB2 request and returns its final response, skipping
+// interim STATUS_PENDING responses.
+func (p *smbProbe) roundTrip(request []byte) ([]byte, error) {
+	if err := p.send(request); err != nil {
+		return nil, err
+	}
+	for {
+		response, err := p.receive()
+		if err != nil {
+			return nil, err
+		}
+		if len(response) < 64 || !bytes.Equal(response[:4], []byte("\xfeSMB")) {
+			return nil, errors.New("invalid SMB2 response")
+		}
+		if binary.LittleEndian.Uint32(response[8:12]) != ntStatusPending {
+			return response, nil
+		}
+	}
+}
+
+// smb2Header returns an SMB2 sync header for command.
+func (p *smbProbe) smb2Header(command uint16) []byte {
+	header := make([]byte, 64)
+	copy(header, "\xfeSMB")
+	binary.LittleEndian.PutUint16(header[4:], 64)
+	binary.LittleEndian.PutUint16(header[12:], command)
+	binary.LittleEndian.PutUint16(header[14:], 1)
+	binary.LittleEndian.PutUint64(header[24:], p.messageID)
+	binary.LittleEndian.PutUint64(header[40:], p.sessionID)
+	p.messageID++
+	return header
+}
+
+// sessionSetupRequest returns an SMB2 SESSION_SETUP request carrying token.
+func (p *smbProbe) sessionSetupRequest(token []byte) []byte {
+	body := make([]byte, 24)
+	binary.LittleEndian.PutUint16(body[0:], 25)
+	body[3] = smb2NegotiateSigningEnabled
+	binary.LittleEndian.PutUint16(body[12:], 64+24)
+	binary.LittleEndian.PutUint16(body[14:], uint16(len(token)))
+	packet := append(p.smb2Header(smb2CommandSessionSetup), body...)
+	return append(packet, token...)
+}
+
+// negotiateRequest returns an SMB2 NEGOTIATE request offering every dialect up
+// to 3.1.1 together with the encryption ciphers.
+func (p *smbProbe) negotiateRequest() ([]byte, error) {
+	clientGUID := make([]byte, 16)
+	salt := make([]byte, 32)
+	if _, err := rand.Read(clientGUID); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	packet := p.smb2Header(smb2CommandNegotiate)
+	body := make([]byte, 36)
+	binary.LittleEndian.PutUint16(body[0:], 36)
+	binary.LittleEndian.PutUint16(body[2:], uint16(len(smb2Dialects)))
+	binary.LittleEndian.PutUint16(body[4:], smb2NegotiateSigningEnabled)
+	binary.LittleEndian.PutUint32(body[8:], smb2GlobalCapEncryption)
+	copy(body[12:], clientGUID)
+	binary.LittleEndian.PutUint16(body[32:], 2)
+	for _, dialect := range smb2Dialects {
+		body = binary.LittleEndian.AppendUint16(body, dialect)
+	}
+	packet = append(packet, body...)
+
+	// negotiate contexts start 8 byte aligned
+	packet = padTo8(packet)
+	binary.LittleEndian.PutUint32(packet[64+28:], uint32(len(packet)))
+
+	preauth := []byte{1, 0, 32, 0, 1, 0} // one SHA-512 hash, 32 byte salt
+	packet = append(packet, negotiateContext(smb2PreauthIntegrityCapabilities, append(preauth, salt...))...)
+	packet = padTo8(packet)
+	// AES-128-GCM, AES-128-CCM, AES-256-GCM, AES-256-CCM
+	ciphers := []byte{4, 0, 2, 0, 1, 0, 4, 0, 3, 0}
+	packet = append(packet, negotiateContext(smb2EncryptionCapabilities, ciphers)...)
+	return packet, nil
+}
+
+// negotiateContext returns an SMB2 negotiate context of type contextType.
+func negotiateContext(contextType uint16, data []byte) []byte {
+	context := make([]byte, 8, 8+len(data))
+	binary.LittleEndian.PutUint16(context[0:], contextType)
+	binary.LittleEndian.PutUint16(context[2:], uint16(len(data)))
+	return append(context, data...)
+}
+
+func padTo8(packet []byte) []byte {
+	for len(packet)%8 != 0 {
+		packet = append(packet, 0)
+	}
+	return packet
+}
+
+// parseNegotiateResponse reads the fingerprint from an SMB2 NEGOTIATE response.
+func parseNegotiateResponse(packet []byte) (*Fingerprint, error) {
+	if len(packet) < 64+64 || !bytes.Equal(packet[:4], []byte("\xfeSMB")) {
+		return nil, errors.New("invalid SMB2 negotiate response")
+	}
+	if status := binary.LittleEndian.Uint32(packet[8:12]); status != ntStatusSuccess {
+		return nil, fmt.Errorf("negotiate: unexpected status 0x%08x", status)
+	}
+	body := packet[64:]
+	dialect := binary.LittleEndian.Uint16(body[4:6])
+	capabilities := binary.LittleEndian.Uint32(body[24:28])
+
+	fingerprint := &Fingerprint{
+		Dialect:    dialectName(dialect),
+		ServerGUID: formatGUID(body[8:24]),
+		ServerTime: filetime(binary.LittleEndian.Uint64(body[40:48])),
+		BootTime:   filetime(binary.LittleEndian.Uint64(body[48:56])),
+		Signing:    binary.LittleEndian.Uint16(body[2:4])&smb2NegotiateSigningRequired != 0,
+	}
+
+	switch {
+	case dialect == 0x0300 || dialect == 0x0302:
+		fingerprint.EncryptionSupported = capabilities&smb2GlobalCapEncryption != 0
+	case dialect == 0x0311:
+		count := int(binary.LittleEndian.Uint16(body[6:8]))
+		offset := int(binary.LittleEndian.Uint32(body[60:64]))
+		for i := 0; i < count && offset+8 <= len(packet); i++ {
+			contextType := binary.LittleEndian.Uint16(packet[offset:])
+			length := int(binary.LittleEndian.Uint16(packet[offset+2:]))
+			data := packet[offset+8 : min(offset+8+length, len(packet))]
+			// the server selects a single cipher, zero if none is common
+			if contextType == smb2EncryptionCapabilities && len(data) >= 4 {
+				fingerprint.EncryptionSupported = binary.LittleEndian.Uint16(data[2:4]) != 0
+			}
+			offset += (8 + length + 7) &^ 7
+		}
+	}
+	return fingerprint, nil
+}
+
+// parseSessionSetupResponse returns the status, session flags and security
+// buffer of an SMB2 SESSION_SETUP response.
+func parseSessionSetupResponse(packet []byte) (uint32, uint16, []byte, error) {
+	if len(packet) < 64+8 {
+		return 0, 0, nil, errors.New("invalid SMB2 session setup response")
+	}
+	status := binary.LittleEndian.Uint32(packet[8:12])
+	body := packet[64:]
+	flags := binary.LittleEndian.Uint16(body[2:4])
+	offset := int(binary.LittleEndian.Uint16(body[4:6]))
+	length := int(binary.LittleEndian.Uint16(body[6:8]))
+	if length == 0 {
+		return status, flags, nil, nil
+	}
+	if offset+length > len(packet) {
+		return 0, 0, nil, errors.New("invalid SMB2 session setup security buffer")
+	}
+	return status, flags, packet[offset : offset+length], nil
+}
+
+// parseNTLMChallenge reads the target info of the NTLM CHALLENGE message
+// wrapped in securityBuffer.
+func parseNTLMChallenge(securityBuffer []byte) (*NTLMInfo, error) {
+	start := bytes.Index(securityBuffer, []byte("NTLMSSP\x00"))
+	if start < 0 {
+		return nil, errors.New("no NTLM challenge in session setup response")
+	}
+	message := securityBuffer[start:]
+	if len(message) < 48 || binary.LittleEndian.Uint32(message[8:12]) != 2 {
+		return nil, errors.New("invalid NTLM challenge")
+	}
+
+	info := &NTLMInfo{}
+	flags := binary.LittleEndian.Uint32(message[20:24])
+	// NTLMSSP_NEGOTIATE_VERSION
+	if flags&0x02000000 != 0 && len(message) >= 56 {
+		build := binary.LittleEndian.Uint16(message[50:52])
+		info.OSVersion = fmt.Sprintf("%d.%d.%d", message[48], message[49], build)
+	}
+
+	length := int(binary.LittleEndian.Uint16(message[40:42]))
+	offset := int(binary.LittleEndian.Uint32(message[44:48]))
+	if offset+length > len(message) {
+		return nil, errors.New("invalid NTLM challenge target info")
+	}
+	targetInfo := message[offset : offset+length]
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo[0:2])
+		size := int(binary.LittleEndian.Uint16(targetInfo[2:4]))
+		if id == 0 || 4+size > len(targetInfo) {
+			break
+		}
+		value := decodeUTF16(targetInfo[4 : 4+size])
+		switch id {
+		case 1:
+			info.NetBIOSComputer = value
+		case 2:
+			info.NetBIOSDomain = value
+		case 3:
+			info.DNSComputer = value
+		case 4:
+			info.DNSDomain = value
+		case 5:
+			info.DNSTree = value
+		}
+		targetInfo = targetInfo[4+size:]
+	}
+	return info, nil
+}
+
+// smb1Header returns an SMB1 header for command.
+func smb1Header(command byte) []byte {
+	header := make([]byte, 32)
+	copy(header, "\xffSMB")
+	header[4] = command
+	header[9] = 0x18 // case insensitive, canonicalized paths
+	// unicode, NT status codes, extended security, long names
+	binary.LittleEndian.PutUint16(header[10:], 0xc801)
+	binary.LittleEndian.PutUint16(header[26:], 0xfeff) // PID
+	return header
+}
+
+// smb1NegotiateRequest returns an SMB1 NEGOTIATE request offering only the
+// NT LM 0.12 dialect.
+func smb1NegotiateRequest() []byte {
+	header := smb1Header(0x72) // SMB_COM_NEGOTIATE
+
+	dialects := append([]byte{0x02}, smb1NTLMDialect...)
+	dialects = append(dialects, 0)
+	packet := append(header, 0) // WordCount
+	packet = binary.LittleEndian.AppendUint16(packet, uint16(len(dialects)))
+	return append(packet, dialects...)
+}
+
+// smb1SessionSetupRequest returns an extended security SMB1
+// SESSION_SETUP_ANDX request carrying token.
+func smb1SessionSetupRequest(token []byte) []byte {
+	packet := smb1Header(0x73)                                // SMB_COM_SESSION_SETUP_ANDX
+	packet = append(packet, 12, 0xff, 0, 0, 0)                // WordCount, no AndX command
+	packet = binary.LittleEndian.AppendUint16(packet, 0xffff) // MaxBufferSize
+	packet = binary.LittleEndian.AppendUint16(packet, 2)      // MaxMpxCount
+	packet = binary.LittleEndian.AppendUint16(packet, 1)      // VcNumber
+	packet = binary.LittleEndian.AppendUint32(packet, 0)      // SessionKey
+	packet = binary.LittleEndian.AppendUint16(packet, uint16(len(token)))
+	packet = binary.LittleEndian.AppendUint32(packet, 0)
+	// extended security, NT status codes, NT SMBs, large files, unicode
+	packet = binary.LittleEndian.AppendUint32(packet, 0x8000005c)
+
+	data := append([]byte{}, token...)
+	// unicode strings are aligned to 2 bytes from the start of the header
+	if (len(packet)+2+len(data))%2 != 0 {
+		data = append(data, 0)
+	}
+	data = append(data, 0, 0, 0, 0) // empty native OS and LAN manager
+	packet = binary.LittleEndian.AppendUint16(packet, uint16(len(data)))
+	return append(packet, data...)
+}
+
+// parseSMB1SessionSetupResponse returns the native OS and LAN manager strings
+// of an SMB1 SESSION_SETUP_ANDX response, empty if the response has none.
+func parseSMB1SessionSetupResponse(packet []byte) (string, string) {
+	if len(packet) < 35 || !bytes.Equal(packet[:4], []byte("\xffSMB")) || packet[4] != 0x73 {
+		return "", ""
+	}
+	wordCount := int(packet[32])
+	start := 33 + 2*wordCount + 2
+	if wordCount < 3 || len(packet) < start {
+		return "", ""
+	}
+	end := min(start+int(binary.LittleEndian.Uint16(packet[start-2:])), len(packet))
+	offset := start
+	// extended security responses carry the security blob before the strings
+	if wordCount == 4 {
+		offset += int(binary.LittleEndian.Uint16(packet[39:41]))
+	}
+
+	var values []string
+	if binary.LittleEndian.Uint16(packet[10:12])&0x8000 != 0 {
+		// unicode strings are aligned to 2 bytes from the start of the header
+		offset += offset % 2
+		for offset+2 <= end && len(values) < 2 {
+			length := 0
+			for offset+length+2 <= end && binary.LittleEndian.Uint16(packet[offset+length:]) != 0 {
+				length += 2
+			}
+			values = append(values, decodeUTF16(packet[offset:offset+length]))
+			offset += length + 2
+		}
+	} else {
+		for offset < end && len(values) < 2 {
+			length := bytes.IndexByte(packet[offset:end], 0)
+			if length < 0 {
+				length = end - offset
+			}
+			values = append(values, string(packet[offset:offset+length]))
+			offset += length + 1
+		}
+	}
+	for len(values) < 2 {
+		values = append(values, "")
+	}
+	return values[0], values[1]
+}
+
+// smb1DialectAccepted reports whether an SMB1 NEGOTIATE response selected the
+// offered dialect.
+func smb1DialectAccepted(packet []byte) bool {
+	if len(packet) < 35 || !bytes.Equal(packet[:4], []byte("\xffSMB")) || packet[4] != 0x72 {
+		return false
+	}
+	if binary.LittleEndian.Uint32(packet[5:9]) != ntStatusSuccess || packet[32] == 0 {
+		return false
+	}
+	return binary.LittleEndian.Uint16(packet[33:35]) != 0xffff
+}
+
+// formatGUID returns the registry format of a little-endian GUID.
+func formatGUID(guid []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(guid[0:4]),
+		binary.LittleEndian.Uint16(guid[4:6]),
+		binary.LittleEndian.Uint16(guid[6:8]),
+		guid[8:10],
+		guid[10:16],
+	)
+}
+
+// filetime converts a Windows FILETIME to UTC, zero stays unset.
+func filetime(value uint64) *time.Time {
+	if value == 0 {
+		return nil
+	}
+	// 100-nanosecond intervals between 1601-01-01 and 1970-01-01
+	const epochDifference = 116444736000000000
+	t := time.Unix(0, int64(value-epochDifference)*100).UTC()
+	return &t
+}
+
+func decodeUTF16(data []byte) string {
+	values := make([]uint16, len(data)/2)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(values)), "\x00")
+}
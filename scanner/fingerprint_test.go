@@ -0,0 +1,307 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/jfjallid/go-smb/smb"
+)
+
+// negotiateResponse builds an SMB2 NEGOTIATE response for dialect with the
+// given negotiate contexts.
+func negotiateResponse(dialect uint16, securityMode uint16, capabilities uint32, contexts ...[]byte) []byte {
+	packet := make([]byte, 64+64)
+	copy(packet, "\xfeSMB")
+	body := packet[64:]
+	binary.LittleEndian.PutUint16(body[0:], 65)
+	binary.LittleEndian.PutUint16(body[2:], securityMode)
+	binary.LittleEndian.PutUint16(body[4:], dialect)
+	binary.LittleEndian.PutUint16(body[6:], uint16(len(contexts)))
+	copy(body[8:], []byte{0x78, 0x56, 0x34, 0x12, 0xbc, 0x9a, 0xf0, 0xde, 1, 2, 3, 4, 5, 6, 7, 8})
+	binary.LittleEndian.PutUint32(body[24:], capabilities)
+	// 2024-01-02 03:04:05 UTC as FILETIME
+	binary.LittleEndian.PutUint64(body[40:], 133486382450000000)
+	binary.LittleEndian.PutUint32(body[60:], uint32(len(packet)))
+	for _, context := range contexts {
+		packet = append(padTo8(packet), context...)
+	}
+	return packet
+}
+
+func TestParseNegotiateResponse(t *testing.T) {
+	cipher := negotiateContext(smb2EncryptionCapabilities, []byte{1, 0, 2, 0})
+	preauth := negotiateContext(smb2PreauthIntegrityCapabilities, []byte{1, 0, 0, 0, 1, 0})
+
+	fingerprint, err := parseNegotiateResponse(negotiateResponse(0x0311, smb2NegotiateSigningEnabled|smb2NegotiateSigningRequired, 0, preauth, cipher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint.Dialect != "3.1.1" || !fingerprint.EncryptionSupported || !fingerprint.Signing {
+		t.Errorf("unexpected fingerprint %+v", fingerprint)
+	}
+	if fingerprint.ServerGUID != "12345678-9abc-def0-0102-030405060708" {
+		t.Errorf("unexpected server GUID %s", fingerprint.ServerGUID)
+	}
+	if fingerprint.ServerTime == nil || !fingerprint.ServerTime.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected server time %v", fingerprint.ServerTime)
+	}
+	if fingerprint.BootTime != nil {
+		t.Errorf("expected no boot time, got %v", fingerprint.BootTime)
+	}
+	if fingerprint.Encryption() != "supported" {
+		t.Errorf("expected supported encryption, got %s", fingerprint.Encryption())
+	}
+
+	// no common cipher
+	noCipher := negotiateContext(smb2EncryptionCapabilities, []byte{1, 0, 0, 0})
+	fingerprint, err = parseNegotiateResponse(negotiateResponse(0x0311, 0, 0, noCipher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint.EncryptionSupported {
+		t.Error("expected encryption to be unsupported without a cipher")
+	}
+
+	fingerprint, err = parseNegotiateResponse(negotiateResponse(0x0300, 0, smb2GlobalCapEncryption))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint.Dialect != "3.0" || !fingerprint.EncryptionSupported || fingerprint.Signing {
+		t.Errorf("unexpected fingerprint %+v", fingerprint)
+	}
+
+	fingerprint, err = parseNegotiateResponse(negotiateResponse(0x0210, 0, smb2GlobalCapEncryption))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint.Dialect != "2.1" || fingerprint.EncryptionSupported {
+		t.Errorf("unexpected fingerprint %+v", fingerprint)
+	}
+
+	if _, err := parseNegotiateResponse([]byte("\xfeSMB")); err == nil {
+		t.Error("expected an error for a truncated response")
+	}
+}
+
+func TestParseNTLMChallenge(t *testing.T) {
+	var targetInfo []byte
+	for _, pair := range []struct {
+		id    uint16
+		value string
+	}{{2, "CORP"}, {1, "DC01"}, {4, "corp.local"}, {3, "dc01.corp.local"}, {5, "corp.local"}, {0, ""}} {
+		value := utf16.Encode([]rune(pair.value))
+		targetInfo = binary.LittleEndian.AppendUint16(targetInfo, pair.id)
+		targetInfo = binary.LittleEndian.AppendUint16(targetInfo, uint16(2*len(value)))
+		for _, v := range value {
+			targetInfo = binary.LittleEndian.AppendUint16(targetInfo, v)
+		}
+	}
+
+	message := make([]byte, 56)
+	copy(message, "NTLMSSP\x00")
+	binary.LittleEndian.PutUint32(message[8:], 2)
+	binary.LittleEndian.PutUint32(message[20:], 0x02000000)
+	binary.LittleEndian.PutUint16(message[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(message[44:], 56)
+	message[48], message[49] = 10, 0
+	binary.LittleEndian.PutUint16(message[50:], 20348)
+	message = append(message, targetInfo...)
+
+	// the challenge is wrapped in a SPNEGO token
+	info, err := parseNTLMChallenge(append([]byte{0xa1, 0x81, 0xc0, 0x30}, message...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NTLMInfo{
+		NetBIOSComputer: "DC01",
+		NetBIOSDomain:   "CORP",
+		DNSComputer:     "dc01.corp.local",
+		DNSDomain:       "corp.local",
+		DNSTree:         "corp.local",
+		OSVersion:       "10.0.20348",
+	}
+	if *info != expected {
+		t.Errorf("expected %+v, got %+v", expected, *info)
+	}
+	if got := info.GuessedOSVersion(); got != "Windows NT 10.0 Build 20348" {
+		t.Errorf("unexpected OS version %q", got)
+	}
+
+	if _, err := parseNTLMChallenge([]byte("no challenge")); err == nil {
+		t.Error("expected an error without a challenge")
+	}
+}
+
+func TestParseSessionSetupResponse(t *testing.T) {
+	packet := make([]byte, 64+8)
+	copy(packet, "\xfeSMB")
+	binary.LittleEndian.PutUint32(packet[8:], ntStatusMoreProcessingRequired)
+	binary.LittleEndian.PutUint16(packet[64+2:], smb2SessionFlagEncryptData)
+	binary.LittleEndian.PutUint16(packet[64+4:], 72)
+	binary.LittleEndian.PutUint16(packet[64+6:], 3)
+	packet = append(packet, "abc"...)
+
+	status, flags, buffer, err := parseSessionSetupResponse(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != ntStatusMoreProcessingRequired || flags != smb2SessionFlagEncryptData || string(buffer) != "abc" {
+		t.Errorf("unexpected response %x %x %q", status, flags, buffer)
+	}
+
+	binary.LittleEndian.PutUint16(packet[64+6:], 10)
+	if _, _, _, err := parseSessionSetupResponse(packet); err == nil {
+		t.Error("expected an error for an overlong security buffer")
+	}
+}
+
+func TestSMB1DialectAccepted(t *testing.T) {
+	request := smb1NegotiateRequest()
+	if string(request[:4]) != "\xffSMB" || request[4] != 0x72 {
+		t.Fatalf("unexpected request %x", request)
+	}
+
+	response := make([]byte, 37)
+	copy(response, "\xffSMB")
+	response[4] = 0x72
+	response[32] = 17
+	if !smb1DialectAccepted(response) {
+		t.Error("expected the dialect to be accepted")
+	}
+
+	binary.LittleEndian.PutUint16(response[33:], 0xffff)
+	if smb1DialectAccepted(response) {
+		t.Error("expected no dialect to be selected")
+	}
+
+	// an SMB2 response to an SMB1 negotiate means SMBv1 is disabled
+	if smb1DialectAccepted(negotiateResponse(0x02ff, 0, 0)) {
+		t.Error("expected an SMB2 response to be rejected")
+	}
+}
+
+// serveSMBProbes answers the SMB2 negotiate of the fingerprint and the SMB1
+// negotiate of the SMBv1 probe on listener, then drops the connection.
+func serveSMBProbes(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			probe := &smbProbe{conn: conn, timeout: time.Second}
+			request, err := probe.receive()
+			if err != nil || len(request) < 4 {
+				return
+			}
+			response := negotiateResponse(0x0311, smb2NegotiateSigningEnabled, 0)
+			if request[0] == 0xff {
+				response = make([]byte, 37)
+				copy(response, "\xffSMB")
+				response[4] = 0x72
+				response[32] = 17
+			}
+			_ = probe.send(response)
+		}()
+	}
+}
+
+func TestFingerprintHostPartial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveSMBProbes(listener)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	fingerprint, err := FingerprintHost(net.ParseIP("127.0.0.1"), port, time.Second, nil, nil, nil)
+	if err == nil {
+		t.Error("expected the error of the failed session setup")
+	}
+	if fingerprint == nil {
+		t.Fatal("expected the partial SMB2 fingerprint")
+	}
+	// the SMB2 negotiate result is kept when SMBv1 answers as well
+	if fingerprint.Dialect != "3.1.1" || fingerprint.ServerGUID == "" || !fingerprint.SMBv1 {
+		t.Errorf("unexpected fingerprint %+v", fingerprint)
+	}
+}
+
+func TestInfoHostNegotiateOnly(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveSMBProbes(listener)
+
+	host := DNHost{IP: net.ParseIP("127.0.0.1"), Hostname: "files01"}
+	options := &Options{SmbPort: listener.Addr().(*net.TCPAddr).Port, Timeout: time.Second}
+	hostResult, err := infoHost(host, options)
+	if err != nil {
+		t.Fatalf("a host refusing the NTLM challenge must not fail: %v", err)
+	}
+	if hostResult.Status != HostStatusOK || hostResult.Hostname != "files01" {
+		t.Errorf("unexpected host %+v", hostResult)
+	}
+	if hostResult.Fingerprint == nil || hostResult.Fingerprint.Dialect != "3.1.1" {
+		t.Errorf("expected the negotiate result, got %+v", hostResult.Fingerprint)
+	}
+}
+
+func TestSessionSetupError(t *testing.T) {
+	if err := sessionSetupError(smb.StatusLogonFailure); !isLogonFailure(err) {
+		t.Errorf("expected a logon failure, got %v", err)
+	}
+	if err := sessionSetupError(0xc0001234); err == nil || isNTStatus(err) {
+		t.Errorf("expected an unknown status error, got %v", err)
+	}
+}
+
+func TestParseSMB1SessionSetupResponse(t *testing.T) {
+	request := smb1SessionSetupRequest([]byte("token"))
+	if request[4] != 0x73 || request[32] != 12 || len(request)%2 != 0 {
+		t.Fatalf("unexpected request %x", request)
+	}
+
+	// extended security response with a 5 byte security blob
+	response := make([]byte, 32)
+	copy(response, "\xffSMB")
+	response[4] = 0x73
+	binary.LittleEndian.PutUint16(response[10:], 0xc801)
+	response = append(response, 4, 0xff, 0, 0, 0, 0, 0, 5, 0)
+	data := []byte("blob!")
+	for _, value := range []string{"Windows 6.1", "Samba 4.17.12-Debian"} {
+		for _, r := range utf16.Encode([]rune(value + "\x00")) {
+			data = binary.LittleEndian.AppendUint16(data, r)
+		}
+	}
+	response = binary.LittleEndian.AppendUint16(response, uint16(len(data)))
+	response = append(response, data...)
+
+	nativeOS, nativeLanMan := parseSMB1SessionSetupResponse(response)
+	if nativeOS != "Windows 6.1" || nativeLanMan != "Samba 4.17.12-Debian" {
+		t.Errorf("unexpected native OS %q and LAN manager %q", nativeOS, nativeLanMan)
+	}
+
+	// error responses carry no strings
+	if nativeOS, nativeLanMan := parseSMB1SessionSetupResponse(response[:35]); nativeOS != "" || nativeLanMan != "" {
+		t.Errorf("expected no strings, got %q and %q", nativeOS, nativeLanMan)
+	}
+}
+
+func TestSprintFingerprint(t *testing.T) {
+	required := true
+	fingerprint := &Fingerprint{Dialect: "3.1.1", EncryptionSupported: true, EncryptionRequired: &required}
+	if got := SprintFingerprint(fingerprint); got != " (dialect:3.1.1) (smbv1:false) (encryption:required)" {
+		t.Errorf("unexpected output %q", got)
+	}
+	if got := SprintFingerprint(nil); got != "" {
+		t.Errorf("expected no output, got %q", got)
+	}
+}
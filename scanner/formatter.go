@@ -27,6 +27,61 @@ func SPrintHostInfoWithAdmin(host, version, hostname, domain string, signing boo
 	return result
 }
 
+// SprintSession returns the session type of a host for the host line if the
+// server mapped the logon to another session type, or granted a guest session.
+func SprintSession(host Host) string {
+	if host.SessionMapped() {
+		return fmt.Sprintf(" (session:%s) (requested:%s)", host.Session, host.RequestedSession)
+	}
+	if host.Session == SessionGuest {
+		return fmt.Sprintf(" (session:%s)", host.Session)
+	}
+	return ""
+}
+
+// SprintVendor returns the vendor of the SMB server of a host for the host
+// line, empty for Windows.
+func SprintVendor(vendor *Vendor) string {
+	if vendor == nil || vendor.IsWindows() {
+		return ""
+	}
+	return fmt.Sprintf(" (vendor:%s)", vendor.Name)
+}
+
+// SprintOperatingSystem returns the support state of the operating system of
+// a host for the host line.
+func SprintOperatingSystem(os *OperatingSystem) string {
+	if os == nil {
+		return ""
+	}
+	var result string
+	if os.EOL {
+		result += fmt.Sprintf(" (eol:%s)", os.EndOfSupport)
+	}
+	if os.Ambiguous {
+		result += fmt.Sprintf(" (eol:ambiguous %s)", os.Product)
+	}
+	if os.PatchDate != "" {
+		result += fmt.Sprintf(" (patched:%s)", os.PatchDate)
+	}
+	return result
+}
+
+// SprintFingerprint returns the SMB fingerprint of a host for the host line.
+func SprintFingerprint(fingerprint *Fingerprint) string {
+	if fingerprint == nil {
+		return ""
+	}
+	result := fmt.Sprintf(" (dialect:%s) (smbv1:%v) (encryption:%s)", fingerprint.Dialect, fingerprint.SMBv1, fingerprint.Encryption())
+	if fingerprint.NTLM != nil && fingerprint.NTLM.OSVersion != "" {
+		result += fmt.Sprintf(" (build:%s)", fingerprint.NTLM.OSVersion)
+	}
+	if fingerprint.BootTime != nil {
+		result += fmt.Sprintf(" (boot:%s)", fingerprint.BootTime.Format(dateTimeFormat))
+	}
+	return result
+}
+
 func SprintFiles(files []File) string {
 	var shareListResult string
 
@@ -65,8 +120,15 @@ func SprintDirectories(ip, share string, dirs []Directory) string {
 func SprintHost(h Host, exclude []string) string {
 	var result string
 
-	result += fmt.Sprintf("\n%-16s %-16s %-16s\n", "Share", "Permissions", "Description")
-	result += fmt.Sprintf("%-16s %-16s %-16s\n", strings.Repeat("-", 5), strings.Repeat("-", 11), strings.Repeat("-", 10))
+	// shares found with several accounts are attributed to the account
+	withCredentials := slices.ContainsFunc(h.Shares, func(share Share) bool { return share.Credential != "" })
+	if withCredentials {
+		result += fmt.Sprintf("\n%-24s %-16s %-16s %-16s %-16s\n", "Credential", "Share", "Type", "Permissions", "Description")
+		result += fmt.Sprintf("%-24s %-16s %-16s %-16s %-16s\n", strings.Repeat("-", 10), strings.Repeat("-", 5), strings.Repeat("-", 4), strings.Repeat("-", 11), strings.Repeat("-", 10))
+	} else {
+		result += fmt.Sprintf("\n%-16s %-16s %-16s %-16s\n", "Share", "Type", "Permissions", "Description")
+		result += fmt.Sprintf("%-16s %-16s %-16s %-16s\n", strings.Repeat("-", 5), strings.Repeat("-", 4), strings.Repeat("-", 11), strings.Repeat("-", 10))
+	}
 
 	for _, share := range h.Shares {
 		if slices.Contains(exclude, share.ShareName) {
@@ -81,13 +143,40 @@ func SprintHost(h Host, exclude []string) string {
 			permissions = append(permissions, "WRITE")
 		}
 
-		result += fmt.Sprintf("%-16s %-16s %-16s\n", share.ShareName, strings.Join(permissions, ","), share.Description)
+		// shares found by brute force have no type
+		shareType := share.Type
+		if share.Discovery != "" {
+			shareType = share.Discovery
+		}
+
+		if withCredentials {
+			result += fmt.Sprintf("%-24s %-16s %-16s %-16s %-16s\n", share.Credential, share.ShareName, shareType, strings.Join(permissions, ","), share.Description)
+		} else {
+			result += fmt.Sprintf("%-16s %-16s %-16s %-16s\n", share.ShareName, shareType, strings.Join(permissions, ","), share.Description)
+		}
+		result += sprintShareDetails(share.Details)
 	}
 	result += "\n"
 
 	return result
 }
 
+// sprintShareDetails returns the path, uses and ACL of a share on an indented
+// line below it.
+func sprintShareDetails(details *ShareDetails) string {
+	if details == nil {
+		return ""
+	}
+	result := fmt.Sprintf("    (path:%s) (uses:%s)", details.Path, details.Uses())
+	if details.ACL != "" {
+		result += fmt.Sprintf(" (acl:%s)", details.ACL)
+	}
+	if details.Exposure != "" {
+		result += fmt.Sprintf(" (exposure:%s)", details.Exposure)
+	}
+	return result + "\n"
+}
+
 func SprintShares(h Host, exclude []string) string {
 	var result string
 
@@ -99,7 +188,11 @@ func SprintShares(h Host, exclude []string) string {
 			continue
 		}
 
-		result += fmt.Sprintf("Listing share %s\\%s\n", h.IP, share.ShareName)
+		if share.Credential != "" {
+			result += fmt.Sprintf("Listing share %s\\%s as %s\n", h.IP, share.ShareName, share.Credential)
+		} else {
+			result += fmt.Sprintf("Listing share %s\\%s\n", h.IP, share.ShareName)
+		}
 		result += fmt.Sprintf("%-4s  %8s  %-16s  %s\n", "Type", "Size", "LastWriteTime", "ShareName")
 		result += fmt.Sprintf("%-4s  %8s  %-16s  %s\n", "----", "----", "-------------", "----")
 		result += SprintFiles(share.Files)
@@ -110,6 +203,156 @@ func SprintShares(h Host, exclude []string) string {
 	return result
 }
 
+// SprintAccessMatrix returns a table of the access of every identity the host
+// was enumerated with to its shares.
+func SprintAccessMatrix(h Host, exclude []string) string {
+	identities := h.Identities()
+	var result string
+
+	result += fmt.Sprintf("\n%-16s", "Share")
+	for _, identity := range identities {
+		result += fmt.Sprintf(" %-16s", identity)
+	}
+	result += fmt.Sprintf("\n%-16s", strings.Repeat("-", 5))
+	for _, identity := range identities {
+		result += fmt.Sprintf(" %-16s", strings.Repeat("-", len(identity)))
+	}
+	result += "\n"
+
+	for _, row := range h.AccessMatrix(identities) {
+		if slices.Contains(exclude, row.Share) {
+			continue
+		}
+		result += fmt.Sprintf("%-16s", row.Share)
+		for _, cell := range row.Cells {
+			result += fmt.Sprintf(" %-16s", cell)
+		}
+		result += "\n"
+	}
+	result += "\n"
+
+	return result
+}
+
+// SprintComparison returns a table of the shares and files the compared
+// accounts have different access to.
+func SprintComparison(comparison *Comparison) string {
+	if comparison.Error != "" {
+		return fmt.Sprintf("\n    [-] %s can't be compared: %s\n", comparison.Compare, comparison.Error)
+	}
+	if len(comparison.Differences) == 0 {
+		return fmt.Sprintf("\n    [=] %s has the same access as %s\n", comparison.Compare, comparison.Baseline)
+	}
+	var result string
+
+	result += fmt.Sprintf("\n%-16s %-24s %-24s %s\n", "Share", comparison.Baseline, comparison.Compare, "Path")
+	result += fmt.Sprintf("%-16s %-24s %-24s %s\n", strings.Repeat("-", 5), strings.Repeat("-", len(comparison.Baseline)), strings.Repeat("-", len(comparison.Compare)), strings.Repeat("-", 4))
+	for _, difference := range comparison.Differences {
+		result += fmt.Sprintf("%-16s %-24s %-24s %s\n", difference.Share, difference.Baseline, difference.Compare, difference.Path)
+	}
+	result += "\n"
+
+	return result
+}
+
+// SprintCredentialResults returns one line per account tried on a host.
+func SprintCredentialResults(results []CredentialResult) string {
+	var result string
+	for _, r := range results {
+		if r.Success {
+			result += fmt.Sprintf("\n    [+] %s", r.Credential)
+			if r.Admin != nil {
+				result += fmt.Sprintf(" (admin:%v)", *r.Admin)
+			}
+		} else {
+			result += fmt.Sprintf("\n    [-] %s: %s", r.Credential, r.Error)
+		}
+	}
+	return result
+}
+
+// SprintAccounts returns a table of the accounts enumerated on a host.
+func SprintAccounts(accounts *AccountEnumeration) string {
+	if accounts == nil || len(accounts.Accounts) == 0 {
+		return ""
+	}
+	var result string
+
+	result += fmt.Sprintf("\n%-8s %-16s %-32s %s\n", "RID", "Type", "Account", "Source")
+	result += fmt.Sprintf("%-8s %-16s %-32s %s\n", strings.Repeat("-", 3), strings.Repeat("-", 4), strings.Repeat("-", 7), strings.Repeat("-", 6))
+	for _, account := range accounts.Accounts {
+		result += fmt.Sprintf("%-8d %-16s %-32s %s\n", account.RID, account.Type, account.FullName(), account.Source)
+	}
+
+	return result
+}
+
+// SprintPipes returns a table of the named pipes found on a host.
+func SprintPipes(pipes *PipeEnumeration) string {
+	if pipes == nil || len(pipes.Pipes) == 0 {
+		return ""
+	}
+	var result string
+
+	result += fmt.Sprintf("\n%-16s %-10s %-10s %s\n", "Pipe", "Status", "Risk", "Interface")
+	result += fmt.Sprintf("%-16s %-10s %-10s %s\n", strings.Repeat("-", 4), strings.Repeat("-", 6), strings.Repeat("-", 4), strings.Repeat("-", 9))
+	for _, pipe := range pipes.Pipes {
+		result += fmt.Sprintf("%-16s %-10s %-10s %s\n", pipe.Name, pipe.Status, pipe.Risk, pipe.Interface)
+	}
+
+	return result
+}
+
+// SprintAudit returns a table of the services and registry values audited on
+// a host.
+func SprintAudit(audit *AdminAudit) string {
+	if audit == nil {
+		return ""
+	}
+	var result string
+
+	if audit.ServiceError != "" {
+		result += fmt.Sprintf("\n[!] Service audit failed: %s", audit.ServiceError)
+	}
+	if audit.RegistryError != "" {
+		result += fmt.Sprintf("\n[!] Registry audit failed: %s", audit.RegistryError)
+	}
+	if len(audit.Services) > 0 {
+		result += fmt.Sprintf("\n%-16s %-14s %-10s %s\n", "Service", "State", "Start", "Finding")
+		result += fmt.Sprintf("%-16s %-14s %-10s %s\n", strings.Repeat("-", 7), strings.Repeat("-", 5), strings.Repeat("-", 5), strings.Repeat("-", 7))
+		for _, service := range audit.Services {
+			result += fmt.Sprintf("%-16s %-14s %-10s %s\n", service.Name, service.State, service.StartType, service.Finding)
+		}
+	}
+	if len(audit.Registry) > 0 {
+		result += fmt.Sprintf("\n%-90s %-16s %s\n", "Registry value", "Value", "Finding")
+		result += fmt.Sprintf("%-90s %-16s %s\n", strings.Repeat("-", 14), strings.Repeat("-", 5), strings.Repeat("-", 7))
+		for _, value := range audit.Registry {
+			data := value.Value
+			if !value.Set {
+				data = "(not set)"
+			}
+			result += fmt.Sprintf("%-90s %-16s %s\n", value.Path(), data, value.Finding)
+		}
+	}
+
+	return result
+}
+
+func SprintTrusts(trusts []Trust) string {
+	var result string
+
+	result += fmt.Sprintf("[+] Domain trusts\n%-24s %-24s %-14s %-10s %s\n", "Domain", "Partner", "Direction", "Type", "Attributes")
+	result += fmt.Sprintf("%-24s %-24s %-14s %-10s %s\n", strings.Repeat("-", 6), strings.Repeat("-", 7), strings.Repeat("-", 9), strings.Repeat("-", 4), strings.Repeat("-", 10))
+
+	for _, trust := range trusts {
+		result += fmt.Sprintf("%-24s %-24s %-14s %-10s %s\n", trust.Domain, trust.Partner, trust.Direction, trust.Type, trust.Attributes)
+	}
+	result += "\n"
+
+	return result
+}
+
 // ParseIPOrCIDR parses a string input and returns an array of valid IP addresses.
 func ParseIPOrCIDR(input string) ([]string, error) {
 	if strings.Contains(input, "-") {
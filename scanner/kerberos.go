@@ -0,0 +1,328 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/jfjallid/go-smb/krb5ssp"
+	smbclient "github.com/jfjallid/gokrb5/v8/client"
+	smbconfig "github.com/jfjallid/gokrb5/v8/config"
+	smbcredentials "github.com/jfjallid/gokrb5/v8/credentials"
+	smbkeytab "github.com/jfjallid/gokrb5/v8/keytab"
+	smbmessages "github.com/jfjallid/gokrb5/v8/messages"
+	"github.com/vflame6/sharefinder/logger"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KerberosCredentials is the Kerberos key material that can be used instead of
+// a password or NT hash, at most one of AESKey, Keytab and CCache is expected
+// to be set, and the configuration to reach the KDCs.
+type KerberosCredentials struct {
+	AESKey     []byte   // AES128 or AES256 long-term key of the user
+	Keytab     string   // path to a keytab file
+	CCache     string   // path to a credential cache file
+	Krb5Config string   // krb5.conf contents, generated if empty
+	KDC        net.IP   // KDC of the user's realm, the connected DC if nil
+	Domains    []string // other realms of the generated config
+}
+
+// LoadKrb5Config reads a krb5.conf from the --krb5-config flag, falling back
+// to the KRB5_CONFIG environment variable, and returns its contents. An empty
+// string is returned if neither is set.
+func LoadKrb5Config(flag string) (string, error) {
+	path := flag
+	if path == "" {
+		path = os.Getenv("KRB5_CONFIG")
+	}
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("krb5 config: %w", err)
+	}
+	if _, err = config.NewFromString(string(data)); err != nil {
+		return "", fmt.Errorf("krb5 config %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// krbErrorCodePattern matches the error code in the text of a KRB-ERROR, see
+// messages.KRBError.Error.
+var krbErrorCodePattern = regexp.MustCompile(`KRB Error: \((\d+)\)`)
+
+// kerberosErrorCode returns the error code of the KRB-ERROR the KDC answered
+// with. gokrb5 keeps only the text of a KRB-ERROR once it wraps it into a
+// krberror.Krberror, e.g. in Client.Login, so the code is read from the text
+// if err doesn't carry the KRB-ERROR itself.
+func kerberosErrorCode(err error) (int32, bool) {
+	var smbErr smbmessages.KRBError
+	if errors.As(err, &smbErr) {
+		return smbErr.ErrorCode, true
+	}
+	var ldapErr messages.KRBError
+	if errors.As(err, &ldapErr) {
+		return ldapErr.ErrorCode, true
+	}
+	match := krbErrorCodePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, false
+	}
+	code, parseErr := strconv.ParseInt(match[1], 10, 32)
+	if parseErr != nil {
+		return 0, false
+	}
+	return int32(code), true
+}
+
+// KerberosCCachePath returns the credential cache path from the --ccache flag,
+// falling back to the KRB5CCNAME environment variable. Only file caches are
+// supported, the FILE: prefix is stripped.
+func KerberosCCachePath(flag string) string {
+	path := flag
+	if path == "" {
+		path = os.Getenv("KRB5CCNAME")
+	}
+	return strings.TrimPrefix(path, "FILE:")
+}
+
+// aesKeyEncType returns the Kerberos encryption type of an AES key by its length.
+func aesKeyEncType(key []byte) (int32, error) {
+	switch len(key) {
+	case 16:
+		return etypeID.AES128_CTS_HMAC_SHA1_96, nil
+	case 32:
+		return etypeID.AES256_CTS_HMAC_SHA1_96, nil
+	default:
+		return 0, fmt.Errorf("invalid AES key length %d, expected 16 or 32 bytes", len(key))
+	}
+}
+
+// keytabFromKey builds an in-memory keytab holding a single long-term key,
+// so pass-the-key and pass-the-hash work with clients that only accept keytabs.
+func keytabFromKey(username, realm string, encType int32, key []byte) (*keytab.Keytab, error) {
+	kt := keytab.New()
+	// the password derived key is replaced with the supplied one below
+	if err := kt.AddEntry(username, realm, "", time.Now(), 1, encType); err != nil {
+		return nil, err
+	}
+	kt.Entries[0].Key = types.EncryptionKey{KeyType: encType, KeyValue: key}
+	return kt, nil
+}
+
+// newLDAPKerberosClient returns a Kerberos client for the GSSAPI bind. The
+// credential cache is preferred, then keytab, AES key, NT hash and password.
+func newLDAPKerberosClient(username, realm, password string, hash []byte, krb KerberosCredentials, krb5Conf *config.Config) (*client.Client, error) {
+	if krb.CCache != "" {
+		ccache, err := credentials.LoadCCache(krb.CCache)
+		if err != nil {
+			return nil, fmt.Errorf("load ccache file: %w", err)
+		}
+		return client.NewFromCCache(ccache, krb5Conf)
+	}
+
+	if krb.Keytab != "" {
+		kt, err := keytab.Load(krb.Keytab)
+		if err != nil {
+			return nil, fmt.Errorf("load keytab file: %w", err)
+		}
+		return client.NewWithKeytab(username, realm, kt, krb5Conf), nil
+	}
+
+	if len(krb.AESKey) > 0 {
+		encType, err := aesKeyEncType(krb.AESKey)
+		if err != nil {
+			return nil, err
+		}
+		kt, err := keytabFromKey(username, realm, encType, krb.AESKey)
+		if err != nil {
+			return nil, err
+		}
+		return client.NewWithKeytab(username, realm, kt, krb5Conf), nil
+	}
+
+	if len(hash) > 0 {
+		kt, err := keytabFromKey(username, realm, etypeID.RC4_HMAC, hash)
+		if err != nil {
+			return nil, err
+		}
+		return client.NewWithKeytab(username, realm, kt, krb5Conf), nil
+	}
+
+	if password != "" {
+		return client.NewWithPassword(username, realm, password, krb5Conf), nil
+	}
+
+	return nil, fmt.Errorf("kerberos requires a ccache, keytab, AES key, hash or password")
+}
+
+// KerberosTicketCache shares one Kerberos client between the SMB connections
+// of a run. The TGT is requested once, service tickets are cached per SPN and
+// reused by every worker, and expiring tickets are renewed by the client.
+type KerberosTicketCache struct {
+	options  *Options
+	mutex    sync.Mutex
+	client   *smbclient.Client
+	spnLocks map[string]*sync.Mutex
+	err      error // rejected credentials, not retried
+}
+
+// NewKerberosTicketCache returns an empty ticket cache. The TGT is requested
+// with the credentials in options on first use, so the domain controller can
+// still be located after the cache is created.
+func NewKerberosTicketCache(options *Options) *KerberosTicketCache {
+	return &KerberosTicketCache{
+		options:  options,
+		spnLocks: make(map[string]*sync.Mutex),
+	}
+}
+
+// Client returns a Kerberos security context for a single connection to spn.
+// The context is backed by the shared client, and the service ticket for spn is
+// obtained at most once, even if several workers connect to the host at once.
+func (c *KerberosTicketCache) Client(spn string) (*krb5ssp.Client, error) {
+	shared, err := c.sharedClient()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.serviceTicket(shared, spn)
+	if err != nil {
+		// the TGT may have expired mid-scan without a way to renew it, log in again once
+		logger.Debugf("Failed to get a service ticket for %s, requesting a new TGT: %v", spn, err)
+		c.reset(shared)
+		shared, err = c.sharedClient()
+		if err != nil {
+			return nil, err
+		}
+		err = c.serviceTicket(shared, spn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return krb5ssp.NewClient(shared), nil
+}
+
+// serviceTicket gets the service ticket for spn into the client cache.
+func (c *KerberosTicketCache) serviceTicket(shared *smbclient.Client, spn string) error {
+	c.mutex.Lock()
+	lock, ok := c.spnLocks[spn]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.spnLocks[spn] = lock
+	}
+	c.mutex.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+	_, _, err := shared.GetServiceTicket(spn)
+	return err
+}
+
+// reset drops the shared client if it is still the current one.
+func (c *KerberosTicketCache) reset(shared *smbclient.Client) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == shared {
+		c.client = nil
+	}
+}
+
+// sharedClient returns the shared client, logging in on first use.
+func (c *KerberosTicketCache) sharedClient() (*smbclient.Client, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client != nil {
+		return c.client, nil
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	shared, err := newSMBKerberosClient(c.options)
+	if err != nil {
+		err = fmt.Errorf("kerberos: %w", err)
+		if isKerberosLogonFailure(err) {
+			// every further attempt would count towards the lockout threshold
+			c.err = err
+		}
+		return nil, err
+	}
+	logger.Debugf("Obtained Kerberos TGT for %s@%s", shared.Credentials.UserName(), shared.Credentials.Domain())
+	c.client = shared
+	return shared, nil
+}
+
+// newSMBKerberosClient logs in with the Kerberos credentials in options. The
+// credential cache is preferred, then keytab, AES key, NT hash and password.
+func newSMBKerberosClient(options *Options) (*smbclient.Client, error) {
+	krb := options.KerberosCredentials()
+	realm := strings.ToUpper(options.Domain)
+	confText := krb.Krb5Config
+	if confText == "" {
+		kdc := strings.ToLower(options.Domain)
+		if krb.KDC != nil {
+			kdc = krb.KDC.String()
+		}
+		confText = krb5ConfigText(realm, kdc, krb.Domains)
+	}
+	cfg, err := smbconfig.NewFromString(confText)
+	if err != nil {
+		return nil, fmt.Errorf("krb5 config: %w", err)
+	}
+
+	settings := []func(*smbclient.Settings){smbclient.DisablePAFXFAST(true)}
+	if options.ProxyDialer != nil {
+		// force TCP communication with the KDC through the proxy
+		cfg.LibDefaults.UDPPreferenceLimit = 1
+		settings = append(settings, smbclient.SetProxyDialer(options.ProxyDialer))
+	}
+	if options.Timeout > 0 {
+		settings = append(settings, smbclient.SetDialTimout(options.Timeout))
+	}
+
+	if options.CCache != "" {
+		ccache, err := smbcredentials.LoadCCache(options.CCache)
+		if err != nil {
+			return nil, fmt.Errorf("load ccache file: %w", err)
+		}
+		// tickets in the cache are used as is, a TGT is not requested
+		return smbclient.NewFromCCache(ccache, nil, cfg, settings...)
+	}
+
+	var c *smbclient.Client
+	switch {
+	case options.Keytab != "":
+		kt, err := smbkeytab.Load(options.Keytab)
+		if err != nil {
+			return nil, fmt.Errorf("load keytab file: %w", err)
+		}
+		c = smbclient.NewWithKeytab(options.Username, realm, kt, cfg, settings...)
+	case len(options.AESKey) > 0:
+		c = smbclient.NewWithKey(options.Username, realm, options.AESKey, cfg, settings...)
+	case len(options.HashBytes) > 0:
+		c = smbclient.NewWithHash(options.Username, realm, options.HashBytes, cfg, settings...)
+	default:
+		c = smbclient.NewWithPassword(options.Username, realm, options.Password, cfg, settings...)
+	}
+	if c == nil {
+		// NT hash and AES key clients are not created for keys of invalid length
+		return nil, fmt.Errorf("invalid Kerberos key length")
+	}
+	if err := c.Login(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+func TestKerberosCCachePath(t *testing.T) {
+	t.Setenv("KRB5CCNAME", "FILE:/tmp/krb5cc_1000")
+	if got := KerberosCCachePath(""); got != "/tmp/krb5cc_1000" {
+		t.Fatalf("expected KRB5CCNAME fallback, got %q", got)
+	}
+	if got := KerberosCCachePath("/tmp/admin.ccache"); got != "/tmp/admin.ccache" {
+		t.Fatalf("expected flag value, got %q", got)
+	}
+	t.Setenv("KRB5CCNAME", "")
+	if got := KerberosCCachePath(""); got != "" {
+		t.Fatalf("expected empty path, got %q", got)
+	}
+}
+
+func TestAESKeyEncType(t *testing.T) {
+	if got, err := aesKeyEncType(make([]byte, 16)); err != nil || got != etypeID.AES128_CTS_HMAC_SHA1_96 {
+		t.Fatalf("unexpected AES128 result %d, %v", got, err)
+	}
+	if got, err := aesKeyEncType(make([]byte, 32)); err != nil || got != etypeID.AES256_CTS_HMAC_SHA1_96 {
+		t.Fatalf("unexpected AES256 result %d, %v", got, err)
+	}
+	if _, err := aesKeyEncType(make([]byte, 20)); err == nil {
+		t.Fatal("expected an error for invalid key length")
+	}
+}
+
+func TestKeytabFromKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x41}, 32)
+	kt, err := keytabFromKey("hodor", "NORTH.SEVENKINGDOMS.LOCAL", etypeID.AES256_CTS_HMAC_SHA1_96, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	principal := types.NewPrincipalName(1, "hodor")
+	got, _, err := kt.GetEncryptionKey(principal, "NORTH.SEVENKINGDOMS.LOCAL", 0, etypeID.AES256_CTS_HMAC_SHA1_96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.KeyValue, key) {
+		t.Fatalf("keytab holds %x, want %x", got.KeyValue, key)
+	}
+}
+
+func TestKrb5ConfigText(t *testing.T) {
+	text := krb5ConfigText("SEVENKINGDOMS.LOCAL", "192.168.56.10", []string{"north.sevenkingdoms.local", "SEVENKINGDOMS.LOCAL", "essos.local."})
+	cfg, err := config.NewFromString(text)
+	if err != nil {
+		t.Fatalf("generated config does not parse: %v\n%s", err, text)
+	}
+	if cfg.LibDefaults.DefaultRealm != "SEVENKINGDOMS.LOCAL" {
+		t.Fatalf("unexpected default realm %q", cfg.LibDefaults.DefaultRealm)
+	}
+	if len(cfg.Realms) != 3 {
+		t.Fatalf("expected 3 realms, got %d", len(cfg.Realms))
+	}
+
+	tests := map[string]string{
+		"kingslanding.sevenkingdoms.local":     "SEVENKINGDOMS.LOCAL",
+		"winterfell.north.sevenkingdoms.local": "NORTH.SEVENKINGDOMS.LOCAL",
+		"meereen.essos.local":                  "ESSOS.LOCAL",
+	}
+	for host, want := range tests {
+		if got := cfg.ResolveRealm(host); got != want {
+			t.Errorf("ResolveRealm(%s) = %s, want %s", host, got, want)
+		}
+	}
+
+	_, kdcs, err := cfg.GetKDCs("NORTH.SEVENKINGDOMS.LOCAL", true)
+	if err != nil || kdcs[1] != "north.sevenkingdoms.local:88" {
+		t.Fatalf("unexpected child realm KDCs %v: %v", kdcs, err)
+	}
+}
+
+func TestLoadKrb5Config(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "krb5.conf")
+	text := krb5ConfigText("NORTH.SEVENKINGDOMS.LOCAL", "192.168.56.11", nil)
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("KRB5_CONFIG", path)
+	got, err := LoadKrb5Config("")
+	if err != nil || got != text {
+		t.Fatalf("expected config from KRB5_CONFIG, got %q, %v", got, err)
+	}
+
+	t.Setenv("KRB5_CONFIG", "")
+	if got, err := LoadKrb5Config(""); err != nil || got != "" {
+		t.Fatalf("expected no config, got %q, %v", got, err)
+	}
+	if _, err := LoadKrb5Config(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
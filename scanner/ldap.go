@@ -2,18 +2,19 @@ package scanner
 
 import (
 	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-ldap/ldap/v3"
 	"github.com/go-ldap/ldap/v3/gssapi"
-	"github.com/jcmturner/gokrb5/v8/client"
 	"github.com/jcmturner/gokrb5/v8/config"
-	"github.com/jcmturner/gokrb5/v8/credentials"
 	"github.com/vflame6/sharefinder/logger"
 	"golang.org/x/net/proxy"
 	"math"
 	"net"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -45,7 +46,7 @@ func GetBaseDN(domain string) string {
 	return result
 }
 
-func NewLDAPConnection(host net.IP, username, password string, hash string, domain string, timeout time.Duration, proxyDialer proxy.Dialer, kerberos bool, dcHostname string, useGC bool) (*LDAPConnection, error) {
+func NewLDAPConnection(host net.IP, username, password string, hash string, domain string, timeout time.Duration, proxyDialer proxy.Dialer, kerberos bool, krb KerberosCredentials, dcHostname string, useGC bool) (*LDAPConnection, error) {
 	var l *ldap.Conn
 	var err error
 
@@ -126,37 +127,36 @@ func NewLDAPConnection(host net.IP, username, password string, hash string, doma
 			return nil, fmt.Errorf("dcHostname is required for Kerberos (used as KDC and SPN host)")
 		}
 
-		// build krb5 config in memory — no temp files
+		// build krb5 config in memory — no temp files — unless one is supplied
 		realm := strings.ToUpper(domain)
-		krb5Conf, err := newKrb5Config(realm, host.String())
+		confText := krb.Krb5Config
+		if confText == "" {
+			kdc := host.String()
+			if krb.KDC != nil {
+				kdc = krb.KDC.String()
+			}
+			confText = krb5ConfigText(realm, kdc, krb.Domains)
+		}
+		krb5Conf, err := config.NewFromString(confText)
 		if err != nil {
 			_ = l.Close()
 			return nil, fmt.Errorf("krb5 config: %w", err)
 		}
 
-		var gc *gssapi.Client
-
-		// try ccache first (KRB5CCNAME), fall back to password-based TGT
-		ccachePath, ccErr := ccachePathFromEnv()
-		if ccErr == nil {
-			ccache, err := credentials.LoadCCache(ccachePath)
+		var hashBytes []byte
+		if hash != "" {
+			hashBytes, err = hex.DecodeString(hash)
 			if err != nil {
 				_ = l.Close()
-				return nil, fmt.Errorf("gssapi: load ccache file: %w", err)
+				return nil, fmt.Errorf("decode hash: %w", err)
 			}
-			krbClient, err := client.NewFromCCache(ccache, krb5Conf)
-			if err != nil {
-				_ = l.Close()
-				return nil, fmt.Errorf("gssapi: ccache client: %w", err)
-			}
-			gc = &gssapi.Client{Client: krbClient}
-		} else if password != "" {
-			krbClient := client.NewWithPassword(username, realm, password, krb5Conf)
-			gc = &gssapi.Client{Client: krbClient}
-		} else {
+		}
+		krbClient, err := newLDAPKerberosClient(username, realm, password, hashBytes, krb, krb5Conf)
+		if err != nil {
 			_ = l.Close()
-			return nil, fmt.Errorf("kerberos requires either KRB5CCNAME ccache or -p password")
+			return nil, fmt.Errorf("gssapi: %w", err)
 		}
+		gc := &gssapi.Client{Client: krbClient}
 		conn.gssClient = gc
 
 		// SPN must be host-based ldap/<fqdn>
@@ -212,35 +212,36 @@ func (c *LDAPConnection) Close() error {
 
 // --- helpers ---
 
-func ccachePathFromEnv() (string, error) {
-	cc := os.Getenv("KRB5CCNAME")
-	if cc == "" {
-		return "", fmt.Errorf("KRB5CCNAME not set; a Kerberos ccache is required for GSSAPI bind")
+// krb5ConfigText builds a krb5 config for the given AD realm and KDC address.
+// Every other domain gets its own realm, with the domain name as the KDC
+// address since AD registers it for every DC, so cross-realm referrals within a
+// forest can be followed.
+func krb5ConfigText(realm, kdcAddr string, domains []string) string {
+	var realms, domainRealm strings.Builder
+	addRealm := func(realm, kdcAddr string) {
+		fmt.Fprintf(&realms, "  %s = {\n    kdc = %s:88\n  }\n", realm, kdcAddr)
+		fmt.Fprintf(&domainRealm, "  .%s = %s\n  %s = %s\n", strings.ToLower(realm), realm, strings.ToLower(realm), realm)
 	}
-	if strings.HasPrefix(cc, "FILE:") {
-		return strings.TrimPrefix(cc, "FILE:"), nil
+
+	addRealm(realm, kdcAddr)
+	seen := map[string]struct{}{realm: {}}
+	for _, domain := range domains {
+		other := strings.ToUpper(strings.TrimSuffix(domain, "."))
+		if _, ok := seen[other]; ok || other == "" {
+			continue
+		}
+		seen[other] = struct{}{}
+		addRealm(other, strings.ToLower(other))
 	}
-	// If it's a bare path, just return it. Other schemes (DIR:, KEYRING:, API:) are not handled here.
-	return cc, nil
-}
 
-// newKrb5Config builds a minimal krb5 config in memory for the given AD realm and KDC address.
-func newKrb5Config(realm, kdcAddr string) (*config.Config, error) {
-	confStr := fmt.Sprintf(`[libdefaults]
+	return fmt.Sprintf(`[libdefaults]
   default_realm = %s
   dns_lookup_kdc = false
   dns_canonicalize_hostname = false
   rdns = false
 [realms]
-  %s = {
-    kdc = %s:88
-  }
-[domain_realm]
-  .%s = %s
-  %s = %s
-`, realm, realm, kdcAddr, strings.ToLower(realm), realm, strings.ToLower(realm), realm)
-
-	return config.NewFromString(confStr)
+%s[domain_realm]
+%s`, realm, realms.String(), domainRealm.String())
 }
 
 func (conn *LDAPConnection) SearchComputers(baseDN string) (*ldap.SearchResult, error) {
@@ -262,6 +263,56 @@ func (conn *LDAPConnection) SearchComputers(baseDN string) (*ldap.SearchResult,
 	return sr, nil
 }
 
+// SearchComputersPaged runs the same query as SearchComputers but hands every
+// page of results to handler as soon as it arrives, so callers can start
+// processing computers before the whole directory has been read.
+// extraAttributes are requested in addition to dNSHostName.
+func (conn *LDAPConnection) SearchComputersPaged(baseDN string, pageSize uint32, handler func([]*ldap.Entry) error, extraAttributes ...string) error {
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectCategory=Computer)",
+		append([]string{"dNSHostName"}, extraAttributes...),
+		nil,
+	)
+	return conn.searchPaged(searchRequest, pageSize, handler)
+}
+
+// searchPaged drives the simple paged results control manually. If handler
+// fails, the server-side paging state is released before returning.
+func (conn *LDAPConnection) searchPaged(searchRequest *ldap.SearchRequest, pageSize uint32, handler func([]*ldap.Entry) error) error {
+	pagingControl := ldap.NewControlPaging(pageSize)
+	searchRequest.Controls = append(searchRequest.Controls, pagingControl)
+
+	for {
+		result, err := conn.connection.Search(searchRequest)
+		if err != nil {
+			return err
+		}
+
+		responseControl, ok := ldap.FindControl(result.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		morePages := ok && len(responseControl.Cookie) > 0
+
+		if err := handler(result.Entries); err != nil {
+			if morePages {
+				pagingControl.PagingSize = 0
+				pagingControl.SetCookie(responseControl.Cookie)
+				_, _ = conn.connection.Search(searchRequest)
+			}
+			return err
+		}
+
+		if !morePages {
+			return nil
+		}
+		pagingControl.SetCookie(responseControl.Cookie)
+	}
+}
+
 func (conn *LDAPConnection) SearchForestDomains() ([]DomainPartition, error) {
 	rootDSERequest := ldap.NewSearchRequest(
 		"",
@@ -328,3 +379,444 @@ func extractDomainPartitions(entries []*ldap.Entry) ([]DomainPartition, error) {
 
 	return results, nil
 }
+
+// dnsRecordHeaderSize is the size of the DNS_RPC_RECORD header preceding record data (MS-DNSP 2.3.2.2)
+const dnsRecordHeaderSize = 24
+
+const (
+	dnsTypeA    = 0x0001
+	dnsTypeAAAA = 0x001c
+)
+
+// SearchDNSPartitions returns the AD-integrated DNS application partitions
+// (DomainDnsZones and ForestDnsZones) hosted by the connected DC.
+func (conn *LDAPConnection) SearchDNSPartitions() ([]string, error) {
+	rootDSERequest := ldap.NewSearchRequest(
+		"",
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		1,
+		0,
+		false,
+		"(objectClass=*)",
+		[]string{"namingContexts"},
+		nil,
+	)
+	rootDSEResult, err := conn.connection.Search(rootDSERequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(rootDSEResult.Entries) == 0 {
+		return nil, fmt.Errorf("empty rootDSE response")
+	}
+
+	var partitions []string
+	for _, nc := range rootDSEResult.Entries[0].GetAttributeValues("namingContexts") {
+		lower := strings.ToLower(nc)
+		if strings.HasPrefix(lower, "dc=domaindnszones,") || strings.HasPrefix(lower, "dc=forestdnszones,") {
+			partitions = append(partitions, nc)
+		}
+	}
+
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("no DNS application partitions found")
+	}
+
+	return partitions, nil
+}
+
+// SearchDNSZoneHosts pages through dnsNode objects of a DNS application partition
+// and hands the hosts with A/AAAA records to handler, page by page.
+func (conn *LDAPConnection) SearchDNSZoneHosts(partitionDN string, pageSize uint32, handler func([]DNHost) error) error {
+	searchRequest := ldap.NewSearchRequest(
+		partitionDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(&(objectClass=dnsNode)(!(dNSTombstoned=TRUE)))",
+		[]string{"dc", "dnsRecord"},
+		nil,
+	)
+	return conn.searchPaged(searchRequest, pageSize, func(entries []*ldap.Entry) error {
+		return handler(extractDNSZoneHosts(entries))
+	})
+}
+
+// extractDNSZoneHosts converts dnsNode entries to hosts. Zone apex, service and
+// special records, reverse lookup zones and nodes without address records are skipped.
+// An IPv4 address is preferred when a node has both A and AAAA records.
+func extractDNSZoneHosts(entries []*ldap.Entry) []DNHost {
+	var results []DNHost
+	for _, entry := range entries {
+		hostname := dnsNodeHostname(entry.DN, entry.GetAttributeValue("dc"))
+		if hostname == "" {
+			continue
+		}
+
+		var ip net.IP
+		for _, record := range entry.GetRawAttributeValues("dnsRecord") {
+			recordIP := decodeDNSRecordAddress(record)
+			if recordIP == nil {
+				continue
+			}
+			if ip == nil || (ip.To4() == nil && recordIP.To4() != nil) {
+				ip = recordIP
+			}
+		}
+		if ip == nil {
+			continue
+		}
+
+		results = append(results, DNHost{Hostname: hostname, IP: ip, Source: SourceDNS})
+	}
+	return results
+}
+
+// dnsNodeHostname builds the FQDN of a dnsNode from its name and the zone in its DN,
+// e.g. DC=ws01,DC=corp.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=corp,DC=local
+// is ws01.corp.local. It returns an empty string for records that are not hosts.
+func dnsNodeHostname(dn, node string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) < 2 || len(parsed.RDNs[1].Attributes) == 0 {
+		return ""
+	}
+	zone := strings.ToLower(parsed.RDNs[1].Attributes[0].Value)
+	if node == "" && len(parsed.RDNs[0].Attributes) > 0 {
+		node = parsed.RDNs[0].Attributes[0].Value
+	}
+	node = strings.ToLower(node)
+
+	if node == "" || node == "@" || strings.HasPrefix(node, "_") || strings.HasPrefix(node, "..") {
+		return ""
+	}
+	if node == "domaindnszones" || node == "forestdnszones" || node == "*" {
+		return ""
+	}
+	if zone == "" || zone == "rootdnsservers" || strings.HasPrefix(zone, "..") || strings.HasPrefix(zone, "_msdcs.") || strings.HasSuffix(zone, ".arpa") {
+		return ""
+	}
+
+	return node + "." + zone
+}
+
+// decodeDNSRecordAddress returns the address of an A or AAAA dnsRecord value, or nil
+// for any other record type or malformed value. The layout is DNS_RPC_RECORD from MS-DNSP.
+func decodeDNSRecordAddress(record []byte) net.IP {
+	if len(record) < dnsRecordHeaderSize {
+		return nil
+	}
+	dataLength := int(binary.LittleEndian.Uint16(record[0:2]))
+	recordType := binary.LittleEndian.Uint16(record[2:4])
+	data := record[dnsRecordHeaderSize:]
+	if len(data) < dataLength {
+		return nil
+	}
+
+	switch {
+	case recordType == dnsTypeA && dataLength == net.IPv4len:
+		return net.IPv4(data[0], data[1], data[2], data[3])
+	case recordType == dnsTypeAAAA && dataLength == net.IPv6len:
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, data[:net.IPv6len])
+		return ip
+	default:
+		return nil
+	}
+}
+
+// trust direction values of trustedDomain.trustDirection
+const (
+	trustDirectionInbound  = 0x1
+	trustDirectionOutbound = 0x2
+)
+
+// trustAttributeNames maps trustedDomain.trustAttributes flags to their names
+var trustAttributeNames = []struct {
+	flag uint32
+	name string
+}{
+	{0x1, "NON_TRANSITIVE"},
+	{0x2, "UPLEVEL_ONLY"},
+	{0x4, "QUARANTINED_DOMAIN"},
+	{0x8, "FOREST_TRANSITIVE"},
+	{0x10, "CROSS_ORGANIZATION"},
+	{0x20, "WITHIN_FOREST"},
+	{0x40, "TREAT_AS_EXTERNAL"},
+	{0x80, "USES_RC4_ENCRYPTION"},
+	{0x200, "CROSS_ORGANIZATION_NO_TGT_DELEGATION"},
+	{0x400, "PIM_TRUST"},
+	{0x800, "CROSS_ORGANIZATION_ENABLE_TGT_DELEGATION"},
+}
+
+// SearchTrusts reads the trustedDomain objects of a domain.
+func (conn *LDAPConnection) SearchTrusts(domain DomainPartition) ([]Trust, error) {
+	searchRequest := ldap.NewSearchRequest(
+		"CN=System,"+domain.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectClass=trustedDomain)",
+		[]string{"trustPartner", "flatName", "trustDirection", "trustType", "trustAttributes", "securityIdentifier"},
+		nil,
+	)
+	sr, err := conn.connection.SearchWithPaging(searchRequest, ldapPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return extractTrusts(domain.Name, sr.Entries), nil
+}
+
+// extractTrusts converts trustedDomain entries of domain to trusts
+func extractTrusts(domain string, entries []*ldap.Entry) []Trust {
+	var results []Trust
+	for _, entry := range entries {
+		partner := strings.ToLower(strings.TrimSpace(entry.GetAttributeValue("trustPartner")))
+		if partner == "" {
+			continue
+		}
+		direction := parseUint32(entry.GetAttributeValue("trustDirection"))
+		results = append(results, Trust{
+			Domain:     domain,
+			Partner:    partner,
+			FlatName:   entry.GetAttributeValue("flatName"),
+			Direction:  trustDirectionString(direction),
+			Type:       trustTypeString(parseUint32(entry.GetAttributeValue("trustType"))),
+			Attributes: trustAttributesString(parseUint32(entry.GetAttributeValue("trustAttributes"))),
+			SID:        decodeSID(entry.GetRawAttributeValue("securityIdentifier")),
+		})
+	}
+	return results
+}
+
+func parseUint32(value string) uint32 {
+	// trustAttributes and friends are stored as signed 32-bit integers
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint32(n)
+}
+
+func trustDirectionString(direction uint32) string {
+	switch direction {
+	case 0:
+		return "disabled"
+	case trustDirectionInbound:
+		return "inbound"
+	case trustDirectionOutbound:
+		return "outbound"
+	case trustDirectionInbound | trustDirectionOutbound:
+		return "bidirectional"
+	default:
+		return fmt.Sprintf("unknown(%d)", direction)
+	}
+}
+
+func trustTypeString(trustType uint32) string {
+	switch trustType {
+	case 1:
+		return "downlevel"
+	case 2:
+		return "uplevel"
+	case 3:
+		return "mit"
+	case 4:
+		return "dce"
+	default:
+		return fmt.Sprintf("unknown(%d)", trustType)
+	}
+}
+
+func trustAttributesString(attributes uint32) string {
+	var names []string
+	for _, attribute := range trustAttributeNames {
+		if attributes&attribute.flag != 0 {
+			names = append(names, attribute.name)
+			attributes &^= attribute.flag
+		}
+	}
+	if attributes != 0 {
+		names = append(names, fmt.Sprintf("0x%x", attributes))
+	}
+	return strings.Join(names, ",")
+}
+
+// decodeSID converts a binary security identifier to its S-1-... string form
+func decodeSID(sid []byte) string {
+	if len(sid) < 8 {
+		return ""
+	}
+	subAuthorityCount := int(sid[1])
+	if len(sid) < 8+4*subAuthorityCount {
+		return ""
+	}
+
+	var authority uint64
+	for _, b := range sid[2:8] {
+		authority = authority<<8 | uint64(b)
+	}
+
+	result := fmt.Sprintf("S-%d-%d", sid[0], authority)
+	for i := 0; i < subAuthorityCount; i++ {
+		result += fmt.Sprintf("-%d", binary.LittleEndian.Uint32(sid[8+4*i:]))
+	}
+	return result
+}
+
+// LAPS attributes of computer objects
+var lapsAttributes = []string{"ms-Mcs-AdmPwd", "msLAPS-Password", "msLAPS-EncryptedPassword"}
+
+// windowsLAPSPassword is the JSON value of msLAPS-Password
+type windowsLAPSPassword struct {
+	Account  string `json:"n"`
+	Password string `json:"p"`
+}
+
+// lapsCredential returns the local administrator credential stored in LAPS
+// attributes of a computer entry. Windows LAPS is preferred over legacy LAPS.
+// The encrypted Windows LAPS password can not be used without DPAPI-NG
+// decryption, so only its presence is reported. nil is returned if the entry has
+// no readable LAPS attributes.
+func lapsCredential(entry *ldap.Entry, legacyUsername string) *Credential {
+	if value := entry.GetAttributeValue("msLAPS-Password"); value != "" {
+		var password windowsLAPSPassword
+		if err := json.Unmarshal([]byte(value), &password); err == nil && password.Password != "" {
+			username := password.Account
+			if username == "" {
+				username = legacyUsername
+			}
+			return &Credential{
+				Username:  username,
+				Password:  password.Password,
+				LocalAuth: true,
+				Source:    CredentialSourceWindowsLAPS,
+			}
+		}
+	}
+
+	if password := entry.GetAttributeValue("ms-Mcs-AdmPwd"); password != "" {
+		return &Credential{
+			Username:  legacyUsername,
+			Password:  password,
+			LocalAuth: true,
+			Source:    CredentialSourceLegacyLAPS,
+		}
+	}
+
+	if len(entry.GetRawAttributeValue("msLAPS-EncryptedPassword")) > 0 {
+		return &Credential{Source: CredentialSourceEncrypted}
+	}
+
+	return nil
+}
+
+// LockoutPolicy is the account lockout policy of a domain
+type LockoutPolicy struct {
+	Threshold         int           // failed logons before lockout, 0 if accounts are never locked out
+	ObservationWindow time.Duration // time after which the failed logon count is reset
+	Duration          time.Duration // time an account stays locked out
+}
+
+// SearchDefaultNamingContext reads the distinguished name of the domain of the
+// connected domain controller from the rootDSE.
+func (conn *LDAPConnection) SearchDefaultNamingContext() (string, error) {
+	rootDSERequest := ldap.NewSearchRequest(
+		"",
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		1,
+		0,
+		false,
+		"(objectClass=*)",
+		[]string{"defaultNamingContext"},
+		nil,
+	)
+	rootDSEResult, err := conn.connection.Search(rootDSERequest)
+	if err != nil {
+		return "", err
+	}
+	if len(rootDSEResult.Entries) == 0 {
+		return "", fmt.Errorf("empty rootDSE response")
+	}
+	baseDN := rootDSEResult.Entries[0].GetAttributeValue("defaultNamingContext")
+	if baseDN == "" {
+		return "", fmt.Errorf("defaultNamingContext not found")
+	}
+	return baseDN, nil
+}
+
+// SearchLockoutPolicy reads the lockout policy from the domain object.
+func (conn *LDAPConnection) SearchLockoutPolicy(baseDN string) (LockoutPolicy, error) {
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectClass=*)",
+		[]string{"lockoutThreshold", "lockOutObservationWindow", "lockoutDuration"},
+		nil,
+	)
+	sr, err := conn.connection.Search(searchRequest)
+	if err != nil {
+		return LockoutPolicy{}, err
+	}
+	if len(sr.Entries) == 0 {
+		return LockoutPolicy{}, fmt.Errorf("domain object %s not found", baseDN)
+	}
+	return extractLockoutPolicy(sr.Entries[0]), nil
+}
+
+// extractLockoutPolicy converts the domain object attributes to a lockout policy
+func extractLockoutPolicy(entry *ldap.Entry) LockoutPolicy {
+	threshold, _ := strconv.Atoi(entry.GetAttributeValue("lockoutThreshold"))
+	return LockoutPolicy{
+		Threshold:         threshold,
+		ObservationWindow: adInterval(entry.GetAttributeValue("lockOutObservationWindow")),
+		Duration:          adInterval(entry.GetAttributeValue("lockoutDuration")),
+	}
+}
+
+// adInterval converts an AD time interval, a negative number of 100-nanosecond
+// units, to a duration.
+func adInterval(value string) time.Duration {
+	interval, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if interval < 0 {
+		interval = -interval
+	}
+	return time.Duration(interval) * 100
+}
+
+// SearchBadPwdCount reads the failed logon count of an account. The attribute
+// is not replicated, so the value is the count known to the connected DC.
+func (conn *LDAPConnection) SearchBadPwdCount(baseDN, username string) (int, error) {
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		1,
+		0,
+		false,
+		fmt.Sprintf("(sAMAccountName=%s)", ldap.EscapeFilter(username)),
+		[]string{"badPwdCount"},
+		nil,
+	)
+	sr, err := conn.connection.Search(searchRequest)
+	if err != nil {
+		return 0, err
+	}
+	if len(sr.Entries) == 0 {
+		return 0, fmt.Errorf("account %s not found", username)
+	}
+	count, _ := strconv.Atoi(sr.Entries[0].GetAttributeValue("badPwdCount"))
+	return count, nil
+}
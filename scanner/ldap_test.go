@@ -1,8 +1,11 @@
 package scanner
 
 import (
+	"encoding/binary"
 	"fmt"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
 )
@@ -103,3 +106,258 @@ func TestExtractDomainPartitions_NoUsableDomains(t *testing.T) {
 		t.Fatal("expected error when no valid forest domains are present")
 	}
 }
+
+func dnsRecordBlob(recordType uint16, data []byte) []byte {
+	blob := make([]byte, dnsRecordHeaderSize+len(data))
+	binary.LittleEndian.PutUint16(blob[0:2], uint16(len(data)))
+	binary.LittleEndian.PutUint16(blob[2:4], recordType)
+	blob[4] = 5 // version
+	copy(blob[dnsRecordHeaderSize:], data)
+	return blob
+}
+
+func TestDecodeDNSRecordAddress(t *testing.T) {
+	v6 := net.ParseIP("fd00::10")
+	tests := []struct {
+		name   string
+		record []byte
+		want   string
+	}{
+		{name: "A record", record: dnsRecordBlob(dnsTypeA, []byte{10, 0, 0, 5}), want: "10.0.0.5"},
+		{name: "AAAA record", record: dnsRecordBlob(dnsTypeAAAA, v6), want: "fd00::10"},
+		{name: "SRV record ignored", record: dnsRecordBlob(0x0021, []byte{0, 0, 0, 0, 1, 189}), want: ""},
+		{name: "truncated header", record: []byte{4, 0, 1, 0}, want: ""},
+		{name: "truncated data", record: dnsRecordBlob(dnsTypeA, []byte{10, 0, 0, 5})[:dnsRecordHeaderSize+2], want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeDNSRecordAddress(tt.record)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("expected nil, got %s", got)
+				}
+				return
+			}
+			if got == nil || got.String() != tt.want {
+				t.Fatalf("decodeDNSRecordAddress() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSNodeHostname(t *testing.T) {
+	tests := []struct {
+		name string
+		dn   string
+		node string
+		want string
+	}{
+		{
+			name: "host record",
+			dn:   "DC=WS01,DC=corp.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=corp,DC=local",
+			node: "WS01",
+			want: "ws01.corp.local",
+		},
+		{
+			name: "node name from DN",
+			dn:   "DC=srv02,DC=child.corp.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=child,DC=corp,DC=local",
+			want: "srv02.child.corp.local",
+		},
+		{
+			name: "zone apex",
+			dn:   "DC=@,DC=corp.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=corp,DC=local",
+			node: "@",
+		},
+		{
+			name: "service record",
+			dn:   "DC=_ldap._tcp,DC=corp.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=corp,DC=local",
+			node: "_ldap._tcp",
+		},
+		{
+			name: "reverse zone",
+			dn:   "DC=5,DC=0.0.10.in-addr.arpa,CN=MicrosoftDNS,DC=DomainDnsZones,DC=corp,DC=local",
+			node: "5",
+		},
+		{
+			name: "msdcs zone",
+			dn:   "DC=gc,DC=_msdcs.corp.local,CN=MicrosoftDNS,DC=ForestDnsZones,DC=corp,DC=local",
+			node: "gc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dnsNodeHostname(tt.dn, tt.node); got != tt.want {
+				t.Fatalf("dnsNodeHostname() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractDNSZoneHosts(t *testing.T) {
+	entries := []*ldap.Entry{
+		{
+			DN: "DC=ws01,DC=corp.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=corp,DC=local",
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "dc", Values: []string{"ws01"}},
+				{Name: "dnsRecord", ByteValues: [][]byte{
+					dnsRecordBlob(dnsTypeAAAA, net.ParseIP("fd00::1")),
+					dnsRecordBlob(dnsTypeA, []byte{10, 0, 0, 1}),
+				}},
+			},
+		},
+		{
+			DN: "DC=cname,DC=corp.local,CN=MicrosoftDNS,DC=DomainDnsZones,DC=corp,DC=local",
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "dc", Values: []string{"cname"}},
+				{Name: "dnsRecord", ByteValues: [][]byte{dnsRecordBlob(0x0005, []byte{3, 'w', 'w', 'w'})}},
+			},
+		},
+	}
+
+	got := extractDNSZoneHosts(entries)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 host, got %d: %#v", len(got), got)
+	}
+	if got[0].Hostname != "ws01.corp.local" || got[0].IP.String() != "10.0.0.1" || got[0].Source != SourceDNS {
+		t.Fatalf("unexpected host: %#v", got[0])
+	}
+}
+
+func TestDecodeSID(t *testing.T) {
+	// S-1-5-21-1004336348-1177238915-682003330
+	sid := []byte{1, 4, 0, 0, 0, 0, 0, 5}
+	for _, sub := range []uint32{21, 1004336348, 1177238915, 682003330} {
+		sid = binary.LittleEndian.AppendUint32(sid, sub)
+	}
+	if got := decodeSID(sid); got != "S-1-5-21-1004336348-1177238915-682003330" {
+		t.Fatalf("decodeSID() = %q", got)
+	}
+	if got := decodeSID(sid[:12]); got != "" {
+		t.Fatalf("expected empty SID for truncated value, got %q", got)
+	}
+}
+
+func TestExtractTrusts(t *testing.T) {
+	entries := []*ldap.Entry{
+		{
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "trustPartner", Values: []string{"Partner.Local"}},
+				{Name: "flatName", Values: []string{"PARTNER"}},
+				{Name: "trustDirection", Values: []string{"3"}},
+				{Name: "trustType", Values: []string{"2"}},
+				{Name: "trustAttributes", Values: []string{"8"}},
+			},
+		},
+		{
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "trustPartner", Values: []string{"child.corp.local"}},
+				{Name: "trustDirection", Values: []string{"2"}},
+				{Name: "trustType", Values: []string{"2"}},
+				{Name: "trustAttributes", Values: []string{"36"}},
+			},
+		},
+		{
+			Attributes: []*ldap.EntryAttribute{
+				{Name: "flatName", Values: []string{"NOPARTNER"}},
+			},
+		},
+	}
+
+	got := extractTrusts("corp.local", entries)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 trusts, got %d", len(got))
+	}
+	first := got[0]
+	if first.Domain != "corp.local" || first.Partner != "partner.local" || first.FlatName != "PARTNER" {
+		t.Fatalf("unexpected first trust: %#v", first)
+	}
+	if first.Direction != "bidirectional" || first.Type != "uplevel" || first.Attributes != "FOREST_TRANSITIVE" {
+		t.Fatalf("unexpected first trust properties: %#v", first)
+	}
+	if !first.AllowsAccess() {
+		t.Fatal("bidirectional trust should allow access")
+	}
+	second := got[1]
+	if second.Direction != "outbound" || second.Attributes != "QUARANTINED_DOMAIN,WITHIN_FOREST" {
+		t.Fatalf("unexpected second trust properties: %#v", second)
+	}
+	if second.AllowsAccess() {
+		t.Fatal("outbound trust should not allow access to the partner")
+	}
+}
+
+func TestTrustAttributesString(t *testing.T) {
+	if got := trustAttributesString(0); got != "" {
+		t.Fatalf("expected empty string, got %q", got)
+	}
+	if got := trustAttributesString(0x1 | 0x100000); got != "NON_TRANSITIVE,0x100000" {
+		t.Fatalf("unexpected attributes string %q", got)
+	}
+}
+
+func TestLAPSCredential(t *testing.T) {
+	entry := func(attrs ...*ldap.EntryAttribute) *ldap.Entry {
+		return &ldap.Entry{Attributes: attrs}
+	}
+
+	windows := entry(
+		&ldap.EntryAttribute{Name: "msLAPS-Password", Values: []string{`{"n":"LocalAdmin","t":"1d9b2b6e1f0c3a0","p":"W1nd0ws!"}`}},
+		&ldap.EntryAttribute{Name: "ms-Mcs-AdmPwd", Values: []string{"legacy"}},
+	)
+	got := lapsCredential(windows, "Administrator")
+	if got == nil || got.Username != "LocalAdmin" || got.Password != "W1nd0ws!" || !got.LocalAuth || got.Source != CredentialSourceWindowsLAPS || !got.IsLAPS() {
+		t.Fatalf("unexpected Windows LAPS credential: %#v", got)
+	}
+
+	legacy := entry(&ldap.EntryAttribute{Name: "ms-Mcs-AdmPwd", Values: []string{"L3gacy!"}})
+	got = lapsCredential(legacy, "Administrator")
+	if got == nil || got.Username != "Administrator" || got.Password != "L3gacy!" || got.Source != CredentialSourceLegacyLAPS {
+		t.Fatalf("unexpected legacy LAPS credential: %#v", got)
+	}
+
+	encrypted := entry(&ldap.EntryAttribute{Name: "msLAPS-EncryptedPassword", ByteValues: [][]byte{{0x01, 0x02}}})
+	got = lapsCredential(encrypted, "Administrator")
+	if got == nil || got.Source != CredentialSourceEncrypted || got.Usable() || got.IsLAPS() {
+		t.Fatalf("unexpected encrypted LAPS credential: %#v", got)
+	}
+
+	if got := lapsCredential(entry(), "Administrator"); got != nil || got.IsLAPS() {
+		t.Fatalf("expected no credential, got %#v", got)
+	}
+}
+
+func TestExtractLockoutPolicy(t *testing.T) {
+	entry := &ldap.Entry{
+		Attributes: []*ldap.EntryAttribute{
+			{Name: "lockoutThreshold", Values: []string{"5"}},
+			{Name: "lockOutObservationWindow", Values: []string{"-18000000000"}},
+			{Name: "lockoutDuration", Values: []string{"-9223372036854775808"}},
+		},
+	}
+
+	policy := extractLockoutPolicy(entry)
+	if policy.Threshold != 5 {
+		t.Errorf("expected threshold 5, got %d", policy.Threshold)
+	}
+	if policy.ObservationWindow != 30*time.Minute {
+		t.Errorf("expected 30m observation window, got %s", policy.ObservationWindow)
+	}
+
+	empty := extractLockoutPolicy(&ldap.Entry{})
+	if empty.Threshold != 0 || empty.ObservationWindow != 0 {
+		t.Errorf("expected an empty policy, got %+v", empty)
+	}
+}
+
+func TestLAPSHostCount(t *testing.T) {
+	run := SharefinderRun{Hosts: []Host{
+		{IP: "10.0.0.1", LAPS: CredentialSourceWindowsLAPS},
+		{IP: "10.0.0.2", LAPSEncrypted: true},
+		{IP: "10.0.0.3"},
+	}}
+	if run.LAPSHostCount() != 1 || run.LAPSEncryptedHostCount() != 1 {
+		t.Errorf("expected 1 LAPS and 1 encrypted LAPS host, got %d and %d", run.LAPSHostCount(), run.LAPSEncryptedHostCount())
+	}
+}
@@ -0,0 +1,234 @@
+package scanner
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lifecycleJSON is the built-in lifecycle table. Update lifecycle.json when
+// Microsoft publishes new releases or end of support dates, or pass a newer
+// copy with --lifecycle-table.
+//
+//go:embed lifecycle.json
+var lifecycleJSON []byte
+
+// lifecycleDateLayout is the layout of the dates of the lifecycle table
+const lifecycleDateLayout = "2006-01-02"
+
+// LifecycleTable maps Windows builds to their products, support dates and
+// the release dates of their cumulative updates
+type LifecycleTable struct {
+	Updated  string             `json:"updated"`
+	Products []LifecycleProduct `json:"products"`
+	Updates  []LifecycleUpdate  `json:"updates"`
+}
+
+// LifecycleProduct is a Windows product of a build. Products with editions
+// apply when the registry product name contains one of them, e.g. LTSC.
+type LifecycleProduct struct {
+	Product      string   `json:"product"`
+	Build        uint32   `json:"build"`
+	Server       bool     `json:"server,omitempty"`
+	Editions     []string `json:"editions,omitempty"`
+	Released     string   `json:"released"`
+	EndOfSupport string   `json:"end_of_support"`
+}
+
+// LifecycleUpdate is a cumulative update of a build, identified by the UBR
+// it sets
+type LifecycleUpdate struct {
+	Build    uint32 `json:"build"`
+	UBR      uint32 `json:"ubr"`
+	Released string `json:"released"`
+}
+
+// OperatingSystem is the Windows version of a host assessed against the
+// lifecycle table
+type OperatingSystem struct {
+	Product      string `xml:"product,attr,omitempty"`
+	Build        uint32 `xml:"build,attr"`
+	UBR          uint32 `xml:"ubr,attr,omitempty"`
+	EndOfSupport string `xml:"end_of_support,attr,omitempty"`
+	EOL          bool   `xml:"eol,attr"`
+	Ambiguous    bool   `xml:"ambiguous,attr,omitempty"` // guessed products of the build differ in EOL
+	PatchDate    string `xml:"patch_date,attr,omitempty"`
+	PatchAge     int    `xml:"patch_age,attr,omitempty"` // days since PatchDate
+}
+
+// ParseLifecycleTable reads a lifecycle table in the format of lifecycle.json.
+func ParseLifecycleTable(r io.Reader) (*LifecycleTable, error) {
+	var table LifecycleTable
+	if err := json.NewDecoder(r).Decode(&table); err != nil {
+		return nil, err
+	}
+	for _, product := range table.Products {
+		for _, date := range []string{product.Released, product.EndOfSupport} {
+			if _, err := time.Parse(lifecycleDateLayout, date); err != nil {
+				return nil, fmt.Errorf("invalid date of %s: %v", product.Product, err)
+			}
+		}
+	}
+	for _, update := range table.Updates {
+		if _, err := time.Parse(lifecycleDateLayout, update.Released); err != nil {
+			return nil, fmt.Errorf("invalid date of update %d.%d: %v", update.Build, update.UBR, err)
+		}
+	}
+	return &table, nil
+}
+
+// DefaultLifecycleTable returns the built-in lifecycle table.
+func DefaultLifecycleTable() *LifecycleTable {
+	table, err := ParseLifecycleTable(bytes.NewReader(lifecycleJSON))
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in lifecycle table: %v", err))
+	}
+	return table
+}
+
+// LoadLifecycleTable returns the lifecycle table of the file at path, or the
+// built-in table if path is empty.
+func LoadLifecycleTable(path string) (*LifecycleTable, error) {
+	if path == "" {
+		return DefaultLifecycleTable(), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseLifecycleTable(file)
+}
+
+// guessedProducts returns the products without editions of a build, the
+// products a host only fingerprinted over NTLM may run.
+func (t *LifecycleTable) guessedProducts(build uint32) []LifecycleProduct {
+	var products []LifecycleProduct
+	for _, product := range t.Products {
+		if product.Build == build && len(product.Editions) == 0 {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+// product returns the product of a build. An empty product name, e.g. of
+// hosts only fingerprinted over NTLM, matches every guessed product of the
+// build, they are reported together with the latest end of support.
+func (t *LifecycleTable) product(build uint32, productName string) (LifecycleProduct, bool) {
+	if productName == "" {
+		var result LifecycleProduct
+		var names []string
+		for _, product := range t.guessedProducts(build) {
+			if !slices.Contains(names, product.Product) {
+				names = append(names, product.Product)
+			}
+			if product.EndOfSupport > result.EndOfSupport {
+				result = product
+			}
+		}
+		result.Product = strings.Join(names, " / ")
+		return result, len(names) > 0
+	}
+
+	var candidates []LifecycleProduct
+	for _, product := range t.Products {
+		if product.Build == build {
+			candidates = append(candidates, product)
+		}
+	}
+
+	// the registry product name of Windows 11 still starts with Windows 10
+	server := strings.Contains(productName, "Server")
+	var fallback *LifecycleProduct
+	for i, product := range candidates {
+		if product.Server != server {
+			continue
+		}
+		if len(product.Editions) == 0 {
+			if fallback == nil {
+				fallback = &candidates[i]
+			}
+			continue
+		}
+		if slices.ContainsFunc(product.Editions, func(edition string) bool { return strings.Contains(productName, edition) }) {
+			return product, true
+		}
+	}
+	if fallback == nil {
+		return LifecycleProduct{}, false
+	}
+	return *fallback, true
+}
+
+// patchDate returns the release date of the latest update of the table that
+// is installed with ubr, empty if the host is older than every known update.
+// It is empty as well if ubr is newer than every known update, the host was
+// patched after the table was updated.
+func (t *LifecycleTable) patchDate(build, ubr uint32) string {
+	var latest, newest LifecycleUpdate
+	for _, update := range t.Updates {
+		if update.Build != build {
+			continue
+		}
+		if update.UBR <= ubr && update.UBR >= latest.UBR {
+			latest = update
+		}
+		newest.UBR = max(newest.UBR, update.UBR)
+	}
+	if ubr > newest.UBR {
+		return ""
+	}
+	return latest.Released
+}
+
+// endOfLife reports whether product is past its end of support, the day after
+// its end of support date.
+func (p LifecycleProduct) endOfLife(now time.Time) bool {
+	endOfSupport, _ := time.Parse(lifecycleDateLayout, p.EndOfSupport)
+	return !now.Before(endOfSupport.AddDate(0, 0, 1))
+}
+
+// Assess returns the operating system of a Windows version, nil if the
+// version lacks a build number. Products are end of life the day after their
+// end of support. Versions without a product name are ambiguous and not end
+// of life if only some of the guessed products of the build are.
+func (t *LifecycleTable) Assess(version WindowsVersion, now time.Time) *OperatingSystem {
+	build, err := strconv.ParseUint(version.Build, 10, 32)
+	if err != nil || build == 0 {
+		return nil
+	}
+	result := &OperatingSystem{Product: strings.TrimSpace(version.ProductName), Build: uint32(build), UBR: version.UBR}
+	if t == nil {
+		return result
+	}
+
+	product, found := t.product(result.Build, result.Product)
+	if found {
+		guessed := result.Product == ""
+		result.Product = product.Product
+		result.EndOfSupport = product.EndOfSupport
+		result.EOL = product.endOfLife(now)
+		if guessed {
+			for _, candidate := range t.guessedProducts(result.Build) {
+				if candidate.endOfLife(now) != result.EOL {
+					result.EOL, result.Ambiguous = false, true
+				}
+			}
+		}
+	}
+	if result.UBR > 0 {
+		result.PatchDate = t.patchDate(result.Build, result.UBR)
+		if patched, err := time.Parse(lifecycleDateLayout, result.PatchDate); err == nil {
+			result.PatchAge = int(now.Sub(patched).Hours() / 24)
+		}
+	}
+	return result
+}
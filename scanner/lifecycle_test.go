@@ -0,0 +1,135 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLifecycleTableAssess(t *testing.T) {
+	table := DefaultLifecycleTable()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		version   WindowsVersion
+		product   string
+		eol       bool
+		ambiguous bool
+		patched   string
+		patchAge  int
+	}{
+		{
+			name:     "server with known update",
+			version:  WindowsVersion{ProductName: "Windows Server 2016 Standard", Build: "14393", UBR: 6614},
+			product:  "Windows Server 2016",
+			patched:  "2024-01-09",
+			patchAge: 1014,
+		},
+		{
+			name:     "server with update newer than the table",
+			version:  WindowsVersion{ProductName: "Windows Server 2016 Standard", Build: "14393", UBR: 6700},
+			product:  "Windows Server 2016",
+			patchAge: 0,
+		},
+		{
+			name:     "server between known updates",
+			version:  WindowsVersion{ProductName: "Windows Server 2016 Standard", Build: "14393", UBR: 6500},
+			product:  "Windows Server 2016",
+			patched:  "2023-11-14",
+			patchAge: 1070,
+		},
+		{
+			name:    "end of life server",
+			version: WindowsVersion{ProductName: "Windows Server 2012 R2 Datacenter", Build: "9600", UBR: 21620},
+			product: "Windows Server 2012 R2",
+			eol:     true,
+		},
+		{
+			name:     "windows 11 reports itself as windows 10",
+			version:  WindowsVersion{ProductName: "Windows 10 Enterprise", Build: "22631", UBR: 3007},
+			product:  "Windows 11 23H2",
+			patched:  "2024-01-09",
+			patchAge: 1014,
+		},
+		{
+			name:    "ltsc edition",
+			version: WindowsVersion{ProductName: "Windows 10 Enterprise LTSC 2019", Build: "17763"},
+			product: "Windows 10 Enterprise LTSC 2019",
+		},
+		{
+			name:    "home edition",
+			version: WindowsVersion{ProductName: "Windows 10 Pro", Build: "17763"},
+			product: "Windows 10 1809",
+			eol:     true,
+		},
+		{
+			// client and server share the build, only the server is still supported
+			name:      "ntlm guess",
+			version:   guessedWindowsVersion("Windows NT 10.0 Build 17763"),
+			product:   "Windows 10 1809 / Windows Server 2019",
+			ambiguous: true,
+		},
+		{
+			// client and server of the build are both past their end of support
+			name:    "ntlm guess of end of life build",
+			version: guessedWindowsVersion("Windows NT 6.3 Build 9600"),
+			product: "Windows 8.1 / Windows Server 2012 R2",
+			eol:     true,
+		},
+		{
+			name:    "unknown build",
+			version: WindowsVersion{ProductName: "Windows 12", Build: "30000"},
+			product: "Windows 12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os := table.Assess(tt.version, now)
+			if os == nil {
+				t.Fatal("expected an operating system")
+			}
+			if os.Product != tt.product || os.EOL != tt.eol || os.Ambiguous != tt.ambiguous || os.PatchDate != tt.patched {
+				t.Errorf("unexpected operating system %+v", os)
+			}
+			if os.PatchAge != tt.patchAge {
+				t.Errorf("expected patch age %d, got %d", tt.patchAge, os.PatchAge)
+			}
+		})
+	}
+
+	if table.Assess(WindowsVersion{}, now) != nil {
+		t.Error("expected no operating system without build")
+	}
+	// the end of support day itself is still supported
+	ltsb := WindowsVersion{ProductName: "Windows 10 Enterprise 2016 LTSB", Build: "14393"}
+	if table.Assess(ltsb, time.Date(2026, 10, 13, 23, 0, 0, 0, time.UTC)).EOL {
+		t.Error("expected support on the end of support day")
+	}
+	if !table.Assess(ltsb, now).EOL {
+		t.Error("expected end of life after the end of support day")
+	}
+}
+
+func TestParseLifecycleTable(t *testing.T) {
+	table, err := ParseLifecycleTable(strings.NewReader(`{"updated": "2030-01-01", "products": [{"product": "Windows Server 2030", "build": 30000, "server": true, "released": "2030-01-01", "end_of_support": "2040-01-01"}]}`))
+	if err != nil || len(table.Products) != 1 {
+		t.Fatalf("unexpected table %+v: %v", table, err)
+	}
+	if _, err := ParseLifecycleTable(strings.NewReader(`{"products": [{"product": "Windows", "build": 1, "released": "2030", "end_of_support": "2040-01-01"}]}`)); err == nil {
+		t.Error("expected an invalid date error")
+	}
+
+	output := SprintOperatingSystem(&OperatingSystem{EOL: true, EndOfSupport: "2023-10-10", PatchDate: "2022-05-10"})
+	if output != " (eol:2023-10-10) (patched:2022-05-10)" {
+		t.Errorf("unexpected output %q", output)
+	}
+	output = SprintOperatingSystem(&OperatingSystem{Product: "Windows 10 1809 / Windows Server 2019", Ambiguous: true})
+	if output != " (eol:ambiguous Windows 10 1809 / Windows Server 2019)" {
+		t.Errorf("unexpected output %q", output)
+	}
+	run := SharefinderRun{Hosts: []Host{{IP: "10.0.0.1", OS: &OperatingSystem{EOL: true}}, {IP: "10.0.0.2", OS: &OperatingSystem{}}, {IP: "10.0.0.3"}}}
+	if run.UnsupportedOSHostCount() != 1 {
+		t.Errorf("expected 1 unsupported host, got %d", run.UnsupportedOSHostCount())
+	}
+}
@@ -0,0 +1,233 @@
+package scanner
+
+import (
+	"errors"
+	"github.com/vflame6/sharefinder/logger"
+	"slices"
+	"strings"
+)
+
+// Identities of the matrix command besides the accounts
+const (
+	IdentityNull  = "null"
+	IdentityGuest = "guest"
+)
+
+// matrixIdentity is an identity the matrix command enumerates every host with.
+// Accounts of the credentials file are passed as per-host credential.
+type matrixIdentity struct {
+	name       string
+	options    *Options
+	credential *Credential
+}
+
+// matrixIdentities returns the identities of the matrix command from the least
+// to the most privileged: null, guest, the --username account and the accounts
+// of the credentials file.
+func matrixIdentities(options *Options) []matrixIdentity {
+	anonymous := *options
+	anonymous.Username, anonymous.Password, anonymous.Hash, anonymous.HashBytes = "", "", "", nil
+	anonymous.Domain, anonymous.LocalAuth, anonymous.Kerberos, anonymous.LockoutGuard = "", false, false, nil
+
+	null := anonymous
+	null.NullSession = true
+	guest := anonymous
+	guest.Guest = true
+	guest.Username = options.GuestUsername
+	identities := []matrixIdentity{{name: IdentityNull, options: &null}, {name: IdentityGuest, options: &guest}}
+
+	authenticated := *options
+	authenticated.NullSession, authenticated.Guest = false, false
+	if options.Username != "" {
+		account := Credential{Domain: options.Domain, Username: options.Username}
+		identities = append(identities, matrixIdentity{name: account.String(), options: &authenticated})
+	}
+	for _, credential := range options.Credentials {
+		identities = append(identities, matrixIdentity{name: credential.String(), options: &authenticated, credential: credential})
+	}
+	return identities
+}
+
+// pinTransport returns the host with only the port of the transport left
+// open, so later identities connect the way the first one did.
+func pinTransport(host DNHost, transport string, options *Options) DNHost {
+	switch transport {
+	case TransportDirect:
+		host.Ports = []int{options.SmbPort}
+	case TransportNetBIOS:
+		host.Ports = []int{netbiosSessionPort}
+	}
+	return host
+}
+
+// enumerateHostMatrix enumerates the host with every identity of the matrix
+// command and merges the results like enumerateHostWithCredentials. The most
+// privileged identities go first, the names of the shares they find are
+// brute-forced with the others in case their srvsvc access is denied. The
+// host is fingerprinted once and the transport of the first session is reused,
+// every identity still needs its own connection to set up its session.
+func enumerateHostMatrix(host DNHost, options *Options) (Host, error) {
+	var merged Host
+	identities := matrixIdentities(options)
+	results := make([]CredentialResult, len(identities))
+	tried := make([]bool, len(identities))
+	err := newHostError(HostStatusSkipped, errors.New("no usable identities left"))
+
+	for i := len(identities) - 1; i >= 0; i-- {
+		identity := identities[i]
+		if identity.credential != nil && !options.CredentialTracker.Acquire(identity.credential) {
+			continue
+		}
+
+		identityOptions := *identity.options
+		target := host
+		target.Credential = identity.credential
+		if merged.IP != "" {
+			identityOptions.Fingerprint = false
+			identityOptions.ShareWordlist = mergeShareNames(slices.Clone(options.ShareWordlist), shareNames(merged.Shares))
+			target = pinTransport(target, merged.Transport, options)
+		}
+		hostResult, enumErr := enumerateHost(target, &identityOptions)
+		authenticated := hostResult.IP != ""
+		if identity.credential != nil {
+			var authErr error
+			if !authenticated {
+				authErr = enumErr
+			}
+			if reason, stopped := options.CredentialTracker.Record(identity.credential, authErr); stopped {
+				logger.Warnf("Stopped using %s: %s", identity.credential, reason)
+			}
+		}
+
+		tried[i] = true
+		results[i] = CredentialResult{Credential: identity.name, Success: authenticated}
+		if enumErr != nil {
+			results[i].Error = enumErr.Error()
+		}
+		if !authenticated {
+			err = enumErr
+			if !isNTStatus(enumErr) {
+				// the host is unreachable, other identities would fail the same way
+				break
+			}
+			continue
+		}
+		results[i].Admin = hostResult.Admin
+		results[i].Session = hostResult.Session
+		merged.merge(hostResult, identity.name)
+	}
+
+	if merged.IP == "" {
+		return merged, err
+	}
+	for i, result := range results {
+		if tried[i] {
+			merged.Credentials = append(merged.Credentials, result)
+		}
+	}
+	return merged, nil
+}
+
+// shareNames returns the names of shares without duplicates.
+func shareNames(shares []Share) []string {
+	var names []string
+	for _, share := range shares {
+		names = mergeShareNames(names, []string{share.ShareName})
+	}
+	return names
+}
+
+// AccessCell is the access of one identity to a share
+type AccessCell struct {
+	Identity      string
+	Authenticated bool // false if the identity couldn't log on to the host
+	Read          bool
+	Write         bool
+}
+
+// String returns RW, R, W, - without access, or n/a if the identity couldn't
+// log on.
+func (c AccessCell) String() string {
+	switch {
+	case !c.Authenticated:
+		return "n/a"
+	case c.Read && c.Write:
+		return "RW"
+	case c.Read:
+		return "R"
+	case c.Write:
+		return "W"
+	default:
+		return "-"
+	}
+}
+
+// AccessMatrixRow is the access of every identity to a share of a host
+type AccessMatrixRow struct {
+	Host  Host
+	Share string
+	Cells []AccessCell
+}
+
+// AccessMatrix returns a row per share of the host with the access of every
+// identity in identities, from the shares attributed to the identities.
+func (h Host) AccessMatrix(identities []string) []AccessMatrixRow {
+	var rows []AccessMatrixRow
+	for _, name := range shareNames(h.Shares) {
+		row := AccessMatrixRow{Host: h, Share: name}
+		for _, identity := range identities {
+			cell := AccessCell{Identity: identity}
+			for _, result := range h.Credentials {
+				if result.Credential == identity {
+					cell.Authenticated = result.Success
+				}
+			}
+			for _, share := range h.Shares {
+				if share.Credential == identity && strings.EqualFold(share.ShareName, name) {
+					cell.Read = cell.Read || share.ReadPermission
+					cell.Write = cell.Write || share.WritePermission
+				}
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Identities returns the identities the host was enumerated with.
+func (h Host) Identities() []string {
+	var identities []string
+	for _, result := range h.Credentials {
+		identities = append(identities, result.Credential)
+	}
+	return identities
+}
+
+// MatrixIdentities returns the identities of all hosts in the order they
+// were first tried, the columns of the access matrix.
+func (r *SharefinderRun) MatrixIdentities() []string {
+	var identities []string
+	for _, h := range r.Hosts {
+		for _, identity := range h.Identities() {
+			if !slices.Contains(identities, identity) {
+				identities = append(identities, identity)
+			}
+		}
+	}
+	return identities
+}
+
+// AccessMatrix returns the access matrix rows of all hosts enumerated with
+// several identities.
+func (r *SharefinderRun) AccessMatrix() []AccessMatrixRow {
+	identities := r.MatrixIdentities()
+	var rows []AccessMatrixRow
+	for _, h := range r.Hosts {
+		if len(h.Credentials) == 0 {
+			continue
+		}
+		rows = append(rows, h.AccessMatrix(identities)...)
+	}
+	return rows
+}
@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatrixIdentities(t *testing.T) {
+	options := &Options{
+		Username:      "alice",
+		Password:      "secret",
+		Domain:        "CORP",
+		GuestUsername: "anonymous_test",
+		LockoutGuard:  NewLockoutGuard(),
+		Credentials:   []*Credential{{Domain: "CORP", Username: "bob", Source: CredentialSourceFile}},
+	}
+	identities := matrixIdentities(options)
+
+	var names []string
+	for _, identity := range identities {
+		names = append(names, identity.name)
+	}
+	if !slices.Equal(names, []string{IdentityNull, IdentityGuest, `CORP\alice`, `CORP\bob`}) {
+		t.Fatalf("unexpected identities %v", names)
+	}
+
+	null, guest := identities[0].options, identities[1].options
+	if !null.NullSession || null.Username != "" || null.Password != "" || null.LockoutGuard != nil {
+		t.Errorf("null identity keeps credentials: %+v", null)
+	}
+	if !guest.Guest || guest.Username != "anonymous_test" || guest.Password != "" || guest.Domain != "" || guest.LockoutGuard != nil {
+		t.Errorf("unexpected guest identity: %+v", guest)
+	}
+	if auth := identities[2].options; auth.NullSession || auth.Guest || auth.Username != "alice" || auth.LockoutGuard == nil {
+		t.Errorf("unexpected authenticated identity: %+v", auth)
+	}
+	if identities[3].credential != options.Credentials[0] {
+		t.Errorf("expected the credentials file account as per-host credential")
+	}
+
+	// without an account only the anonymous identities are tried
+	if got := matrixIdentities(&Options{}); len(got) != 2 {
+		t.Errorf("expected null and guest, got %d identities", len(got))
+	}
+}
+
+func TestPinTransport(t *testing.T) {
+	options := &Options{SmbPort: 445}
+	if got := pinTransport(DNHost{}, TransportDirect, options); !slices.Equal(got.Ports, []int{445}) {
+		t.Errorf("expected port 445, got %v", got.Ports)
+	}
+	if got := pinTransport(DNHost{}, TransportNetBIOS, options); !slices.Equal(got.Ports, []int{netbiosSessionPort}) {
+		t.Errorf("expected port 139, got %v", got.Ports)
+	}
+	if got := pinTransport(DNHost{Ports: []int{139, 445}}, "", options); len(got.Ports) != 2 {
+		t.Errorf("expected ports to be kept, got %v", got.Ports)
+	}
+}
+
+func TestAccessMatrix(t *testing.T) {
+	host := Host{
+		IP: "10.0.0.1",
+		Credentials: []CredentialResult{
+			{Credential: IdentityNull, Success: true},
+			{Credential: IdentityGuest, Success: false},
+			{Credential: `CORP\alice`, Success: true},
+		},
+		Shares: []Share{
+			{ShareName: "Data", ReadPermission: true, Credential: `CORP\alice`},
+			{ShareName: "Public", ReadPermission: true, WritePermission: true, Credential: `CORP\alice`},
+			{ShareName: "data", ReadPermission: true, Credential: IdentityNull},
+			{ShareName: "Public", Credential: IdentityNull},
+		},
+	}
+	run := SharefinderRun{Hosts: []Host{host, {IP: "10.0.0.2", Status: HostStatusAuthFailed}}}
+
+	identities := run.MatrixIdentities()
+	if !slices.Equal(identities, []string{IdentityNull, IdentityGuest, `CORP\alice`}) {
+		t.Fatalf("unexpected identities %v", identities)
+	}
+
+	rows := run.AccessMatrix()
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	var got []string
+	for _, row := range rows {
+		cells := []string{row.Share}
+		for _, cell := range row.Cells {
+			cells = append(cells, cell.String())
+		}
+		got = append(got, strings.Join(cells, " "))
+	}
+	want := []string{"Data R n/a R", "Public - n/a RW"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if matrix := SprintAccessMatrix(host, []string{"Public"}); !strings.Contains(matrix, "Data") || strings.Contains(matrix, "Public") {
+		t.Errorf("unexpected matrix:\n%s", matrix)
+	}
+}
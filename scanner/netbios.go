@@ -0,0 +1,369 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/vflame6/sharefinder/logger"
+	"golang.org/x/net/proxy"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMB transports recorded in the transport attribute of a host
+const (
+	TransportDirect  = "direct"  // SMB over TCP, usually port 445
+	TransportNetBIOS = "netbios" // SMB over the NetBIOS session service on port 139
+)
+
+const (
+	// netbiosNameServicePort is the UDP port of NBNS node status queries
+	netbiosNameServicePort = 137
+	// netbiosAnyServer is the called name accepted by Windows and Samba servers
+	// regardless of their NetBIOS name
+	netbiosAnyServer = "*SMBSERVER"
+	// netbiosCallingName is the NetBIOS name of the scanner in session requests
+	netbiosCallingName = "SHAREFINDER"
+	// netbiosServerSuffix is the name suffix of the file server service
+	netbiosServerSuffix = 0x20
+
+	nbssSessionRequest  = 0x81
+	nbssPositiveSession = 0x82
+	nbssNegativeSession = 0x83
+)
+
+// smbTransport is a way to reach the SMB service of a host
+type smbTransport struct {
+	Name   string
+	Port   int
+	Dialer proxy.Dialer
+}
+
+// smbTransports returns the transports to try with host in order. The NetBIOS
+// session service on port 139 is a fallback for --smb-port unless disabled,
+// ports the sweep found closed are left out.
+func smbTransports(host DNHost, options *Options) []smbTransport {
+	var transports []smbTransport
+	if options.SmbPort == netbiosSessionPort {
+		transports = append(transports, netbiosTransport(host, options))
+	} else {
+		if host.hasPort(options.SmbPort) {
+			transports = append(transports, smbTransport{Name: TransportDirect, Port: options.SmbPort, Dialer: options.ProxyDialer})
+		}
+		if options.NetBIOSFallback && host.hasPort(netbiosSessionPort) {
+			transports = append(transports, netbiosTransport(host, options))
+		}
+	}
+	return transports
+}
+
+func netbiosTransport(host DNHost, options *Options) smbTransport {
+	return smbTransport{
+		Name: TransportNetBIOS,
+		Port: netbiosSessionPort,
+		Dialer: &netbiosDialer{
+			host:        host,
+			timeout:     options.Timeout,
+			proxyDialer: options.ProxyDialer,
+		},
+	}
+}
+
+// connectSMB calls connect with the transports of host until one reaches the
+// SMB service and returns that transport. Authentication errors are returned
+// without trying other transports. After a timeout only transports on ports
+// the sweep found open are tried, a filtered host would time out on every
+// port. If no transport reaches the service the error of the first one is
+// returned.
+func connectSMB(host DNHost, options *Options, connect func(transport smbTransport) error) (smbTransport, error) {
+	transports := smbTransports(host, options)
+	if len(transports) == 0 {
+		return smbTransport{}, newHostError(HostStatusUnreachable, fmt.Errorf("port %d is closed, open ports: %s", options.SmbPort, formatPorts(host.Ports)))
+	}
+	var firstErr error
+	timedOut := false
+	for _, transport := range transports {
+		if timedOut && !slices.Contains(host.Ports, transport.Port) {
+			continue
+		}
+		err := connect(transport)
+		if err == nil {
+			return transport, nil
+		}
+		switch HostStatus(err) {
+		case HostStatusUnreachable, HostStatusTimeout, HostStatusNegotiation:
+		default:
+			return transport, err
+		}
+		logger.Debugf("Failed to reach %s over %s transport on port %d: %v", host.IP.String(), transport.Name, transport.Port, err)
+		timedOut = timedOut || HostStatus(err) == HostStatusTimeout
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return smbTransport{}, firstErr
+}
+
+// netbiosDialer connects to the NetBIOS session service and requests a session
+// with the first called name of the host the server accepts. It is passed as
+// the proxy dialer of SMB connections, dials go through proxyDialer if set.
+type netbiosDialer struct {
+	host        DNHost
+	timeout     time.Duration
+	proxyDialer proxy.Dialer
+	names       []string
+}
+
+func (d *netbiosDialer) Dial(network, address string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+func (d *netbiosDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.names == nil {
+		// the node status query leaves time to dial within the timeout
+		d.names = netbiosNames(d.host, d.proxyDialer == nil, min(d.timeout/2, time.Second))
+	}
+
+	var err error
+	for _, name := range d.names {
+		var conn net.Conn
+		conn, err = d.dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		err = requestNetBIOSSession(conn, name, d.timeout)
+		if err == nil {
+			logger.Debugf("NetBIOS session established with %s as %s", address, name)
+			return conn, nil
+		}
+		_ = conn.Close()
+		var rejected *netbiosSessionError
+		if !errors.As(err, &rejected) {
+			return nil, err
+		}
+		logger.Debugf("NetBIOS session with %s as %s rejected: %v", address, name, err)
+	}
+	return nil, err
+}
+
+func (d *netbiosDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.proxyDialer != nil {
+		if contextDialer, ok := d.proxyDialer.(proxy.ContextDialer); ok {
+			return contextDialer.DialContext(ctx, network, address)
+		}
+		return d.proxyDialer.Dial(network, address)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, network, address)
+}
+
+// netbiosNames returns the called names to request a session with host: the
+// first label of its DNS name, the file server name from an NBNS node status
+// query if queryNBNS is set, and *SMBSERVER.
+func netbiosNames(host DNHost, queryNBNS bool, timeout time.Duration) []string {
+	var names []string
+	if name := netbiosNameFromHostname(host.Hostname); name != "" {
+		names = append(names, name)
+	}
+	if queryNBNS {
+		name, err := queryNetBIOSName(host.IP, timeout)
+		if err != nil {
+			logger.Debugf("NBNS node status query of %s failed: %v", host.IP.String(), err)
+		} else if name != "" && (len(names) == 0 || names[0] != name) {
+			names = append(names, name)
+		}
+	}
+	return append(names, netbiosAnyServer)
+}
+
+// netbiosNameFromHostname returns the NetBIOS name of a DNS hostname, empty if
+// hostname is an IP address.
+func netbiosNameFromHostname(hostname string) string {
+	if hostname == "" || net.ParseIP(hostname) != nil {
+		return ""
+	}
+	name, _, _ := strings.Cut(hostname, ".")
+	name = strings.ToUpper(name)
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	return name
+}
+
+// netbiosSessionError is a negative session response of the server
+type netbiosSessionError struct {
+	Code byte
+}
+
+func (e *netbiosSessionError) Error() string {
+	switch e.Code {
+	case 0x80:
+		return "NetBIOS session rejected: not listening on called name"
+	case 0x81:
+		return "NetBIOS session rejected: not listening for calling name"
+	case 0x82:
+		return "NetBIOS session rejected: called name not present"
+	case 0x83:
+		return "NetBIOS session rejected: insufficient resources"
+	default:
+		return fmt.Sprintf("NetBIOS session rejected: error 0x%02x", e.Code)
+	}
+}
+
+// requestNetBIOSSession requests a session with calledName over conn.
+func requestNetBIOSSession(conn net.Conn, calledName string, timeout time.Duration) error {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(netbiosSessionRequest(calledName, netbiosCallingName)); err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("NetBIOS session request: %w", err)
+	}
+	trailer := make([]byte, binary.BigEndian.Uint16(header[2:4]))
+	if _, err := io.ReadFull(conn, trailer); err != nil {
+		return fmt.Errorf("NetBIOS session request: %w", err)
+	}
+
+	switch header[0] {
+	case nbssPositiveSession:
+		return nil
+	case nbssNegativeSession:
+		var code byte = 0x8f
+		if len(trailer) > 0 {
+			code = trailer[0]
+		}
+		return &netbiosSessionError{Code: code}
+	default:
+		return fmt.Errorf("NetBIOS session request: unexpected response type 0x%02x", header[0])
+	}
+}
+
+// netbiosSessionRequest returns a session request packet.
+func netbiosSessionRequest(calledName, callingName string) []byte {
+	called := encodeNetBIOSName(calledName, netbiosServerSuffix)
+	calling := encodeNetBIOSName(callingName, 0x00)
+	packet := []byte{nbssSessionRequest, 0, 0, 0}
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(called)+len(calling)))
+	packet = append(packet, called...)
+	return append(packet, calling...)
+}
+
+// encodeNetBIOSName returns the first level encoding of name with suffix
+// (RFC 1001 section 14.1) as a length prefixed label.
+func encodeNetBIOSName(name string, suffix byte) []byte {
+	raw := make([]byte, 16)
+	padding := byte(' ')
+	if name == "*" {
+		// the wildcard of node status queries is padded with zeros
+		padding = 0
+	}
+	for i := range raw[:15] {
+		raw[i] = padding
+	}
+	copy(raw[:15], strings.ToUpper(name))
+	raw[15] = suffix
+
+	encoded := make([]byte, 0, 34)
+	encoded = append(encoded, 32)
+	for _, b := range raw {
+		encoded = append(encoded, 'A'+(b>>4), 'A'+(b&0x0f))
+	}
+	return append(encoded, 0)
+}
+
+// queryNetBIOSName returns the file server name of ip from an NBNS node
+// status query.
+func queryNetBIOSName(ip net.IP, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(ip.String(), strconv.Itoa(netbiosNameServicePort)), timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 12)
+	if _, err := rand.Read(request[:2]); err != nil {
+		return "", err
+	}
+	binary.BigEndian.PutUint16(request[4:], 1) // QDCOUNT
+	request = append(request, encodeNetBIOSName("*", 0x00)...)
+	request = append(request, 0x00, 0x21, 0x00, 0x01) // NBSTAT, IN
+	if _, err := conn.Write(request); err != nil {
+		return "", err
+	}
+
+	response := make([]byte, 1500)
+	n, err := conn.Read(response)
+	if err != nil {
+		return "", err
+	}
+	if n < 2 || !bytes.Equal(response[:2], request[:2]) {
+		return "", errors.New("unexpected NBNS transaction id")
+	}
+	return parseNodeStatus(response[:n])
+}
+
+// parseNodeStatus returns the unique file server name of an NBNS node status
+// response, or the unique workstation name if the server name is missing.
+func parseNodeStatus(response []byte) (string, error) {
+	if len(response) < 12 || binary.BigEndian.Uint16(response[6:8]) == 0 {
+		return "", errors.New("invalid NBNS node status response")
+	}
+	// skip the answer name
+	offset := 12
+	for offset < len(response) {
+		length := int(response[offset])
+		if length == 0 {
+			offset++
+			break
+		}
+		if length&0xc0 == 0xc0 {
+			offset += 2
+			break
+		}
+		offset += 1 + length
+	}
+	// type, class, TTL and data length
+	offset += 10
+	if offset >= len(response) {
+		return "", errors.New("truncated NBNS node status response")
+	}
+	count := int(response[offset])
+	offset++
+
+	var workstation string
+	for i := 0; i < count && offset+18 <= len(response); i++ {
+		entry := response[offset : offset+18]
+		offset += 18
+		if binary.BigEndian.Uint16(entry[16:18])&0x8000 != 0 {
+			// group name
+			continue
+		}
+		name := strings.TrimRight(string(entry[:15]), " \x00")
+		switch entry[15] {
+		case netbiosServerSuffix:
+			return name, nil
+		case 0x00:
+			if workstation == "" {
+				workstation = name
+			}
+		}
+	}
+	return workstation, nil
+}
@@ -0,0 +1,218 @@
+package scanner
+
+import (
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEncodeNetBIOSName(t *testing.T) {
+	encoded := encodeNetBIOSName(netbiosAnyServer, netbiosServerSuffix)
+	if len(encoded) != 34 || encoded[0] != 32 || encoded[33] != 0 {
+		t.Fatalf("unexpected label %q", encoded)
+	}
+	if got := string(encoded[1:33]); got != "CKFDENECFDEFFCFGEFFCCACACACACACA" {
+		t.Errorf("unexpected encoding %s", got)
+	}
+	// the node status wildcard is padded with zeros
+	if got := string(encodeNetBIOSName("*", 0x00)[1:33]); got != "CKAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA" {
+		t.Errorf("unexpected wildcard encoding %s", got)
+	}
+}
+
+func TestNetBIOSNameFromHostname(t *testing.T) {
+	tests := map[string]string{
+		"fs01.corp.local":            "FS01",
+		"FILESERVER":                 "FILESERVER",
+		"averyveryverylongname.corp": "AVERYVERYVERYLO",
+		"10.0.0.1":                   "",
+		"":                           "",
+	}
+	for hostname, expected := range tests {
+		if got := netbiosNameFromHostname(hostname); got != expected {
+			t.Errorf("%q: expected %q, got %q", hostname, expected, got)
+		}
+	}
+}
+
+func TestParseNodeStatus(t *testing.T) {
+	response := make([]byte, 12)
+	response[7] = 1 // ANCOUNT
+	response = append(response, encodeNetBIOSName("*", 0x00)...)
+	response = append(response, 0, 0x21, 0, 1, 0, 0, 0, 0, 0, 0x41)
+	response = append(response, 3)
+	for _, entry := range []struct {
+		name   string
+		suffix byte
+		group  bool
+	}{{"CORP", 0x00, true}, {"FS01", 0x00, false}, {"FS01", 0x20, false}} {
+		raw := []byte(entry.name + "               ")[:15]
+		raw = append(raw, entry.suffix, 0x04, 0x00)
+		if entry.group {
+			raw[16] |= 0x80
+		}
+		response = append(response, raw...)
+	}
+
+	name, err := parseNodeStatus(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "FS01" {
+		t.Errorf("expected FS01, got %q", name)
+	}
+
+	if _, err := parseNodeStatus(response[:12]); err == nil {
+		t.Error("expected an error for a truncated response")
+	}
+}
+
+func TestNetBIOSDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// reject every called name but *SMBSERVER
+	accepted := string(encodeNetBIOSName(netbiosAnyServer, netbiosServerSuffix))
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			request := make([]byte, 72)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				conn.Close()
+				continue
+			}
+			if string(request[4:38]) == accepted {
+				_, _ = conn.Write([]byte{nbssPositiveSession, 0, 0, 0})
+			} else {
+				_, _ = conn.Write([]byte{nbssNegativeSession, 0, 0, 1, 0x82})
+			}
+			conn.Close()
+		}
+	}()
+
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(listener.Addr().(*net.TCPAddr).Port))
+	dialer := &netbiosDialer{timeout: time.Second, names: []string{"FS01", netbiosAnyServer}}
+	conn, err := dialer.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	dialer = &netbiosDialer{timeout: time.Second, names: []string{"FS01"}}
+	_, err = dialer.Dial("tcp", address)
+	var rejected *netbiosSessionError
+	if !errors.As(err, &rejected) || rejected.Code != 0x82 {
+		t.Errorf("expected a called name rejection, got %v", err)
+	}
+}
+
+func TestSMBTransports(t *testing.T) {
+	names := func(transports []smbTransport) []string {
+		var result []string
+		for _, transport := range transports {
+			result = append(result, transport.Name+":"+strconv.Itoa(transport.Port))
+		}
+		return result
+	}
+
+	options := &Options{SmbPort: 445, NetBIOSFallback: true}
+	tests := []struct {
+		ports    []int
+		expected []string
+	}{
+		{nil, []string{"direct:445", "netbios:139"}},
+		{[]int{445}, []string{"direct:445"}},
+		{[]int{139}, []string{"netbios:139"}},
+		{[]int{}, nil},
+	}
+	for _, test := range tests {
+		got := names(smbTransports(DNHost{Ports: test.ports}, options))
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("ports %v: expected %v, got %v", test.ports, test.expected, got)
+		}
+	}
+
+	options.NetBIOSFallback = false
+	if got := names(smbTransports(DNHost{}, options)); len(got) != 1 || got[0] != "direct:445" {
+		t.Errorf("expected only the direct transport, got %v", got)
+	}
+	options.SmbPort = 139
+	if got := names(smbTransports(DNHost{}, options)); len(got) != 1 || got[0] != "netbios:139" {
+		t.Errorf("expected only the NetBIOS transport, got %v", got)
+	}
+}
+
+func TestConnectSMB(t *testing.T) {
+	options := &Options{SmbPort: 445, NetBIOSFallback: true}
+	refused := newHostError(HostStatusUnreachable, errors.New("connection refused"))
+
+	var tried []string
+	transport, err := connectSMB(DNHost{}, options, func(transport smbTransport) error {
+		tried = append(tried, transport.Name)
+		if transport.Name == TransportDirect {
+			return refused
+		}
+		return nil
+	})
+	if err != nil || transport.Name != TransportNetBIOS || len(tried) != 2 {
+		t.Errorf("expected a NetBIOS fallback, got %s %v after %v", transport.Name, err, tried)
+	}
+
+	// authentication failures must not be retried on another transport
+	tried = nil
+	_, err = connectSMB(DNHost{}, options, func(transport smbTransport) error {
+		tried = append(tried, transport.Name)
+		return newHostError(HostStatusAuthFailed, errors.New("logon failure"))
+	})
+	if HostStatus(err) != HostStatusAuthFailed || len(tried) != 1 {
+		t.Errorf("expected a single auth failure, got %v after %v", err, tried)
+	}
+
+	// the error of the configured port is reported
+	_, err = connectSMB(DNHost{}, options, func(transport smbTransport) error {
+		if transport.Name == TransportDirect {
+			return refused
+		}
+		return newHostError(HostStatusTimeout, errors.New("i/o timeout"))
+	})
+	if err != refused {
+		t.Errorf("expected the direct transport error, got %v", err)
+	}
+
+	// a timeout is only retried on port 139 if the sweep found it open
+	tried = nil
+	timeout := newHostError(HostStatusTimeout, errors.New("i/o timeout"))
+	_, err = connectSMB(DNHost{}, options, func(transport smbTransport) error {
+		tried = append(tried, transport.Name)
+		return timeout
+	})
+	if err != timeout || len(tried) != 1 {
+		t.Errorf("expected no fallback after a timeout, got %v after %v", err, tried)
+	}
+	tried = nil
+	transport, err = connectSMB(DNHost{Ports: []int{445, 139}}, options, func(transport smbTransport) error {
+		tried = append(tried, transport.Name)
+		if transport.Name == TransportDirect {
+			return timeout
+		}
+		return nil
+	})
+	if err != nil || transport.Name != TransportNetBIOS || len(tried) != 2 {
+		t.Errorf("expected a NetBIOS fallback to the swept port, got %s %v after %v", transport.Name, err, tried)
+	}
+
+	_, err = connectSMB(DNHost{Ports: []int{}}, options, func(transport smbTransport) error { return nil })
+	if HostStatus(err) != HostStatusUnreachable {
+		t.Errorf("expected closed ports to be unreachable, got %v", err)
+	}
+}
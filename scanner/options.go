@@ -9,30 +9,82 @@ import (
 
 // Options is a struct to store scanner's configuration
 type Options struct {
-	CustomResolver     net.IP // --resolver
+	Accounts           bool               // --accounts (null and guest only)
+	AdminAudit         bool               // --admin-audit
+	AESKey             []byte             // --aes-key
+	BruteforceShares   bool               // --bruteforce-shares
+	CCache             string             // --ccache or KRB5CCNAME
+	CompareCredential  *Credential        // --compare-user (auth and hunt only)
+	CredentialTracker  *CredentialTracker // failed logons per account of Credentials
+	Credentials        []*Credential      // --credentials
+	CustomResolver     net.IP             // --resolver
 	DCHostname         string
+	DNSZones           bool   // --dns-zones (hunt only)
 	Domain             string // part of --username
 	DomainController   net.IP
+	DomainControllers  []DNHost // DCs located via DNS SRV records (hunt only)
 	Exclude            []string // --exclude
 	FileTXT            *os.File
 	FileXML            *os.File
-	Forest             bool   // --forest (hunt only)
-	Hash               string // --hashes
-	HashBytes          []byte // --hashes
+	Fingerprint        bool     // --fingerprint
+	Forest             bool     // --forest (hunt only)
+	GlobalCatalogs     []DNHost // GCs located via DNS SRV records (hunt only)
+	Guest              bool     // guest command
+	GuestUsername      string   // --guest-username (matrix only)
+	Hash               string   // --hashes
+	HashBytes          []byte   // --hashes
+	HuntTrusts         bool     // --hunt-trusts (hunt only)
+	Info               bool     // info command, no authentication
 	Kerberos           bool
-	List               bool // --list
-	LocalAuth          bool // --local-auth
+	KerberosDomains    []string             // realms besides Domain, e.g. forest domains (hunt only)
+	KerberosTickets    *KerberosTicketCache // shared between SMB connections
+	Keytab             string               // --keytab
+	Krb5Config         string               // --krb5-config or KRB5_CONFIG contents
+	LAPS               bool                 // --laps (hunt only)
+	Lifecycle          *LifecycleTable      // --lifecycle-table or the built-in table
+	LAPSUsername       string               // --laps-username (hunt only)
+	List               bool                 // --list
+	LocalAuth          bool                 // --local-auth
+	LockoutCheck       bool                 // --lockout-check
+	LockoutGuard       *LockoutGuard        // --lockout-check, nil if disabled or with --credentials
+	Matrix             bool                 // matrix command
 	NullSession        bool
 	OutputRawFileName  string
 	OutputXMLFileName  string
 	OutputHTML         bool // --html
 	OutputHTMLFileName string
-	Password           string       // --password
-	ProxyDialer        proxy.Dialer // --proxy
-	Recurse            bool         // --recurse
-	SmbPort            int          // --smb-port
+	NetBIOSFallback    bool          // --netbios-fallback
+	Pipes              bool          // --pipes
+	Password           string        // --password
+	ProxyDialer        proxy.Dialer  // --proxy
+	Recurse            bool          // --recurse
+	ResolverThreads    int           // --resolver-threads (hunt only)
+	RIDFirst           uint32        // --rid-range (null and guest only)
+	RIDLast            uint32        // --rid-range (null and guest only)
+	ShareWordlist      []string      // --share-wordlist and the built-in share names
+	SmbPort            int           // --smb-port
+	Sweep              bool          // --sweep
+	SweepOnly          bool          // --sweep-only
+	SweepThreads       int           // --sweep-threads
+	SweepTimeout       time.Duration // --sweep-timeout
 	Target             chan DNHost
 	Timeout            time.Duration // --timeout
+	Trusts             bool          // --trusts (hunt only)
 	Username           string        // part of --username
 	Writer             *OutputWriter
 }
+
+// KerberosCredentials returns the Kerberos key material and configuration set in the options
+func (o *Options) KerberosCredentials() KerberosCredentials {
+	krb := KerberosCredentials{
+		AESKey:     o.AESKey,
+		Keytab:     o.Keytab,
+		CCache:     o.CCache,
+		Krb5Config: o.Krb5Config,
+		Domains:    o.KerberosDomains,
+	}
+	if o.DomainController != nil && !o.DomainController.Equal(net.IPv4zero) {
+		krb.KDC = o.DomainController
+	}
+	return krb
+}
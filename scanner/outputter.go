@@ -121,8 +121,14 @@ func (o *OutputWriter) WriteXMLHost(host Host, writer io.Writer) error {
 	return bufWriter.Flush()
 }
 
-func (o *OutputWriter) WriteXMLFooter(timeEnd time.Time, writer io.Writer) error {
+func (o *OutputWriter) WriteXMLFooter(timeEnd time.Time, summary RunSummary, writer io.Writer) error {
+	summaryContent, err := xml.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
 	content := "</hosts>\n"
+	content += string(summaryContent) + "\n"
 	content += fmt.Sprintf(
 		"<time_end time=\"%s\" formatted_time=\"%s\"></time_end>",
 		timeEnd.Format("2006-01-02T15:04:05Z07:00"),
@@ -130,7 +136,7 @@ func (o *OutputWriter) WriteXMLFooter(timeEnd time.Time, writer io.Writer) error
 	content += "</SharefinderRun>"
 
 	bufWriter := bufio.NewWriter(writer)
-	_, err := bufWriter.WriteString(content)
+	_, err = bufWriter.WriteString(content)
 	if err != nil {
 		return err
 	}
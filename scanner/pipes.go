@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"errors"
+	"github.com/jfjallid/go-smb/smb"
+)
+
+// Named pipe probe results
+const (
+	PipeReachable = "reachable" // the pipe could be opened with the session
+	PipeDenied    = "denied"    // the pipe exists but the session can't open it
+)
+
+// PipeRiskCoercion marks interfaces that can be abused to coerce the host into
+// authenticating to an attacker, e.g. PrinterBug or PetitPotam
+const PipeRiskCoercion = "coercion"
+
+// wellKnownPipe is a named pipe and the RPC interface served over it
+type wellKnownPipe struct {
+	Name      string
+	Interface string
+	Risk      string
+}
+
+// wellKnownPipes are the named pipes probed on IPC$
+var wellKnownPipes = []wellKnownPipe{
+	{"spoolss", "MS-RPRN print spooler", PipeRiskCoercion},
+	{"efsrpc", "MS-EFSR encrypting file system", PipeRiskCoercion},
+	{"lsarpc", "MS-LSAD/MS-LSAT local security authority, MS-EFSR", PipeRiskCoercion},
+	{"lsass", "MS-EFSR encrypting file system", PipeRiskCoercion},
+	{"netdfs", "MS-DFSNM DFS namespace management", PipeRiskCoercion},
+	{"FssagentRpc", "MS-FSRVP file server VSS agent", PipeRiskCoercion},
+	{"samr", "MS-SAMR security account manager", ""},
+	{"netlogon", "MS-NRPC netlogon", ""},
+	{"winreg", "MS-RRP remote registry", ""},
+	{"svcctl", "MS-SCMR service control manager", ""},
+	{"atsvc", "MS-TSCH task scheduler", ""},
+	{"srvsvc", "MS-SRVS server service", ""},
+	{"wkssvc", "MS-WKST workstation service", ""},
+	{"eventlog", "MS-EVEN event log", ""},
+	{"InitShutdown", "MS-RSP remote shutdown", ""},
+	{"epmapper", "RPC endpoint mapper", ""},
+	{"ntsvcs", "MS-PNP plug and play", ""},
+}
+
+// PipeEnumeration is the outcome of probing the well-known named pipes of a host
+type PipeEnumeration struct {
+	Session string      `xml:"session,attr"`
+	Pipes   []NamedPipe `xml:"pipe"`
+}
+
+// NamedPipe is a named pipe that exists on a host
+type NamedPipe struct {
+	Name      string `xml:"name,attr"`
+	Interface string `xml:"interface,attr"`
+	Status    string `xml:"status,attr"`
+	Risk      string `xml:"risk,attr,omitempty"`
+}
+
+// Reachable returns the pipes the session could open.
+func (e *PipeEnumeration) Reachable() []NamedPipe {
+	var pipes []NamedPipe
+	for _, pipe := range e.Pipes {
+		if pipe.Status == PipeReachable {
+			pipes = append(pipes, pipe)
+		}
+	}
+	return pipes
+}
+
+// CoercionReachable reports whether the session could open a pipe of an
+// interface that allows authentication coercion.
+func (e *PipeEnumeration) CoercionReachable() bool {
+	for _, pipe := range e.Reachable() {
+		if pipe.Risk == PipeRiskCoercion {
+			return true
+		}
+	}
+	return false
+}
+
+// pipeStatus returns the probe result of opening a pipe, empty if the pipe
+// doesn't exist.
+func pipeStatus(err error) string {
+	switch {
+	case err == nil:
+		return PipeReachable
+	case errors.Is(err, smb.StatusMap[smb.StatusAccessDenied]):
+		return PipeDenied
+	default:
+		return ""
+	}
+}
+
+// ProbePipes opens the well-known named pipes on IPC$ and returns the ones
+// that exist. session is the session type the pipes were probed with.
+func (conn *Connection) ProbePipes(session string) (*PipeEnumeration, error) {
+	share := "IPC$"
+	if err := conn.session.TreeConnect(share); err != nil {
+		return nil, err
+	}
+	defer conn.session.TreeDisconnect(share)
+
+	result := &PipeEnumeration{Session: session}
+	for _, pipe := range wellKnownPipes {
+		f, err := conn.session.OpenFile(share, pipe.Name)
+		if err == nil {
+			f.CloseFile()
+		}
+		status := pipeStatus(err)
+		if status == "" {
+			continue
+		}
+		result.Pipes = append(result.Pipes, NamedPipe{Name: pipe.Name, Interface: pipe.Interface, Status: status, Risk: pipe.Risk})
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"errors"
+	"github.com/jfjallid/go-smb/smb"
+	"strings"
+	"testing"
+)
+
+func TestPipeStatus(t *testing.T) {
+	if got := pipeStatus(nil); got != PipeReachable {
+		t.Errorf("expected reachable, got %q", got)
+	}
+	if got := pipeStatus(smb.StatusMap[smb.StatusAccessDenied]); got != PipeDenied {
+		t.Errorf("expected denied, got %q", got)
+	}
+	if got := pipeStatus(smb.StatusMap[smb.StatusObjectNameNotFound]); got != "" {
+		t.Errorf("expected a missing pipe, got %q", got)
+	}
+	if got := pipeStatus(errors.New("broken pipe")); got != "" {
+		t.Errorf("expected a missing pipe, got %q", got)
+	}
+}
+
+func TestPipeEnumeration(t *testing.T) {
+	pipes := &PipeEnumeration{Session: SessionNull, Pipes: []NamedPipe{
+		{Name: "spoolss", Status: PipeDenied, Risk: PipeRiskCoercion},
+		{Name: "lsarpc", Status: PipeReachable, Risk: PipeRiskCoercion},
+		{Name: "samr", Status: PipeReachable},
+	}}
+	if len(pipes.Reachable()) != 2 || !pipes.CoercionReachable() {
+		t.Errorf("unexpected reachable pipes %+v", pipes.Reachable())
+	}
+	pipes.Pipes[1].Status = PipeDenied
+	if pipes.CoercionReachable() {
+		t.Error("expected no reachable coercion pipe")
+	}
+
+	output := SprintPipes(pipes)
+	if !strings.Contains(output, "spoolss") || !strings.Contains(output, "coercion") {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	run := SharefinderRun{Hosts: []Host{{IP: "10.0.0.1", Pipes: pipes}, {IP: "10.0.0.2", Pipes: &PipeEnumeration{}}}}
+	if len(run.PipeHosts()) != 1 || run.CoercionHostCount() != 0 {
+		t.Errorf("unexpected pipe hosts %d and coercion count %d", len(run.PipeHosts()), run.CoercionHostCount())
+	}
+}
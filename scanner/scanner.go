@@ -2,16 +2,25 @@ package scanner
 
 import (
 	"bufio"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jfjallid/go-smb/smb"
 	"github.com/vflame6/sharefinder/logger"
+	"golang.org/x/net/proxy"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ldapPageSize matches the default MaxPageSize of Active Directory
+const ldapPageSize = 1000
+
 // Scanner is a struct so store scanner's configuration and execute commands
 type Scanner struct {
 	Options     *Options
@@ -20,13 +29,36 @@ type Scanner struct {
 	TimeEnd     time.Time
 	Threads     int
 	Stop        chan bool
+	Summary     RunSummary
+
+	summaryMutex sync.Mutex
+
+	// replaced in tests to locate and connect to stub domain controllers
+	newResolver func(protocol string, resolverIP net.IP, timeout time.Duration, proxyDialer proxy.Dialer) *Resolver
+	dialLDAP    func(host net.IP, dcHostname string, useGC bool) (*LDAPConnection, error)
 }
 
 type DNHost struct {
-	Hostname string
-	IP       net.IP
+	Hostname   string
+	IP         net.IP
+	Source     string      // discovery source of the host, empty for user-specified targets
+	Credential *Credential // host-specific credential, e.g. from LAPS
+	Ports      []int       // open ports found by the sweep, nil if not swept
 }
 
+// Sources of domains searched by hunt
+const (
+	DomainSourcePrimary = "primary" // the user's domain
+	DomainSourceForest  = "forest"  // domains of the current forest
+	DomainSourceTrust   = "trust"   // trusted domains
+)
+
+// Discovery sources of hunt targets
+const (
+	SourceLDAP = "ldap" // computer objects
+	SourceDNS  = "dns"  // AD-integrated DNS zones
+)
+
 // NewScanner is a function to create new Scanner struct
 func NewScanner(options *Options, commandLine []string, timeStart time.Time, threads int) *Scanner {
 	return &Scanner{
@@ -35,6 +67,7 @@ func NewScanner(options *Options, commandLine []string, timeStart time.Time, thr
 		TimeStart:   timeStart,
 		Threads:     threads,
 		Stop:        make(chan bool),
+		newResolver: NewResolver,
 	}
 }
 
@@ -44,7 +77,7 @@ func (s *Scanner) CloseOutputter() {
 		_ = s.Options.FileTXT.Close()
 	}
 	if s.Options.FileXML != nil {
-		err := s.Options.Writer.WriteXMLFooter(s.TimeEnd, s.Options.FileXML)
+		err := s.Options.Writer.WriteXMLFooter(s.TimeEnd, s.Summary, s.Options.FileXML)
 		if err != nil {
 			logger.Error(err)
 		}
@@ -134,38 +167,53 @@ func (s *Scanner) ParseTargetsInMemory(targets []DNHost) {
 
 // RunSMBEnumeration is executed by auth command
 func (s *Scanner) RunSMBEnumeration(wg *sync.WaitGroup) {
+	if s.Options.Kerberos && s.Options.KerberosTickets == nil {
+		s.Options.KerberosTickets = NewKerberosTicketCache(s.Options)
+	}
+	var targets <-chan DNHost = s.Options.Target
+	if s.Options.Sweep {
+		targets = s.runSweep(targets)
+	}
+	if s.Options.SweepOnly {
+		wg.Add(1)
+		go liveHostThread(s.Options, targets, wg)
+		return
+	}
 	for i := 0; i < s.Threads; i++ {
 		wg.Add(1)
-		go smbThread(s.Stop, s.Options, wg)
+		go smbThread(s.Stop, s.Options, targets, wg)
 	}
 }
 
-// RunEnumerateDomainComputers is executed by hunt command to get a list of hosts
-func (s *Scanner) RunEnumerateDomainComputers() ([]DNHost, error) {
+// RunEnumerateDomainComputers is executed by hunt command to discover targets.
+// Computers are read from LDAP page by page and handed to a bounded pool of DNS
+// resolvers which feed Options.Target directly, so SMB enumeration starts while
+// the directory is still being read. The targets channel is always closed on
+// return. The number of targets sent for enumeration is returned.
+func (s *Scanner) RunEnumerateDomainComputers() (int, error) {
+	defer close(s.Options.Target)
+
 	// Regular LDAP for forest discovery: the GC's partial attribute set excludes
 	// crossRef.systemFlags, so a config-NC crossRef search on 3268 returns 0 hits.
-	ldapConn, err := NewLDAPConnection(
-		s.Options.DomainController,
-		s.Options.Username,
-		s.Options.Password,
-		s.Options.Hash,
-		strings.ToLower(s.Options.Domain),
-		s.Options.Timeout,
-		s.Options.ProxyDialer,
-		s.Options.Kerberos,
-		s.Options.DCHostname,
-		false,
-	)
+	ldapConn, err := s.connectDomainController()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	defer ldapConn.Close()
 
+	if err = s.applyLockoutPolicy(ldapConn); err != nil {
+		return 0, err
+	}
+
 	var searchBases []DomainPartition
 	if s.Options.Forest {
 		searchBases, err = ldapConn.SearchForestDomains()
 		if err != nil {
-			return nil, err
+			return 0, err
+		}
+		// every forest domain is a Kerberos realm reachable through referrals
+		for _, searchBase := range searchBases {
+			s.addKerberosDomain(searchBase.Name)
 		}
 	} else {
 		searchBases = []DomainPartition{{
@@ -175,35 +223,22 @@ func (s *Scanner) RunEnumerateDomainComputers() ([]DNHost, error) {
 	}
 
 	if len(searchBases) == 0 {
-		return nil, errors.New("no domain naming contexts found")
+		return 0, errors.New("no domain naming contexts found")
 	}
 
 	// Prefer GC for cross-domain SearchComputers (one connection covers the forest).
 	// If GC is unreachable we keep ldapConn and chase referrals per-domain below.
 	queryConn := ldapConn
 	if s.Options.Forest {
-		gcConn, gcErr := NewLDAPConnection(
-			s.Options.DomainController,
-			s.Options.Username,
-			s.Options.Password,
-			s.Options.Hash,
-			strings.ToLower(s.Options.Domain),
-			s.Options.Timeout,
-			s.Options.ProxyDialer,
-			s.Options.Kerberos,
-			s.Options.DCHostname,
-			true,
-		)
+		gcConn, gcErr := s.connectGlobalCatalog()
 		if gcErr != nil {
-			logger.Warnf("Global Catalog unavailable on %s, will chase per-domain referrals: %v", s.Options.DomainController, gcErr)
+			logger.Warnf("Global Catalog unavailable, will chase per-domain referrals: %v", gcErr)
 		} else {
 			defer gcConn.Close()
 			queryConn = gcConn
 		}
 	}
 
-	var results []DNHost
-	seenHosts := make(map[string]struct{})
 	var resolver net.IP
 	if s.Options.CustomResolver != nil {
 		resolver = s.Options.CustomResolver
@@ -218,81 +253,656 @@ func (s *Scanner) RunEnumerateDomainComputers() ([]DNHost, error) {
 		r = NewResolver("udp", resolver, s.Options.Timeout, nil)
 	}
 
-	for i, searchBase := range searchBases {
-		logger.Warnf("Enumerating domain %s", searchBase.Name)
-		sr, err := queryConn.SearchComputers(searchBase.BaseDN)
-		if err != nil && isLDAPReferral(err) {
-			// Referral-chase fallback: connect directly to a DC of the referred domain.
-			logger.Warnf("Domain %s referred — chasing via direct DC connection", searchBase.Name)
-			altConn, dialErr := s.dialDCForDomain(searchBase.Name, &r, resolver)
-			if dialErr != nil {
-				logger.Warnf("Skipping domain %s: %v", searchBase.Name, dialErr)
+	resolverThreads := s.Options.ResolverThreads
+	if resolverThreads < 1 {
+		resolverThreads = 1
+	}
+
+	// the resolver pool is started lazily, once the first hostname has been used
+	// to pick a working DNS transport
+	hostnames := make(chan DNHost, resolverThreads*4)
+	resolvedCount := make(chan int, 1)
+	started := false
+	startResolvers := func(r *Resolver) {
+		started = true
+		go func() {
+			resolvedCount <- resolveHostnames(hostnames, r, resolverThreads, s.Options.Target)
+		}()
+	}
+	stopResolvers := func() int {
+		close(hostnames)
+		if !started {
+			return 0
+		}
+		return <-resolvedCount
+	}
+
+	var extraAttributes []string
+	if s.Options.LAPS {
+		extraAttributes = lapsAttributes
+	}
+
+	seenHosts := make(map[string]struct{})
+	queued := 0
+	lapsHosts := 0
+	lapsEncryptedHosts := 0
+	var resolverErr error
+	queueEntries := func(entries []*ldap.Entry) error {
+		if err := s.Options.LockoutGuard.Err(); err != nil {
+			// stop paging, the workers no longer authenticate
+			return err
+		}
+		for _, entry := range entries {
+			hostname := entry.GetAttributeValue("dNSHostName")
+			if hostname == "" {
 				continue
 			}
-			sr, err = altConn.SearchComputers(searchBase.BaseDN)
-			altConn.Close()
+			key := strings.ToLower(hostname)
+			if _, ok := seenHosts[key]; ok {
+				continue
+			}
+			seenHosts[key] = struct{}{}
+
+			if !started {
+				validated, err := s.selectResolver(r, resolver, hostname)
+				if err != nil {
+					resolverErr = err
+					return err
+				}
+				r = validated
+				startResolvers(r)
+			}
+
+			host := DNHost{Hostname: hostname, Source: SourceLDAP}
+			if s.Options.LAPS {
+				host.Credential = lapsCredential(entry, s.Options.LAPSUsername)
+				if host.Credential.IsLAPS() {
+					lapsHosts++
+				} else if host.Credential != nil {
+					lapsEncryptedHosts++
+				}
+			}
+			hostnames <- host
+			queued++
 		}
+		logger.Debugf("Queued %d domain computers for DNS resolution", queued)
+		return nil
+	}
+
+	for _, searchBase := range searchBases {
+		logger.Warnf("Enumerating domain %s", searchBase.Name)
+		queuedBefore := queued
+		err := s.queryDomain(queryConn, searchBase, &r, resolver, func(conn *LDAPConnection) error {
+			return conn.SearchComputersPaged(searchBase.BaseDN, ldapPageSize, queueEntries, extraAttributes...)
+		})
+		if resolverErr != nil {
+			// DNS validation failed, nothing can be resolved in this run
+			stopResolvers()
+			return 0, resolverErr
+		}
+		source := DomainSourcePrimary
+		if s.Options.Forest {
+			source = DomainSourceForest
+		}
+		s.recordDomain(searchBase.Name, source, queued-queuedBefore, err)
 		if err != nil {
 			logger.Warnf("Skipping domain %s: %v", searchBase.Name, err)
 			continue
 		}
-		if len(sr.Entries) == 0 {
-			continue
-		}
+		logger.Warnf("Found %d computers in domain %s", queued-queuedBefore, searchBase.Name)
+	}
 
-		if i == 0 {
-			testEntry := sr.Entries[0].GetAttributeValue("dNSHostName")
-			if s.Options.ProxyDialer != nil {
-				_, err = r.LookupHost(testEntry)
-				if err != nil {
-					return nil, err
+	if s.Options.Trusts {
+		trusts := s.enumerateTrusts(queryConn, searchBases, &r, resolver)
+
+		if s.Options.HuntTrusts {
+			for _, partner := range trustedDomainsToHunt(trusts, searchBases) {
+				logger.Warnf("Enumerating trusted domain %s", partner.Name)
+				queuedBefore := queued
+				// computers of the trusted domain are only reachable with Kerberos through its realm
+				s.addKerberosDomain(partner.Name)
+				conn, err := s.dialDCForDomain(partner.Name, &r, resolver)
+				if err == nil {
+					err = conn.SearchComputersPaged(partner.BaseDN, ldapPageSize, queueEntries, extraAttributes...)
+					conn.Close()
+				}
+				if resolverErr != nil {
+					stopResolvers()
+					return 0, resolverErr
 				}
-			} else {
-				_, err = r.LookupHost(testEntry)
+				s.recordDomain(partner.Name, DomainSourceTrust, queued-queuedBefore, err)
 				if err != nil {
-					r = NewResolver("tcp", resolver, s.Options.Timeout, nil)
-					_, err = r.LookupHost(testEntry)
-					if err != nil {
-						return nil, err
-					}
+					logger.Warnf("Skipping trusted domain %s: %v", partner.Name, err)
+					continue
 				}
+				logger.Warnf("Found %d computers in trusted domain %s", queued-queuedBefore, partner.Name)
 			}
 		}
+	}
 
-		for _, entry := range sr.Entries {
-			hostname := entry.GetAttributeValue("dNSHostName")
-			if hostname == "" {
-				continue
+	// hosts from DNS zones already carry an address and skip the resolver pool
+	zoneHosts := 0
+	if s.Options.DNSZones {
+		queueZoneHosts := func(hosts []DNHost) error {
+			for _, host := range hosts {
+				key := strings.ToLower(host.Hostname)
+				if _, ok := seenHosts[key]; ok {
+					continue
+				}
+				seenHosts[key] = struct{}{}
+				s.Options.Target <- host
+				zoneHosts++
 			}
+			return nil
+		}
+		s.enumerateDNSZones(ldapConn, searchBases, &r, resolver, queueZoneHosts)
+	}
+
+	resolved := stopResolvers()
+	if resolved > 0 {
+		logger.Warnf("Resolved %d of %d domain computers", resolved, queued)
+	}
+	if s.Options.LAPS {
+		logger.Warnf("Found LAPS credentials for %d domain computers", lapsHosts)
+		if lapsEncryptedHosts > 0 {
+			logger.Warnf("Skipped %d encrypted LAPS passwords, these computers are scanned with the global account", lapsEncryptedHosts)
+		}
+	}
+
+	total := resolved + zoneHosts
+	if total == 0 {
+		return 0, errors.New("no domain computers found")
+	}
 
-			possibleTarget, err := r.LookupHost(hostname)
+	return total, nil
+}
+
+// enumerateDNSZones reads host records of AD-integrated DNS zones and passes them
+// to handler. The DNS partitions hosted by the connected DC are searched first; in
+// forest mode, DomainDnsZones of the other domains are read from their own DCs.
+func (s *Scanner) enumerateDNSZones(ldapConn *LDAPConnection, searchBases []DomainPartition, r **Resolver, resolverIP net.IP, handler func([]DNHost) error) {
+	searchPartition := func(conn *LDAPConnection, partition string) {
+		logger.Warnf("Enumerating DNS records in %s", partition)
+		var found int
+		err := conn.SearchDNSZoneHosts(partition, ldapPageSize, func(hosts []DNHost) error {
+			found += len(hosts)
+			return handler(hosts)
+		})
+		if err != nil {
+			logger.Warnf("Skipping DNS partition %s: %v", partition, err)
+			return
+		}
+		logger.Warnf("Found %d host records in %s", found, partition)
+	}
+
+	partitions, err := ldapConn.SearchDNSPartitions()
+	if err != nil {
+		logger.Warnf("Failed to find DNS partitions: %v", err)
+	}
+	searched := make(map[string]struct{})
+	for _, partition := range partitions {
+		searched[strings.ToLower(partition)] = struct{}{}
+		searchPartition(ldapConn, partition)
+	}
+
+	if !s.Options.Forest {
+		return
+	}
+	for _, searchBase := range searchBases {
+		partition := "DC=DomainDnsZones," + searchBase.BaseDN
+		if _, ok := searched[strings.ToLower(partition)]; ok {
+			continue
+		}
+		altConn, err := s.dialDCForDomain(searchBase.Name, r, resolverIP)
+		if err != nil {
+			logger.Warnf("Skipping DNS zones of domain %s: %v", searchBase.Name, err)
+			continue
+		}
+		searchPartition(altConn, partition)
+		altConn.Close()
+	}
+}
+
+// queryDomain runs query against conn. If the domain partition is not held by
+// the connected server and an LDAP referral is returned, query is retried
+// against a DC of the referred domain.
+func (s *Scanner) queryDomain(conn *LDAPConnection, domain DomainPartition, r **Resolver, resolverIP net.IP, query func(*LDAPConnection) error) error {
+	err := query(conn)
+	if err == nil || !isLDAPReferral(err) {
+		return err
+	}
+
+	// Referral-chase fallback: connect directly to a DC of the referred domain.
+	logger.Warnf("Domain %s referred — chasing via direct DC connection", domain.Name)
+	altConn, err := s.dialDCForDomain(domain.Name, r, resolverIP)
+	if err != nil {
+		return err
+	}
+	defer altConn.Close()
+	return query(altConn)
+}
+
+// enumerateTrusts reads trusts of every searched domain, reports them and stores
+// them in the run summary.
+func (s *Scanner) enumerateTrusts(conn *LDAPConnection, searchBases []DomainPartition, r **Resolver, resolverIP net.IP) []Trust {
+	var trusts []Trust
+	for _, searchBase := range searchBases {
+		var domainTrusts []Trust
+		err := s.queryDomain(conn, searchBase, r, resolverIP, func(conn *LDAPConnection) error {
+			var err error
+			domainTrusts, err = conn.SearchTrusts(searchBase)
+			return err
+		})
+		if err != nil {
+			logger.Warnf("Failed to enumerate trusts of domain %s: %v", searchBase.Name, err)
+			continue
+		}
+		trusts = append(trusts, domainTrusts...)
+	}
+
+	logger.Warnf("Found %d domain trusts", len(trusts))
+	if len(trusts) > 0 {
+		printResult := SprintTrusts(trusts)
+		logger.Info(printResult)
+		if s.Options.FileTXT != nil {
+			err := s.Options.Writer.Write(printResult, s.Options.FileTXT)
 			if err != nil {
-				logger.Debug(err.Error())
-				continue
+				logger.Error(err)
 			}
-			key := strings.ToLower(hostname) + "|" + possibleTarget.String()
-			if _, ok := seenHosts[key]; ok {
-				continue
+		}
+	}
+
+	s.summaryMutex.Lock()
+	s.Summary.Trusts = append(s.Summary.Trusts, trusts...)
+	s.summaryMutex.Unlock()
+	return trusts
+}
+
+// trustedDomainsToHunt returns partner domains that accept authentication from
+// the trusting domain and are not searched already, each domain once.
+func trustedDomainsToHunt(trusts []Trust, searchBases []DomainPartition) []DomainPartition {
+	seen := make(map[string]struct{})
+	for _, searchBase := range searchBases {
+		seen[strings.ToLower(searchBase.Name)] = struct{}{}
+	}
+
+	var results []DomainPartition
+	for _, trust := range trusts {
+		if !trust.AllowsAccess() {
+			continue
+		}
+		name := strings.ToLower(trust.Partner)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		results = append(results, DomainPartition{Name: name, BaseDN: GetBaseDN(name)})
+	}
+	return results
+}
+
+// addKerberosDomain adds a realm besides the domain of the account to the
+// Kerberos configuration. SMB workers may already share a Kerberos client, it
+// is dropped and logged in again with the new realm on next use.
+func (s *Scanner) addKerberosDomain(domain string) {
+	if strings.EqualFold(domain, s.Options.Domain) {
+		return
+	}
+	for _, known := range s.Options.KerberosDomains {
+		if strings.EqualFold(known, domain) {
+			return
+		}
+	}
+
+	tickets := s.Options.KerberosTickets
+	if tickets != nil {
+		tickets.mutex.Lock()
+		defer tickets.mutex.Unlock()
+		tickets.client = nil
+	}
+	s.Options.KerberosDomains = append(s.Options.KerberosDomains, domain)
+}
+
+// recordDomain stores the outcome of computer discovery in a domain in the run summary
+func (s *Scanner) recordDomain(name, source string, computers int, err error) {
+	result := DomainResult{
+		Name:      name,
+		Source:    source,
+		Success:   err == nil,
+		Computers: computers,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	s.summaryMutex.Lock()
+	defer s.summaryMutex.Unlock()
+	s.Summary.Domains = append(s.Summary.Domains, result)
+}
+
+// selectResolver checks r against a known hostname. Without a proxy, a failed
+// UDP lookup is retried over TCP and the TCP resolver is returned on success.
+func (s *Scanner) selectResolver(r *Resolver, resolverIP net.IP, testHost string) (*Resolver, error) {
+	_, err := r.LookupHost(testHost)
+	if err == nil {
+		return r, nil
+	}
+	if s.Options.ProxyDialer != nil {
+		return nil, err
+	}
+
+	tcpResolver := NewResolver("tcp", resolverIP, s.Options.Timeout, nil)
+	if _, err = tcpResolver.LookupHost(testHost); err != nil {
+		return nil, err
+	}
+	return tcpResolver, nil
+}
+
+// hostLookup is the part of Resolver used by the resolution pool
+type hostLookup interface {
+	LookupHost(host string) (net.IP, error)
+}
+
+// resolveHostnames resolves hostnames with the specified number of concurrent
+// workers and sends every resolved host to targets. It returns after the
+// hostnames channel is closed and drained, reporting how many hosts were sent.
+func resolveHostnames(hostnames <-chan DNHost, r hostLookup, workers int, targets chan<- DNHost) int {
+	var wg sync.WaitGroup
+	var sent atomic.Int64
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hostnames {
+				ip, err := r.LookupHost(host.Hostname)
+				if err != nil {
+					logger.Debug(err.Error())
+					continue
+				}
+				host.IP = ip
+				targets <- host
+				if n := sent.Add(1); n%500 == 0 {
+					logger.Warnf("Resolved %d domain computers so far", n)
+				}
 			}
-			seenHosts[key] = struct{}{}
-			results = append(results, DNHost{Hostname: hostname, IP: possibleTarget})
+		}()
+	}
+	wg.Wait()
+
+	return int(sent.Load())
+}
+
+// DiscoverDomainControllers locates domain controllers and Global Catalog servers
+// of domain via DNS SRV records and stores them in Options, so that LDAP
+// connections fail over between them in priority order. The --resolver server is
+// queried if specified, the system resolver otherwise.
+func (s *Scanner) DiscoverDomainControllers(domain string) error {
+	if s.Options.ProxyDialer != nil && s.Options.CustomResolver == nil {
+		return errors.New("locating domain controllers through --proxy requires --resolver")
+	}
+
+	var r *Resolver
+	if s.Options.ProxyDialer != nil {
+		r = s.newResolver("tcp", s.Options.CustomResolver, s.Options.Timeout, s.Options.ProxyDialer)
+	} else {
+		r = s.newResolver("udp", s.Options.CustomResolver, s.Options.Timeout, nil)
+	}
+
+	dcs, err := r.LookupDomainControllers(domain, false)
+	if err != nil && s.Options.ProxyDialer == nil && s.Options.CustomResolver != nil {
+		r = s.newResolver("tcp", s.Options.CustomResolver, s.Options.Timeout, nil)
+		dcs, err = r.LookupDomainControllers(domain, false)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to locate domain controllers of %s: %w", domain, err)
+	}
+
+	gcs, err := r.LookupDomainControllers(domain, true)
+	if err != nil {
+		logger.Debugf("Failed to locate Global Catalog servers of %s: %v", domain, err)
+	}
+
+	for _, dc := range dcs {
+		logger.Debugf("Located domain controller %s (%s)", dc.Hostname, dc.IP)
+	}
+
+	s.Options.DomainControllers = dcs
+	s.Options.GlobalCatalogs = gcs
+	return nil
+}
+
+// CheckLockoutPolicy reads the domain lockout policy and the failed logon
+// counts of the scan account and the tracked accounts before the scan starts,
+// so the lockout guard and the credential tracker stop short of the lockout
+// threshold. An error is returned if the domain controller rejects the scan
+// account or it is already close to lockout. Other failures keep the default
+// allowance of the guard and --max-attempts of the tracked accounts.
+func (s *Scanner) CheckLockoutPolicy() error {
+	if s.Options.LockoutGuard == nil && len(s.trackedCredentials()) == 0 {
+		return nil
+	}
+	conn, err := s.connectPolicyReader()
+	if err != nil {
+		if s.Options.Username != "" && ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return fmt.Errorf("the domain controller rejected the credentials: %w", err)
 		}
+		s.warnLockoutPolicyNotRead(err)
+		return nil
 	}
+	defer conn.Close()
+	return s.applyLockoutPolicy(conn)
+}
 
-	if len(results) == 0 {
-		return nil, errors.New("no domain computers found")
+// connectPolicyReader connects to the domain controller to read the lockout
+// policy. Without a scan account, the first domain account of --credentials
+// binds instead and the bind counts towards its failed logons.
+func (s *Scanner) connectPolicyReader() (*LDAPConnection, error) {
+	if s.Options.Username != "" || s.Options.CredentialTracker == nil {
+		return s.connectDomainController()
 	}
+	for _, credential := range s.Options.Credentials {
+		if credential.LocalAuth || !s.Options.CredentialTracker.Acquire(credential) {
+			continue
+		}
+		conn, err := NewLDAPConnection(
+			s.Options.DomainController,
+			credential.Username,
+			credential.Password,
+			hex.EncodeToString(credential.Hash),
+			strings.ToLower(credential.Domain),
+			s.Options.Timeout,
+			s.Options.ProxyDialer,
+			false,
+			KerberosCredentials{},
+			s.Options.DCHostname,
+			false,
+		)
+		authErr := err
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			authErr = fmt.Errorf("%w: %w", smb.StatusMap[smb.StatusLogonFailure], err)
+		}
+		if reason, stopped := s.Options.CredentialTracker.Record(credential, authErr); stopped {
+			logger.Warnf("Stopped using %s: %s", credential, reason)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bind as %s: %w", credential, err)
+		}
+		return conn, nil
+	}
+	return nil, errors.New("no domain account to bind with")
+}
 
-	return results, nil
+// trackedCredentials returns the domain accounts whose failed logons are
+// counted by the credential tracker, if the lockout policy is to be read.
+func (s *Scanner) trackedCredentials() []*Credential {
+	if !s.Options.LockoutCheck || s.Options.CredentialTracker == nil {
+		return nil
+	}
+	var credentials []*Credential
+	for _, credential := range append(slices.Clone(s.Options.Credentials), s.Options.CompareCredential) {
+		if credential != nil && !credential.LocalAuth {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials
 }
 
-// dialDCForDomain resolves domainName via DNS and opens a regular LDAP connection
-// to a DC of that domain. Used when the Global Catalog is unavailable and a
-// SearchComputers query against the user-specified DC returned an LDAP referral.
-// AD-integrated DNS registers the domain name itself as A records for every DC,
-// so a plain host lookup yields a usable target. The resolver pointer is updated
-// in-place if a UDP→TCP retry succeeds, mirroring the test-entry validation below.
+// warnLockoutPolicyNotRead warns that the failed logons are only capped by
+// the default allowance and --max-attempts.
+func (s *Scanner) warnLockoutPolicyNotRead(err error) {
+	if s.Options.LockoutGuard != nil {
+		logger.Warnf("Failed to read the lockout policy, assuming %d failed logons are allowed: %v", DefaultLockoutAllowance, err)
+		return
+	}
+	logger.Warnf("Failed to read the lockout policy, accounts are only capped by --max-attempts: %v", err)
+}
+
+// applyLockoutPolicy sets the lockout guard allowance and the caps of the
+// tracked accounts from the policy read over conn. Tracked accounts that are
+// not found in the domain of the domain controller keep --max-attempts.
+func (s *Scanner) applyLockoutPolicy(conn *LDAPConnection) error {
+	tracked := s.trackedCredentials()
+	if s.Options.LockoutGuard == nil && len(tracked) == 0 {
+		return nil
+	}
+	baseDN := GetBaseDN(s.Options.Domain)
+	if s.Options.Domain == "" {
+		var err error
+		baseDN, err = conn.SearchDefaultNamingContext()
+		if err != nil {
+			s.warnLockoutPolicyNotRead(err)
+			return nil
+		}
+	}
+	policy, err := conn.SearchLockoutPolicy(baseDN)
+	if err != nil {
+		s.warnLockoutPolicyNotRead(err)
+		return nil
+	}
+	if policy.Threshold == 0 {
+		logger.Warnf("Lockout policy: accounts are never locked out")
+	}
+
+	for _, credential := range tracked {
+		badPwdCount, err := conn.SearchBadPwdCount(baseDN, credential.Username)
+		if err != nil {
+			logger.Debugf("Failed to read badPwdCount of %s, only --max-attempts applies: %v", credential, err)
+			continue
+		}
+		if reason, stopped := s.Options.CredentialTracker.SetPolicy(credential, policy, badPwdCount); stopped {
+			logger.Warnf("Stopped using %s: %s", credential, reason)
+		}
+	}
+	if policy.Threshold > 0 && len(tracked) > 0 {
+		logger.Warnf("Lockout policy: %d failed logons within %s, applied to %d tracked accounts", policy.Threshold, policy.ObservationWindow, len(tracked))
+	}
+
+	if s.Options.LockoutGuard == nil {
+		return nil
+	}
+	badPwdCount, err := conn.SearchBadPwdCount(baseDN, s.Options.Username)
+	if err != nil {
+		logger.Debugf("Failed to read badPwdCount of %s: %v", s.Options.Username, err)
+	}
+	if policy.Threshold > 0 {
+		logger.Warnf("Lockout policy: %d failed logons within %s, %s has %d", policy.Threshold, policy.ObservationWindow, s.Options.Username, badPwdCount)
+	}
+	return s.Options.LockoutGuard.SetPolicy(policy, badPwdCount)
+}
+
+// connectDomainController opens a regular LDAP connection to the domain controller.
+// If domain controllers were located via DNS, they are tried in priority order and
+// the one that accepted the connection becomes Options.DomainController.
+func (s *Scanner) connectDomainController() (*LDAPConnection, error) {
+	if len(s.Options.DomainControllers) == 0 {
+		return s.newLDAPConnection(s.Options.DomainController, s.Options.DCHostname, false)
+	}
+
+	conn, dc, err := s.dialDomainControllers(s.Options.DomainControllers, false)
+	if err != nil {
+		return nil, err
+	}
+	s.Options.DomainController = dc.IP
+	if s.Options.DCHostname == "" {
+		s.Options.DCHostname = dc.Hostname
+	}
+	logger.Warnf("Using domain controller %s (%s)", dc.Hostname, dc.IP)
+	return conn, nil
+}
+
+// connectGlobalCatalog opens a Global Catalog connection, preferring GC servers
+// located via DNS and falling back to the connected domain controller.
+func (s *Scanner) connectGlobalCatalog() (*LDAPConnection, error) {
+	if len(s.Options.GlobalCatalogs) > 0 {
+		conn, _, err := s.dialDomainControllers(s.Options.GlobalCatalogs, true)
+		if err == nil {
+			return conn, nil
+		}
+		logger.Debugf("No located Global Catalog accepted the connection: %v", err)
+	}
+	return s.newLDAPConnection(s.Options.DomainController, s.Options.DCHostname, true)
+}
+
+// dialDomainControllers tries candidates in order until an LDAP connection succeeds.
+func (s *Scanner) dialDomainControllers(candidates []DNHost, useGC bool) (*LDAPConnection, DNHost, error) {
+	var errs []error
+	for _, dc := range candidates {
+		dcHostname := dc.Hostname
+		if dcHostname == "" {
+			dcHostname = s.Options.DCHostname
+		}
+		conn, err := s.newLDAPConnection(dc.IP, dcHostname, useGC)
+		if err != nil {
+			logger.Warnf("Failed to connect to domain controller %s (%s): %v", dc.Hostname, dc.IP, err)
+			errs = append(errs, err)
+			continue
+		}
+		return conn, dc, nil
+	}
+	return nil, DNHost{}, fmt.Errorf("no domain controller accepted the connection: %w", errors.Join(errs...))
+}
+
+// newLDAPConnection opens an LDAP connection to host with the scanner's credentials.
+func (s *Scanner) newLDAPConnection(host net.IP, dcHostname string, useGC bool) (*LDAPConnection, error) {
+	if s.dialLDAP != nil {
+		return s.dialLDAP(host, dcHostname, useGC)
+	}
+	return NewLDAPConnection(
+		host,
+		s.Options.Username,
+		s.Options.Password,
+		s.Options.Hash,
+		strings.ToLower(s.Options.Domain),
+		s.Options.Timeout,
+		s.Options.ProxyDialer,
+		s.Options.Kerberos,
+		s.Options.KerberosCredentials(),
+		dcHostname,
+		useGC,
+	)
+}
+
+// dialDCForDomain opens a regular LDAP connection to a DC of domainName. Used when
+// the Global Catalog is unavailable and a SearchComputers query against the
+// user-specified DC returned an LDAP referral. DCs registered in the domain's
+// _ldap._tcp.dc._msdcs SRV records are tried first, in priority order.
+// Otherwise, AD-integrated DNS registers the domain name itself as A records for
+// every DC, so a plain host lookup yields a usable target. The resolver pointer
+// is updated in-place if a UDP→TCP retry succeeds, mirroring selectResolver.
 func (s *Scanner) dialDCForDomain(domainName string, r **Resolver, resolverIP net.IP) (*LDAPConnection, error) {
+	dcs, err := (*r).LookupDomainControllers(domainName, false)
+	if err == nil {
+		conn, dc, dialErr := s.dialDomainControllers(dcs, false)
+		if dialErr == nil {
+			logger.Debugf("Connected to domain controller %s (%s) of %s", dc.Hostname, dc.IP, domainName)
+			return conn, nil
+		}
+		logger.Debugf("Located domain controllers of %s are not reachable: %v", domainName, dialErr)
+	} else {
+		logger.Debugf("SRV lookup for domain controllers of %s failed: %v", domainName, err)
+	}
+
 	ip, err := (*r).LookupHost(domainName)
 	if err != nil && s.Options.ProxyDialer == nil {
 		tcpResolver := NewResolver("tcp", resolverIP, s.Options.Timeout, nil)
@@ -308,8 +918,8 @@ func (s *Scanner) dialDCForDomain(domainName string, r **Resolver, resolverIP ne
 	dcHostname := s.Options.DCHostname
 	if s.Options.Kerberos {
 		// Kerberos SPN must match the alt DC's hostname; reverse-resolve the IP.
-		// Cross-realm Kerberos may still fail downstream — the in-memory krb5 config
-		// has only the user's realm — but failing fast here gives a clearer error.
+		// The service ticket is obtained through a cross-realm referral, which
+		// needs the domain's realm in the krb5 config (forest domains are added).
 		names, rerr := net.LookupAddr(ip.String())
 		if rerr != nil || len(names) == 0 {
 			return nil, fmt.Errorf("kerberos referral fallback to %s requires reverse-DNS for SPN: %v", ip, rerr)
@@ -317,18 +927,7 @@ func (s *Scanner) dialDCForDomain(domainName string, r **Resolver, resolverIP ne
 		dcHostname = strings.TrimSuffix(names[0], ".")
 	}
 
-	return NewLDAPConnection(
-		ip,
-		s.Options.Username,
-		s.Options.Password,
-		s.Options.Hash,
-		strings.ToLower(s.Options.Domain),
-		s.Options.Timeout,
-		s.Options.ProxyDialer,
-		s.Options.Kerberos,
-		dcHostname,
-		false,
-	)
+	return s.newLDAPConnection(ip, dcHostname, false)
 }
 
 // OutputHTML is used to generate HTML output from XML output
@@ -2,6 +2,8 @@ package scanner
 
 import (
 	"encoding/xml"
+	"fmt"
+	smbclient "github.com/jfjallid/gokrb5/v8/client"
 	"net"
 	"os"
 	"path/filepath"
@@ -338,6 +340,24 @@ func TestSprintHost(t *testing.T) {
 	})
 }
 
+func TestSprintHost_Credentials(t *testing.T) {
+	h := Host{
+		IP: "10.0.0.1",
+		Shares: []Share{
+			{ShareName: "Data", ReadPermission: true, Credential: "NORTH\\helpdesk"},
+			{ShareName: "Data", ReadPermission: true, WritePermission: true, Credential: "NORTH\\svc_backup"},
+		},
+	}
+
+	result := SprintHost(h, nil)
+	if !strings.Contains(result, "Credential") {
+		t.Error("expected a Credential column")
+	}
+	if !strings.Contains(result, "NORTH\\svc_backup") || !strings.Contains(result, "NORTH\\helpdesk") {
+		t.Errorf("expected both accounts in output:\n%s", result)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // SprintFiles
 // ---------------------------------------------------------------------------
@@ -537,3 +557,136 @@ func TestParseTargets_CIDRFromFile(t *testing.T) {
 		t.Fatalf("expected 2 hosts from /30 CIDR, got %d", len(hosts))
 	}
 }
+
+// ---------------------------------------------------------------------------
+// resolveHostnames
+// ---------------------------------------------------------------------------
+
+type fakeLookup map[string]string
+
+func (f fakeLookup) LookupHost(host string) (net.IP, error) {
+	ip, ok := f[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	return net.ParseIP(ip), nil
+}
+
+func TestResolveHostnames(t *testing.T) {
+	lookup := fakeLookup{
+		"dc01.corp.local":  "10.0.0.1",
+		"srv01.corp.local": "10.0.0.2",
+		"srv02.corp.local": "10.0.0.3",
+	}
+
+	hostnames := make(chan DNHost)
+	targets := make(chan DNHost, 10)
+	go func() {
+		for _, h := range []string{"dc01.corp.local", "missing.corp.local", "srv01.corp.local", "srv02.corp.local"} {
+			hostnames <- DNHost{Hostname: h, Source: SourceLDAP}
+		}
+		close(hostnames)
+	}()
+
+	sent := resolveHostnames(hostnames, lookup, 3, targets)
+	close(targets)
+
+	if sent != 3 {
+		t.Fatalf("expected 3 resolved hosts, got %d", sent)
+	}
+	got := make(map[string]string)
+	for h := range targets {
+		if h.Source != SourceLDAP {
+			t.Errorf("%s: source not preserved, got %q", h.Hostname, h.Source)
+		}
+		got[h.Hostname] = h.IP.String()
+	}
+	for hostname, ip := range lookup {
+		if got[hostname] != ip {
+			t.Errorf("%s: expected %s, got %q", hostname, ip, got[hostname])
+		}
+	}
+	if _, ok := got["missing.corp.local"]; ok {
+		t.Error("unresolvable host must not be sent to targets")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// trustedDomainsToHunt
+// ---------------------------------------------------------------------------
+
+func TestTrustedDomainsToHunt(t *testing.T) {
+	trusts := []Trust{
+		{Domain: "corp.local", Partner: "partner.local", Direction: "bidirectional"},
+		{Domain: "corp.local", Partner: "outbound.local", Direction: "outbound"},
+		{Domain: "corp.local", Partner: "child.corp.local", Direction: "bidirectional"},
+		{Domain: "child.corp.local", Partner: "PARTNER.local", Direction: "inbound"},
+		{Domain: "corp.local", Partner: "inbound.local", Direction: "inbound"},
+	}
+	searchBases := []DomainPartition{
+		{Name: "corp.local", BaseDN: "dc=corp,dc=local"},
+		{Name: "child.corp.local", BaseDN: "dc=child,dc=corp,dc=local"},
+	}
+
+	got := trustedDomainsToHunt(trusts, searchBases)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 domains to hunt, got %d: %#v", len(got), got)
+	}
+	if got[0].Name != "partner.local" || got[0].BaseDN != "dc=partner,dc=local" {
+		t.Errorf("unexpected first domain: %#v", got[0])
+	}
+	if got[1].Name != "inbound.local" {
+		t.Errorf("unexpected second domain: %#v", got[1])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// addKerberosDomain
+// ---------------------------------------------------------------------------
+
+func TestAddKerberosDomain(t *testing.T) {
+	options := &Options{Domain: "corp.local", Kerberos: true}
+	options.KerberosTickets = NewKerberosTicketCache(options)
+	options.KerberosTickets.client = &smbclient.Client{}
+	s := &Scanner{Options: options}
+
+	s.addKerberosDomain("CORP.local")
+	if len(options.KerberosDomains) != 0 || options.KerberosTickets.client == nil {
+		t.Fatalf("the domain of the account must not be added: %v", options.KerberosDomains)
+	}
+
+	s.addKerberosDomain("partner.local")
+	s.addKerberosDomain("PARTNER.LOCAL")
+	if len(options.KerberosDomains) != 1 || options.KerberosDomains[0] != "partner.local" {
+		t.Errorf("expected [partner.local], got %v", options.KerberosDomains)
+	}
+	if options.KerberosTickets.client != nil {
+		t.Error("expected the shared Kerberos client to be dropped for the new realm")
+	}
+}
+
+func TestParseSharefinderRun_Summary(t *testing.T) {
+	var buf strings.Builder
+	writer := NewOutputWriter()
+	summary := RunSummary{
+		Trusts:  []Trust{{Domain: "corp.local", Partner: "partner.local", Direction: "bidirectional", Type: "uplevel"}},
+		Domains: []DomainResult{{Name: "partner.local", Source: DomainSourceTrust, Success: false, Error: "bind failed"}},
+	}
+	if err := writer.WriteXMLHeader("1.0", []string{"hunt"}, time.Now(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteXMLFooter(time.Now(), summary, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ParseSharefinderRun([]byte(buf.String()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Summary.Trusts) != 1 || result.Summary.Trusts[0].Partner != "partner.local" {
+		t.Fatalf("unexpected trusts: %#v", result.Summary.Trusts)
+	}
+	if len(result.Summary.Domains) != 1 || result.Summary.Domains[0].Success || result.Summary.Domains[0].Error != "bind failed" {
+		t.Fatalf("unexpected domains: %#v", result.Summary.Domains)
+	}
+}
@@ -0,0 +1,49 @@
+package scanner
+
+// Session types of a host enumeration
+const (
+	SessionNull          = "null"
+	SessionGuest         = "guest"
+	SessionAuthenticated = "auth"
+)
+
+// scanSessionType returns the session type a host is enumerated with.
+func scanSessionType(options *Options, nullSession bool) string {
+	switch {
+	case nullSession:
+		return SessionNull
+	case options.Guest:
+		return SessionGuest
+	default:
+		return SessionAuthenticated
+	}
+}
+
+// effectiveSessionType returns the session type of the SMB2 session flags,
+// servers mapping unknown users to Guest set IS_GUEST even if the logon was
+// requested for a named account.
+func effectiveSessionType(isNull, isGuest bool) string {
+	switch {
+	case isNull:
+		return SessionNull
+	case isGuest:
+		return SessionGuest
+	default:
+		return SessionAuthenticated
+	}
+}
+
+// SessionType returns the session type the server granted the connection.
+func (conn *Connection) SessionType() string {
+	return effectiveSessionType(conn.session.IsNullSession(), conn.session.IsGuestSession())
+}
+
+// setSession records the effective session type of the host and the requested
+// one if the server mapped the logon to another session type.
+func (h *Host) setSession(requested, effective string) {
+	h.Session = effective
+	h.RequestedSession = ""
+	if requested != effective {
+		h.RequestedSession = requested
+	}
+}
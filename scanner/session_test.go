@@ -0,0 +1,54 @@
+package scanner
+
+import "testing"
+
+func TestScanSessionType(t *testing.T) {
+	if got := scanSessionType(&Options{}, true); got != SessionNull {
+		t.Errorf("expected a null session, got %s", got)
+	}
+	if got := scanSessionType(&Options{Guest: true}, false); got != SessionGuest {
+		t.Errorf("expected a guest session, got %s", got)
+	}
+	if got := scanSessionType(&Options{}, false); got != SessionAuthenticated {
+		t.Errorf("expected an authenticated session, got %s", got)
+	}
+}
+
+func TestEffectiveSessionType(t *testing.T) {
+	if got := effectiveSessionType(true, false); got != SessionNull {
+		t.Errorf("expected a null session, got %s", got)
+	}
+	if got := effectiveSessionType(false, true); got != SessionGuest {
+		t.Errorf("expected a guest session, got %s", got)
+	}
+	if got := effectiveSessionType(false, false); got != SessionAuthenticated {
+		t.Errorf("expected an authenticated session, got %s", got)
+	}
+}
+
+func TestHostSetSession(t *testing.T) {
+	// the random guest user was mapped to Guest as expected
+	var guest Host
+	guest.setSession(SessionGuest, SessionGuest)
+	if guest.SessionMapped() || SprintSession(guest) != " (session:guest)" {
+		t.Errorf("unexpected session %+v", guest)
+	}
+
+	// a bad password was mapped to Guest
+	var mapped Host
+	mapped.setSession(SessionAuthenticated, SessionGuest)
+	if !mapped.SessionMapped() || SprintSession(mapped) != " (session:guest) (requested:auth)" {
+		t.Errorf("unexpected session %+v", mapped)
+	}
+
+	var authenticated Host
+	authenticated.setSession(SessionAuthenticated, SessionAuthenticated)
+	if authenticated.SessionMapped() || SprintSession(authenticated) != "" {
+		t.Errorf("unexpected session %+v", authenticated)
+	}
+
+	run := SharefinderRun{Hosts: []Host{guest, mapped, authenticated}}
+	if run.GuestSessionHostCount() != 2 {
+		t.Errorf("expected 2 guest sessions, got %d", run.GuestSessionHostCount())
+	}
+}
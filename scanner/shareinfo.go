@@ -0,0 +1,382 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/jfjallid/go-smb/dcerpc"
+	"github.com/jfjallid/go-smb/dcerpc/mssrvs"
+	"github.com/jfjallid/go-smb/dcerpc/smbtransport"
+	"github.com/jfjallid/go-smb/msdtyp"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Exposures of a share path flagged in reports
+const (
+	ShareExposureVolumeRoot      = "volume_root"      // the share is the root of a volume, e.g. C:\
+	ShareExposureSystemDirectory = "system_directory" // the share is in a Windows or program directory
+)
+
+const (
+	// shareMaxUsesUnlimited is the max uses of shares without a connection limit
+	shareMaxUsesUnlimited = 0xffffffff
+
+	// share access masks of the Windows share permission dialog
+	shareAccessFull   = 0x001f01ff
+	shareAccessChange = 0x001301bf
+	shareAccessRead   = 0x001200a9
+)
+
+// ShareDetails are the share properties only returned to privileged sessions
+// by NetShareEnum level 2 and 502
+type ShareDetails struct {
+	Level       uint32 `xml:"level,attr"`
+	Path        string `xml:"path,attr"`
+	MaxUses     int64  `xml:"max_uses,attr"` // -1 if unlimited
+	CurrentUses uint32 `xml:"current_uses,attr"`
+	ACL         string `xml:"acl,attr,omitempty"`
+	Exposure    string `xml:"exposure,attr,omitempty"`
+}
+
+// Uses returns the current and max uses of the share.
+func (d *ShareDetails) Uses() string {
+	if d.MaxUses < 0 {
+		return fmt.Sprintf("%d/unlimited", d.CurrentUses)
+	}
+	return fmt.Sprintf("%d/%d", d.CurrentUses, d.MaxUses)
+}
+
+// shareInfo is an entry of a NetShareEnum level 2 or 502 response
+type shareInfo struct {
+	Name               string
+	Type               uint32
+	Remark             string
+	Permissions        uint32
+	MaxUses            uint32
+	CurrentUses        uint32
+	Path               string
+	SecurityDescriptor []byte
+}
+
+// wellKnownSIDs are the names of SIDs commonly found in share ACLs
+var wellKnownSIDs = map[string]string{
+	"S-1-1-0":      "Everyone",
+	"S-1-3-0":      "CREATOR OWNER",
+	"S-1-5-2":      "NETWORK",
+	"S-1-5-4":      "INTERACTIVE",
+	"S-1-5-7":      "ANONYMOUS LOGON",
+	"S-1-5-11":     "Authenticated Users",
+	"S-1-5-18":     "SYSTEM",
+	"S-1-5-32-544": `BUILTIN\Administrators`,
+	"S-1-5-32-545": `BUILTIN\Users`,
+	"S-1-5-32-546": `BUILTIN\Guests`,
+	"S-1-5-32-547": `BUILTIN\Power Users`,
+	"S-1-5-32-549": `BUILTIN\Server Operators`,
+	"S-1-5-32-551": `BUILTIN\Backup Operators`,
+}
+
+var (
+	volumeRootPattern      = regexp.MustCompile(`^[A-Za-z]:\\?$`)
+	systemDirectoryPattern = regexp.MustCompile(`(?i)^[a-z]:\\(windows|program files|program files \(x86\)|programdata)(\\|$)`)
+)
+
+// ShareType returns the type of a share from its STYPE value, e.g. "disk" or
+// "ipc,special".
+func ShareType(stype uint32) string {
+	var shareType string
+	switch stype & 0xff {
+	case mssrvs.StypeDisktree:
+		shareType = "disk"
+	case mssrvs.StypePrintq:
+		shareType = "printer"
+	case mssrvs.StypeDevice:
+		shareType = "device"
+	case mssrvs.StypeIPC:
+		shareType = "ipc"
+	default:
+		shareType = fmt.Sprintf("0x%x", stype&0xff)
+	}
+	if stype&mssrvs.StypeSpecial != 0 {
+		shareType += ",special"
+	}
+	if stype&mssrvs.StypeTemporary != 0 {
+		shareType += ",temporary"
+	}
+	return shareType
+}
+
+// netShareType returns the STYPE value of a share from NetShareEnumAll, which
+// only keeps the base type and the special and temporary flags.
+func netShareType(share mssrvs.NetShare) uint32 {
+	stype := share.TypeId
+	if stype > mssrvs.StypeIPC {
+		// cluster shares are disk shares
+		stype = mssrvs.StypeDisktree
+	}
+	if share.Hidden {
+		stype |= mssrvs.StypeSpecial
+	}
+	if strings.HasSuffix(share.Type, "_"+mssrvs.ShareTypeMap[mssrvs.StypeTemporary]) {
+		stype |= mssrvs.StypeTemporary
+	}
+	return stype
+}
+
+// shareExposure flags paths that expose a volume root or a system directory.
+// Hidden administrative shares always do, so only regular shares are flagged.
+func shareExposure(path string, stype uint32) string {
+	if stype&mssrvs.StypeSpecial != 0 || stype&0xff != mssrvs.StypeDisktree {
+		return ""
+	}
+	switch {
+	case volumeRootPattern.MatchString(path):
+		return ShareExposureVolumeRoot
+	case systemDirectoryPattern.MatchString(path) && !strings.Contains(strings.ToUpper(path), `\SYSVOL`):
+		// SYSVOL and NETLOGON of domain controllers live in C:\Windows
+		return ShareExposureSystemDirectory
+	default:
+		return ""
+	}
+}
+
+// shareACL returns the DACL of a self-relative security descriptor as
+// comma-separated "principal:rights" entries.
+func shareACL(raw []byte) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var sd msdtyp.SecurityDescriptor
+	if err := sd.UnmarshalBinary(raw); err != nil {
+		return "", err
+	}
+	if sd.Dacl == nil {
+		// a NULL DACL grants everyone full access
+		return "Everyone:FULL", nil
+	}
+	var entries []string
+	for _, ace := range sd.Dacl.ACLS {
+		principal := ace.Sid.ToString()
+		if name, ok := wellKnownSIDs[principal]; ok {
+			principal = name
+		}
+		var rights string
+		switch ace.Mask {
+		case shareAccessFull:
+			rights = "FULL"
+		case shareAccessChange:
+			rights = "CHANGE"
+		case shareAccessRead:
+			rights = "READ"
+		default:
+			rights = fmt.Sprintf("0x%08x", ace.Mask)
+		}
+		switch ace.Header.Type {
+		case msdtyp.AccessAllowedAceType:
+			entries = append(entries, principal+":"+rights)
+		case msdtyp.AccessDeniedAceType:
+			entries = append(entries, "DENY "+principal+":"+rights)
+		}
+	}
+	return strings.Join(entries, ", "), nil
+}
+
+// shareDetails returns the details of a NetShareEnum level 2 or 502 entry.
+func shareDetails(info shareInfo, level uint32) *ShareDetails {
+	details := &ShareDetails{
+		Level:       level,
+		Path:        info.Path,
+		MaxUses:     int64(info.MaxUses),
+		CurrentUses: info.CurrentUses,
+		Exposure:    shareExposure(info.Path, info.Type),
+	}
+	if info.MaxUses == shareMaxUsesUnlimited {
+		details.MaxUses = -1
+	}
+	if acl, err := shareACL(info.SecurityDescriptor); err == nil {
+		details.ACL = acl
+	}
+	return details
+}
+
+// GetShareDetails returns the details of the shares by name. Level 502 with
+// the share ACLs is tried first, then level 2.
+func (conn *Connection) GetShareDetails() (map[string]*ShareDetails, error) {
+	share := "IPC$"
+	err := conn.session.TreeConnect(share)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.session.TreeDisconnect(share)
+	f, err := conn.session.OpenFile(share, "srvsvc")
+	if err != nil {
+		return nil, err
+	}
+	defer f.CloseFile()
+	transport, err := smbtransport.NewSMBTransport(f)
+	if err != nil {
+		return nil, err
+	}
+	bind, err := dcerpc.Bind(transport, mssrvs.MSRPCUuidSrvSvc, 3, 0, dcerpc.MSRPCUuidNdr)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []shareInfo
+	var level uint32
+	for _, level = range []uint32{502, 2} {
+		var response []byte
+		response, err = bind.MakeRequest(mssrvs.SrvSvcOpNetShareEnumAll, netShareEnumRequest(conn.host, level))
+		if err != nil {
+			return nil, err
+		}
+		infos, err = parseNetShareEnumResponse(response)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	details := make(map[string]*ShareDetails, len(infos))
+	for _, info := range infos {
+		details[info.Name] = shareDetails(info, level)
+	}
+	return details, nil
+}
+
+// netShareEnumRequest returns a NetrShareEnum request for level without a
+// resume handle.
+func netShareEnumRequest(serverName string, level uint32) []byte {
+	refID := uint32(1)
+	w := new(bytes.Buffer)
+	_, _ = msdtyp.WriteConformantVaryingStringPtr(w, serverName, &refID, true)
+	// level and union discriminator
+	_ = binary.Write(w, binary.LittleEndian, level)
+	_ = binary.Write(w, binary.LittleEndian, level)
+	// container pointer, empty container
+	_ = binary.Write(w, binary.LittleEndian, refID)
+	refID++
+	_ = binary.Write(w, binary.LittleEndian, uint32(0))
+	_ = binary.Write(w, binary.LittleEndian, uint32(0))
+	// preferred max length
+	_ = binary.Write(w, binary.LittleEndian, uint32(0xffffffff))
+	// resume handle pointer and value
+	_ = binary.Write(w, binary.LittleEndian, refID)
+	_ = binary.Write(w, binary.LittleEndian, uint32(0))
+	return w.Bytes()
+}
+
+// parseNetShareEnumResponse returns the entries of a NetrShareEnum level 2 or
+// 502 response, or the Windows error of the response.
+func parseNetShareEnumResponse(response []byte) ([]shareInfo, error) {
+	if len(response) < 4 {
+		return nil, errors.New("truncated NetShareEnum response")
+	}
+	windowsError := binary.LittleEndian.Uint32(response[len(response)-4:])
+	if windowsError != mssrvs.ErrorSuccess {
+		if responseErr, found := mssrvs.SRVSResponseCodeMap[windowsError]; found {
+			return nil, responseErr
+		}
+		return nil, fmt.Errorf("NetShareEnum returned error 0x%x", windowsError)
+	}
+
+	r := bytes.NewReader(response)
+	var header struct {
+		Level         uint32
+		Discriminator uint32
+		Container     uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Level != 2 && header.Level != 502 {
+		return nil, fmt.Errorf("unexpected NetShareEnum level %d", header.Level)
+	}
+	if header.Container == 0 {
+		return nil, nil
+	}
+	var container struct {
+		EntriesRead uint32
+		Buffer      uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &container); err != nil {
+		return nil, err
+	}
+	if container.Buffer == 0 || container.EntriesRead == 0 {
+		return nil, nil
+	}
+	var maxCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &maxCount); err != nil {
+		return nil, err
+	}
+	if int(container.EntriesRead) > r.Len()/32 {
+		return nil, errors.New("truncated NetShareEnum response")
+	}
+
+	// the fixed part of every entry, followed by the strings and security
+	// descriptors the pointers refer to
+	type entry struct {
+		Name, Type, Remark, Permissions, MaxUses, CurrentUses, Path, Password uint32
+		Reserved, SecurityDescriptor                                          uint32
+	}
+	entries := make([]entry, container.EntriesRead)
+	for i := range entries {
+		fields := []any{&entries[i].Name, &entries[i].Type, &entries[i].Remark, &entries[i].Permissions, &entries[i].MaxUses, &entries[i].CurrentUses, &entries[i].Path, &entries[i].Password}
+		if header.Level == 502 {
+			fields = append(fields, &entries[i].Reserved, &entries[i].SecurityDescriptor)
+		}
+		for _, field := range fields {
+			if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	infos := make([]shareInfo, len(entries))
+	readString := func(pointer uint32) (string, error) {
+		if pointer == 0 {
+			return "", nil
+		}
+		return msdtyp.ReadConformantVaryingString(r, true)
+	}
+	for i, e := range entries {
+		info := shareInfo{Type: e.Type, Permissions: e.Permissions, MaxUses: e.MaxUses, CurrentUses: e.CurrentUses}
+		var err error
+		if info.Name, err = readString(e.Name); err != nil {
+			return nil, err
+		}
+		if info.Remark, err = readString(e.Remark); err != nil {
+			return nil, err
+		}
+		if info.Path, err = readString(e.Path); err != nil {
+			return nil, err
+		}
+		if _, err = readString(e.Password); err != nil {
+			return nil, err
+		}
+		if e.SecurityDescriptor != 0 {
+			var size uint32
+			if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+				return nil, err
+			}
+			if int(size) > r.Len() {
+				return nil, errors.New("truncated NetShareEnum security descriptor")
+			}
+			info.SecurityDescriptor = make([]byte, size)
+			if _, err := io.ReadFull(r, info.SecurityDescriptor); err != nil {
+				return nil, err
+			}
+			if padding := (4 - size%4) % 4; padding > 0 {
+				if _, err := r.Seek(int64(padding), io.SeekCurrent); err != nil {
+					return nil, err
+				}
+			}
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
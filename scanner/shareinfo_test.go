@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/jfjallid/go-smb/dcerpc/mssrvs"
+	"github.com/jfjallid/go-smb/msdtyp"
+	"strings"
+	"testing"
+)
+
+// everyoneFullSD is a self-relative security descriptor granting Everyone
+// full access.
+func everyoneFullSD() []byte {
+	sd := []byte{1, 0, 0x04, 0x80}
+	sd = binary.LittleEndian.AppendUint32(sd, 0) // owner
+	sd = binary.LittleEndian.AppendUint32(sd, 0) // group
+	sd = binary.LittleEndian.AppendUint32(sd, 0) // SACL
+	sd = binary.LittleEndian.AppendUint32(sd, 20)
+	// ACL header with one ACE
+	sd = append(sd, 2, 0, 28, 0, 1, 0, 0, 0)
+	sd = append(sd, msdtyp.AccessAllowedAceType, 0, 20, 0)
+	sd = binary.LittleEndian.AppendUint32(sd, shareAccessFull)
+	return append(sd, 1, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0)
+}
+
+func TestShareType(t *testing.T) {
+	tests := map[uint32]string{
+		mssrvs.StypeDisktree:                          "disk",
+		mssrvs.StypeDisktree | mssrvs.StypeSpecial:    "disk,special",
+		mssrvs.StypeIPC | mssrvs.StypeSpecial:         "ipc,special",
+		mssrvs.StypePrintq:                            "printer",
+		mssrvs.StypeDisktree | mssrvs.StypeTemporary:  "disk,temporary",
+		mssrvs.StypeDisktree | mssrvs.StypeClusterDFS: "disk",
+	}
+	for stype, expected := range tests {
+		if got := ShareType(stype); got != expected {
+			t.Errorf("0x%x: expected %s, got %s", stype, expected, got)
+		}
+	}
+
+	share := mssrvs.NetShare{Name: "IPC$", Type: "IPC_Hidden", TypeId: mssrvs.StypeIPC, Hidden: true}
+	if got := ShareType(netShareType(share)); got != "ipc,special" {
+		t.Errorf("expected ipc,special, got %s", got)
+	}
+}
+
+func TestShareExposure(t *testing.T) {
+	tests := []struct {
+		path     string
+		stype    uint32
+		expected string
+	}{
+		{`C:\`, mssrvs.StypeDisktree, ShareExposureVolumeRoot},
+		{`D:`, mssrvs.StypeDisktree, ShareExposureVolumeRoot},
+		{`C:\Windows\System32`, mssrvs.StypeDisktree, ShareExposureSystemDirectory},
+		{`c:\program files (x86)`, mssrvs.StypeDisktree, ShareExposureSystemDirectory},
+		{`C:\Windows\SYSVOL\sysvol`, mssrvs.StypeDisktree, ""},
+		{`C:\WindowsApps`, mssrvs.StypeDisktree, ""},
+		{`D:\Shares\Finance`, mssrvs.StypeDisktree, ""},
+		// administrative shares are roots by design
+		{`C:\`, mssrvs.StypeDisktree | mssrvs.StypeSpecial, ""},
+	}
+	for _, test := range tests {
+		if got := shareExposure(test.path, test.stype); got != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.path, test.expected, got)
+		}
+	}
+}
+
+func TestShareACL(t *testing.T) {
+	acl, err := shareACL(everyoneFullSD())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl != "Everyone:FULL" {
+		t.Errorf("unexpected ACL %q", acl)
+	}
+	if acl, err := shareACL(nil); err != nil || acl != "" {
+		t.Errorf("expected no ACL, got %q %v", acl, err)
+	}
+}
+
+func TestParseNetShareEnumResponse(t *testing.T) {
+	sd := everyoneFullSD()
+	w := new(bytes.Buffer)
+	for _, v := range []uint32{502, 502, 0x20000, 1, 0x20004, 1} {
+		_ = binary.Write(w, binary.LittleEndian, v)
+	}
+	// name, type, remark, permissions, max uses, current uses, path, password, reserved, SD
+	for _, v := range []uint32{0x20008, mssrvs.StypeDisktree, 0x2000c, 0, shareMaxUsesUnlimited, 2, 0x20010, 0, uint32(len(sd)), 0x20014} {
+		_ = binary.Write(w, binary.LittleEndian, v)
+	}
+	for _, value := range []string{"Backup", "Backups", `C:\`} {
+		if _, err := msdtyp.WriteConformantVaryingString(w, value, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = binary.Write(w, binary.LittleEndian, uint32(len(sd)))
+	w.Write(sd)
+	// total entries, resume handle and Windows error
+	for _, v := range []uint32{1, 0x20018, 0, 0} {
+		_ = binary.Write(w, binary.LittleEndian, v)
+	}
+
+	infos, err := parseNetShareEnumResponse(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name != "Backup" || infos[0].Remark != "Backups" || infos[0].Path != `C:\` || !bytes.Equal(infos[0].SecurityDescriptor, sd) {
+		t.Fatalf("unexpected entries %+v", infos)
+	}
+
+	details := shareDetails(infos[0], 502)
+	if details.MaxUses != -1 || details.Exposure != ShareExposureVolumeRoot || details.ACL != "Everyone:FULL" {
+		t.Errorf("unexpected details %+v", details)
+	}
+	output := sprintShareDetails(details)
+	if !strings.Contains(output, "(uses:2/unlimited)") || !strings.Contains(output, "(exposure:volume_root)") {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	denied := binary.LittleEndian.AppendUint32(make([]byte, 24), mssrvs.SRVSErrorAccessDenied)
+	if _, err := parseNetShareEnumResponse(denied); err != mssrvs.SRVSResponseCodeMap[mssrvs.SRVSErrorAccessDenied] {
+		t.Errorf("expected access denied, got %v", err)
+	}
+}
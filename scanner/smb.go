@@ -23,14 +23,27 @@ type Connection struct {
 	session *smb.Connection
 }
 
-func NewSMBConnection(host DNHost, username, password string, hashes []byte, kerberos, localAuth bool, domain string, timeout time.Duration, smbPort int, proxyDialer proxy.Dialer, dcIP net.IP, nullSession bool, dcHostname string) (*Connection, error) {
-	options := GetSMBOptions(host, username, password, hashes, kerberos, localAuth, domain, timeout, smbPort, proxyDialer, dcIP, nullSession, dcHostname)
+func NewSMBConnection(host DNHost, username, password string, hashes []byte, kerberos bool, tickets *KerberosTicketCache, localAuth bool, domain string, timeout time.Duration, smbPort int, proxyDialer proxy.Dialer, dcIP net.IP, nullSession bool, dcHostname string, guard *LockoutGuard) (*Connection, error) {
+	options, err := GetSMBOptions(host, username, password, hashes, kerberos, tickets, localAuth, domain, timeout, smbPort, proxyDialer, dcIP, nullSession, dcHostname)
+	if err != nil {
+		// Kerberos logons happen while building the options, the KDC may also be unreachable
+		guard.Record(err)
+		return nil, newHostError(classifyConnectionError(err), err)
+	}
+	// the session setup is passed through the lockout guard after negotiation
+	options.ManualLogin = guard != nil
 
 	// establish the connection
 	session, err := smb.NewConnection(options)
 	if err != nil {
 		return nil, err
 	}
+	if guard != nil {
+		if err = guard.Authenticate(session.SessionSetup); err != nil {
+			session.Close()
+			return nil, err
+		}
+	}
 	conn := &Connection{
 		host:    host.IP.String(),
 		session: session,
@@ -38,7 +51,7 @@ func NewSMBConnection(host DNHost, username, password string, hashes []byte, ker
 	return conn, nil
 }
 
-func GetSMBOptions(host DNHost, username, password string, hashes []byte, kerberos, localAuth bool, domain string, timeout time.Duration, smbPort int, proxyDialer proxy.Dialer, dcIP net.IP, nullSession bool, dcHostname string) smb.Options {
+func GetSMBOptions(host DNHost, username, password string, hashes []byte, kerberos bool, tickets *KerberosTicketCache, localAuth bool, domain string, timeout time.Duration, smbPort int, proxyDialer proxy.Dialer, dcIP net.IP, nullSession bool, dcHostname string) (smb.Options, error) {
 	smbOptions := smb.Options{
 		Host:                  host.IP.String(),
 		Port:                  smbPort,
@@ -58,7 +71,10 @@ func GetSMBOptions(host DNHost, username, password string, hashes []byte, kerber
 				logger.Debugf("Resolved %s to %s for Kerberos SPN", host.IP.String(), hostname)
 			} else if dcHostname != "" && domain != "" {
 				// construct FQDN from dc-hostname + domain when target is the DC itself
-				hostname = dcHostname + "." + domain
+				hostname = dcHostname
+				if !strings.Contains(hostname, ".") {
+					hostname += "." + domain
+				}
 				logger.Debugf("Using DC hostname for SPN: %s", hostname)
 			} else {
 				logger.Warnf("Kerberos requires a hostname for SPN but target %s has no hostname — use hunt command or specify target as hostname", host.IP.String())
@@ -68,18 +84,30 @@ func GetSMBOptions(host DNHost, username, password string, hashes []byte, kerber
 		if dcIP != nil && !dcIP.Equal(net.IPv4zero) {
 			dcIPStr = dcIP.String()
 		}
-		smbOptions.Initiator = &spnego.KRB5Initiator{
+		initiator := &spnego.KRB5Initiator{
 			Domain:      domain,
 			User:        username,
 			Password:    password,
 			Hash:        hashes,
-			AESKey:      nil,
 			SPN:         "cifs/" + hostname,
 			DCIP:        dcIPStr,
 			DialTimeout: timeout,
 			ProxyDialer: proxyDialer,
 			Host:        hostname,
 		}
+		if tickets == nil {
+			// without a shared ticket cache the initiator requests its own TGT
+			smbOptions.Initiator = initiator
+		} else {
+			client, err := tickets.Client(initiator.SPN)
+			if err != nil {
+				return smbOptions, err
+			}
+			if err = initiator.SetClient(client); err != nil {
+				return smbOptions, fmt.Errorf("kerberos client for %s: %w", initiator.SPN, err)
+			}
+			smbOptions.Initiator = &sharedKRB5Initiator{initiator}
+		}
 	} else {
 		smbOptions.Initiator = &spnego.NTLMInitiator{
 			Domain:      domain,
@@ -91,9 +119,17 @@ func GetSMBOptions(host DNHost, username, password string, hashes []byte, kerber
 		}
 	}
 
-	return smbOptions
+	return smbOptions, nil
 }
 
+// sharedKRB5Initiator is a Kerberos initiator backed by a KerberosTicketCache.
+// Logoff must not destroy the client, as other connections still use it.
+type sharedKRB5Initiator struct {
+	*spnego.KRB5Initiator
+}
+
+func (i *sharedKRB5Initiator) Logoff() {}
+
 // Close is a function to close the active connection
 func (conn *Connection) Close() {
 	conn.session.Close()
@@ -188,38 +224,38 @@ func (conn *Connection) CheckLocalAdmin() (bool, error) {
 	return false, fmt.Errorf("unexpected svcctl admin-check return code: 0x%x", res.ReturnCode)
 }
 
-func (conn *Connection) DetectWindowsVersion(fallback string) (string, error) {
+func (conn *Connection) DetectWindowsVersion() (WindowsVersion, error) {
 	share := "IPC$"
 	if err := conn.session.TreeConnect(share); err != nil {
-		return buildWindowsVersionString("", "", "", "", "", 0, fallback), err
+		return WindowsVersion{}, err
 	}
 	defer conn.session.TreeDisconnect(share)
 
 	f, err := conn.session.OpenFile(share, msrrp.MSRRPPipe)
 	if err != nil {
-		return buildWindowsVersionString("", "", "", "", "", 0, fallback), err
+		return WindowsVersion{}, err
 	}
 	defer f.CloseFile()
 
 	transport, err := smbtransport.NewSMBTransport(f)
 	if err != nil {
-		return buildWindowsVersionString("", "", "", "", "", 0, fallback), err
+		return WindowsVersion{}, err
 	}
 	bind, err := dcerpc.Bind(transport, msrrp.MSRRPUuid, msrrp.MSRRPMajorVersion, msrrp.MSRRPMinorVersion, dcerpc.MSRPCUuidNdr)
 	if err != nil {
-		return buildWindowsVersionString("", "", "", "", "", 0, fallback), err
+		return WindowsVersion{}, err
 	}
 
 	rpccon := msrrp.NewRPCCon(bind)
 	hklm, err := rpccon.OpenBaseKey(msrrp.HKEYLocalMachine)
 	if err != nil {
-		return buildWindowsVersionString("", "", "", "", "", 0, fallback), err
+		return WindowsVersion{}, err
 	}
 	defer rpccon.CloseKeyHandle(hklm)
 
 	currentVersionKey, err := rpccon.OpenSubKey(hklm, `SOFTWARE\Microsoft\Windows NT\CurrentVersion`)
 	if err != nil {
-		return buildWindowsVersionString("", "", "", "", "", 0, fallback), err
+		return WindowsVersion{}, err
 	}
 	defer rpccon.CloseKeyHandle(currentVersionKey)
 
@@ -246,15 +282,14 @@ func (conn *Connection) DetectWindowsVersion(fallback string) (string, error) {
 		return dwordValue
 	}
 
-	return buildWindowsVersionString(
-		queryString("ProductName"),
-		queryString("DisplayVersion"),
-		queryString("ReleaseId"),
-		queryString("CurrentVersion"),
-		queryString("CurrentBuildNumber"),
-		queryDWORD("UBR"),
-		fallback,
-	), nil
+	return WindowsVersion{
+		ProductName:    queryString("ProductName"),
+		DisplayVersion: queryString("DisplayVersion"),
+		ReleaseID:      queryString("ReleaseId"),
+		CurrentVersion: queryString("CurrentVersion"),
+		Build:          queryString("CurrentBuildNumber"),
+		UBR:            queryDWORD("UBR"),
+	}, nil
 }
 
 func (conn *Connection) CheckReadAccess(share string) error {
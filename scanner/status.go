@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"github.com/jfjallid/go-smb/smb"
+	"github.com/jfjallid/gokrb5/v8/iana/errorcode"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Host statuses recorded in the status attribute of a host. A host that failed
+// before authentication has no shares, a host with a listing status was
+// authenticated but its shares could not be read.
+const (
+	HostStatusOK                = "ok"
+	HostStatusUnreachable       = "unreachable"
+	HostStatusTimeout           = "timeout"
+	HostStatusNegotiation       = "negotiation_failed"
+	HostStatusAuthFailed        = "auth_failed"
+	HostStatusAccountRestricted = "account_restricted"
+	HostStatusSrvsvcDenied      = "srvsvc_denied"
+	HostStatusListingError      = "listing_error"
+	HostStatusSkipped           = "skipped"
+)
+
+// HostStatuses lists the host statuses in report order.
+var HostStatuses = []string{
+	HostStatusOK,
+	HostStatusSrvsvcDenied,
+	HostStatusListingError,
+	HostStatusAuthFailed,
+	HostStatusAccountRestricted,
+	HostStatusNegotiation,
+	HostStatusTimeout,
+	HostStatusUnreachable,
+	HostStatusSkipped,
+}
+
+// HostError is a failure to enumerate a host together with its status.
+type HostError struct {
+	Status string
+	Err    error
+}
+
+func (e *HostError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HostError) Unwrap() error {
+	return e.Err
+}
+
+// newHostError wraps err with status, errors that already carry a status are
+// returned as is.
+func newHostError(status string, err error) error {
+	var hostErr *HostError
+	if errors.As(err, &hostErr) {
+		return err
+	}
+	return &HostError{Status: status, Err: err}
+}
+
+// HostStatus returns the status of an enumeration error. Errors without a
+// status are classified as connection failures.
+func HostStatus(err error) string {
+	if err == nil {
+		return HostStatusOK
+	}
+	var hostErr *HostError
+	if errors.As(err, &hostErr) {
+		return hostErr.Status
+	}
+	return classifyConnectionError(err)
+}
+
+// accountRestrictions are the logon errors of a valid account that can't be used.
+var accountRestrictions = []uint32{
+	smb.StatusAccountDisabled,
+	smb.StatusAccountLockedOut,
+	smb.StatusAccountRestriction,
+	smb.StatusPasswordExpired,
+	smb.StatusPasswordMustChange,
+}
+
+// classifyConnectionError classifies an error of connecting, negotiating the
+// dialect or setting up the session with a host.
+func classifyConnectionError(err error) string {
+	for _, status := range accountRestrictions {
+		if errors.Is(err, smb.StatusMap[status]) {
+			return HostStatusAccountRestricted
+		}
+	}
+	message := err.Error()
+	if code, ok := kerberosErrorCode(err); ok && (code == errorcode.KDC_ERR_CLIENT_REVOKED || code == errorcode.KDC_ERR_KEY_EXPIRED) {
+		return HostStatusAccountRestricted
+	}
+	if isLogonFailure(err) || errors.Is(err, smb.StatusMap[smb.StatusAccessDenied]) || isKerberosLogonFailure(err) {
+		return HostStatusAuthFailed
+	}
+
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) || strings.Contains(message, "i/o timeout") {
+		return HostStatusTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return HostStatusUnreachable
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return HostStatusUnreachable
+	}
+	// SOCKS proxies report the failure of the remote dial in the reply
+	for _, reason := range []string{"connection refused", "host unreachable", "network unreachable"} {
+		if strings.Contains(message, reason) {
+			return HostStatusUnreachable
+		}
+	}
+	return HostStatusNegotiation
+}
+
+// classifyListingError classifies an error of listing the shares of an
+// authenticated host.
+func classifyListingError(err error) string {
+	if errors.Is(err, smb.StatusMap[smb.StatusAccessDenied]) || strings.Contains(strings.ToLower(err.Error()), "access denied") {
+		return HostStatusSrvsvcDenied
+	}
+	return HostStatusListingError
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/jfjallid/go-smb/smb"
+	"github.com/jfjallid/gokrb5/v8/iana/errorcode"
+	smbmessages "github.com/jfjallid/gokrb5/v8/messages"
+)
+
+func TestClassifyConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"logon failure", smb.StatusMap[smb.StatusLogonFailure], HostStatusAuthFailed},
+		{"locked out", smb.StatusMap[smb.StatusAccountLockedOut], HostStatusAccountRestricted},
+		{"disabled", fmt.Errorf("session setup: %w", smb.StatusMap[smb.StatusAccountDisabled]), HostStatusAccountRestricted},
+		{"kerberos preauth", errors.New("kerberos: KRB Error: (24) KDC_ERR_PREAUTH_FAILED"), HostStatusAuthFailed},
+		{"kerberos revoked", errors.New("kerberos: KRB Error: (18) KDC_ERR_CLIENT_REVOKED"), HostStatusAccountRestricted},
+		{"kerberos key expired", fmt.Errorf("kerberos: %w", smbmessages.KRBError{ErrorCode: errorcode.KDC_ERR_KEY_EXPIRED}), HostStatusAccountRestricted},
+		{"kerberos preauth error", fmt.Errorf("kerberos: %w", smbmessages.KRBError{ErrorCode: errorcode.KDC_ERR_PREAUTH_FAILED}), HostStatusAuthFailed},
+		{"kdc timeout", errors.New("kerberos: [Root cause: Networking_Error] Networking_Error: AS Exchange Error: failed sending AS_REQ to KDC: failed to communicate with KDC 10.0.0.1:88: dial tcp 10.0.0.1:88: i/o timeout"), HostStatusTimeout},
+		{"kdc refused", errors.New("kerberos: [Root cause: Networking_Error] Networking_Error: AS Exchange Error: failed sending AS_REQ to KDC: dial tcp 10.0.0.1:88: connect: connection refused"), HostStatusUnreachable},
+		{"dial timeout", &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, HostStatusTimeout},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, HostStatusUnreachable},
+		{"socks refused", errors.New("socks connect tcp 127.0.0.1:1080->10.0.0.5:445: unknown error connection refused"), HostStatusUnreachable},
+		{"eof", errors.New("EOF"), HostStatusNegotiation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyConnectionError(tt.err); got != tt.want {
+				t.Errorf("classifyConnectionError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyListingError(t *testing.T) {
+	if got := classifyListingError(smb.StatusMap[smb.StatusAccessDenied]); got != HostStatusSrvsvcDenied {
+		t.Errorf("expected %q, got %q", HostStatusSrvsvcDenied, got)
+	}
+	if got := classifyListingError(errors.New("unexpected NDR data")); got != HostStatusListingError {
+		t.Errorf("expected %q, got %q", HostStatusListingError, got)
+	}
+}
+
+func TestHostStatus(t *testing.T) {
+	if got := HostStatus(nil); got != HostStatusOK {
+		t.Errorf("expected %q for nil, got %q", HostStatusOK, got)
+	}
+	err := newHostError(HostStatusSrvsvcDenied, smb.StatusMap[smb.StatusAccessDenied])
+	if got := HostStatus(fmt.Errorf("enumerate: %w", err)); got != HostStatusSrvsvcDenied {
+		t.Errorf("expected wrapped status %q, got %q", HostStatusSrvsvcDenied, got)
+	}
+	if rewrapped := newHostError(HostStatusAuthFailed, err); HostStatus(rewrapped) != HostStatusSrvsvcDenied {
+		t.Error("an existing status must not be replaced")
+	}
+	if !errors.Is(err, smb.StatusMap[smb.StatusAccessDenied]) {
+		t.Error("host error must unwrap to the NT status")
+	}
+}
+
+func TestHostStatusCounts(t *testing.T) {
+	run := &SharefinderRun{Hosts: []Host{
+		{IP: "10.0.0.1"},
+		{IP: "10.0.0.2", Status: HostStatusOK},
+		{IP: "10.0.0.3", Status: HostStatusSrvsvcDenied},
+		{IP: "10.0.0.4", Status: HostStatusTimeout},
+		{IP: "10.0.0.5", Status: HostStatusTimeout},
+	}}
+
+	if run.HostCount() != 3 {
+		t.Errorf("expected 3 enumerated hosts, got %d", run.HostCount())
+	}
+	if failed := run.FailedHosts(); len(failed) != 2 {
+		t.Errorf("expected 2 failed hosts, got %d", len(failed))
+	}
+	want := []HostStatusCount{
+		{Status: HostStatusOK, Count: 2},
+		{Status: HostStatusSrvsvcDenied, Count: 1},
+		{Status: HostStatusTimeout, Count: 2},
+	}
+	got := run.HostStatusCounts()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
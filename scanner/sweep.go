@@ -0,0 +1,152 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/vflame6/sharefinder/logger"
+	"golang.org/x/net/proxy"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// netbiosSessionPort is the NetBIOS session service port, probed besides --smb-port
+const netbiosSessionPort = 139
+
+// SweepResult records the outcome of the TCP port sweep
+type SweepResult struct {
+	Ports  string `xml:"ports,attr"`
+	Probed int    `xml:"probed,attr"`
+	Live   int    `xml:"live,attr"`
+}
+
+// sweepPorts returns the ports probed by the sweep.
+func sweepPorts(smbPort int) []int {
+	if smbPort == netbiosSessionPort {
+		return []int{smbPort}
+	}
+	return []int{smbPort, netbiosSessionPort}
+}
+
+// formatPorts returns ports as a comma separated list.
+func formatPorts(ports []int) string {
+	values := make([]string, len(ports))
+	for i, port := range ports {
+		values[i] = strconv.Itoa(port)
+	}
+	return strings.Join(values, ",")
+}
+
+// probePorts connects to every port of ip at once and returns the open ones
+// in the order of ports. Connections go through proxyDialer if it is set.
+func probePorts(ip net.IP, ports []int, timeout time.Duration, proxyDialer proxy.Dialer) []int {
+	open := make([]bool, len(ports))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			open[i] = dialPort(ip, port, timeout, proxyDialer)
+		}(i, port)
+	}
+	wg.Wait()
+
+	result := []int{}
+	for i, port := range ports {
+		if open[i] {
+			result = append(result, port)
+		}
+	}
+	return result
+}
+
+// dialPort reports whether a TCP connection to ip:port can be established.
+func dialPort(ip net.IP, port int, timeout time.Duration, proxyDialer proxy.Dialer) bool {
+	conn, err := dialTCP(ip, port, timeout, proxyDialer)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// dialTCP connects to ip:port within timeout, through proxyDialer if it is set.
+func dialTCP(ip net.IP, port int, timeout time.Duration, proxyDialer proxy.Dialer) (net.Conn, error) {
+	address := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if proxyDialer != nil {
+		if contextDialer, ok := proxyDialer.(proxy.ContextDialer); ok {
+			return contextDialer.DialContext(ctx, "tcp", address)
+		}
+		return proxyDialer.Dial("tcp", address)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+// runSweep probes the targets for open SMB ports with Options.SweepThreads
+// workers and returns a channel of the live hosts only. The channel is closed
+// when the targets are over, after the outcome is recorded in the run summary.
+func (s *Scanner) runSweep(targets <-chan DNHost) <-chan DNHost {
+	ports := sweepPorts(s.Options.SmbPort)
+	live := make(chan DNHost, cap(s.Options.Target))
+	var probed, found atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.Options.SweepThreads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range targets {
+				probed.Add(1)
+				host.Ports = probePorts(host.IP, ports, s.Options.SweepTimeout, s.Options.ProxyDialer)
+				if len(host.Ports) == 0 {
+					logger.Debugf("No open ports among %s on %s", formatPorts(ports), host.IP.String())
+					continue
+				}
+				logger.Debugf("Found open ports %s on %s (%s)", formatPorts(host.Ports), host.IP.String(), host.Hostname)
+				found.Add(1)
+				live <- host
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		result := SweepResult{Ports: formatPorts(ports), Probed: int(probed.Load()), Live: int(found.Load())}
+		logger.Warnf("Port sweep found %d live hosts of %d", result.Live, result.Probed)
+		s.summaryMutex.Lock()
+		s.Summary.Sweep = &result
+		s.summaryMutex.Unlock()
+		close(live)
+	}()
+	return live
+}
+
+// liveHostThread prints the live hosts found by the sweep, one address per
+// line, so the list can be used as a target file.
+func liveHostThread(options *Options, targets <-chan DNHost, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for host := range targets {
+		logger.Info(host.IP.String())
+		if options.FileTXT != nil {
+			err := options.Writer.Write(fmt.Sprintln(host.IP.String()), options.FileTXT)
+			if err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+}
+
+// hasPort reports whether the sweep found port open on host. Hosts that were
+// not swept are assumed to have every port open.
+func (h DNHost) hasPort(port int) bool {
+	return h.Ports == nil || slices.Contains(h.Ports, port)
+}
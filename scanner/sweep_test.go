@@ -0,0 +1,55 @@
+package scanner
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSweepPorts(t *testing.T) {
+	if got := sweepPorts(445); !reflect.DeepEqual(got, []int{445, 139}) {
+		t.Errorf("expected [445 139], got %v", got)
+	}
+	if got := sweepPorts(139); !reflect.DeepEqual(got, []int{139}) {
+		t.Errorf("expected [139], got %v", got)
+	}
+	if got := formatPorts([]int{445, 139}); got != "445,139" {
+		t.Errorf("expected 445,139, got %q", got)
+	}
+}
+
+func TestProbePorts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	openPort := listener.Addr().(*net.TCPAddr).Port
+
+	// a port that was just released is closed
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	defer listener.Close()
+
+	got := probePorts(net.ParseIP("127.0.0.1"), []int{closedPort, openPort}, time.Second, nil)
+	if !reflect.DeepEqual(got, []int{openPort}) {
+		t.Errorf("expected [%d], got %v", openPort, got)
+	}
+}
+
+func TestDNHostHasPort(t *testing.T) {
+	if !(DNHost{}).hasPort(445) {
+		t.Error("hosts that were not swept must be assumed open")
+	}
+	swept := DNHost{Ports: []int{139}}
+	if swept.hasPort(445) || !swept.hasPort(139) {
+		t.Errorf("unexpected ports of %v", swept.Ports)
+	}
+	if (DNHost{Ports: []int{}}).hasPort(445) {
+		t.Error("a swept host without open ports has no port")
+	}
+}
@@ -3,6 +3,7 @@ package scanner
 import (
 	"errors"
 	"fmt"
+	"github.com/jfjallid/go-smb/spnego"
 	"github.com/vflame6/sharefinder/logger"
 	"github.com/vflame6/sharefinder/utils"
 	"slices"
@@ -14,39 +15,67 @@ func enumerateHost(host DNHost, options *Options) (Host, error) {
 	var hostResult Host
 	var shareResult []Share
 
+	// use the host-specific local credential if one is available, e.g. from LAPS
+	username, password, hash := options.Username, options.Password, options.HashBytes
+	kerberos, localAuth, domain, nullSession := options.Kerberos, options.LocalAuth, options.Domain, options.NullSession
+	guard := options.LockoutGuard
+	if host.Credential.Usable() {
+		username, password, hash = host.Credential.Username, host.Credential.Password, host.Credential.Hash
+		kerberos, localAuth, domain, nullSession = false, host.Credential.LocalAuth, host.Credential.Domain, false
+		// per-host credentials don't count towards the scan account lockout
+		guard = nil
+		logger.Debugf("Using %s credential %s for %s (%s)", host.Credential.Source, host.Credential, host.IP.String(), host.Hostname)
+	}
+
 	// get an SMB connection with NTLM authentication method
 	logger.Debugf("Trying to establish SMB connection to %s (%s)", host.IP.String(), host.Hostname)
-	conn, err := NewSMBConnection(
-		host,
-		options.Username,
-		options.Password,
-		options.HashBytes,
-		options.Kerberos,
-		options.LocalAuth,
-		options.Domain,
-		options.Timeout,
-		options.SmbPort,
-		options.ProxyDialer,
-		options.DomainController,
-		options.NullSession,
-		options.DCHostname,
-	)
+	var conn *Connection
+	transport, err := connectSMB(host, options, func(transport smbTransport) error {
+		var connErr error
+		conn, connErr = NewSMBConnection(
+			host,
+			username,
+			password,
+			hash,
+			kerberos,
+			options.KerberosTickets,
+			localAuth,
+			domain,
+			options.Timeout,
+			transport.Port,
+			transport.Dialer,
+			options.DomainController,
+			nullSession,
+			options.DCHostname,
+			guard,
+		)
+		return connErr
+	})
 	if err != nil {
-		return hostResult, err
+		return hostResult, newHostError(classifyConnectionError(err), err)
 	}
 	defer conn.Close()
 
 	// check if message signing is required
 	isSigningRequired := conn.session.IsSigningRequired()
 	if !conn.session.IsAuthenticated() {
-		return hostResult, fmt.Errorf("not authenticated status on host %s after successful connection", host)
+		return hostResult, newHostError(HostStatusAuthFailed, fmt.Errorf("not authenticated status on host %s after successful connection", host.IP.String()))
 	}
 	logger.Debugf("Successfully established SMB connection to %s (%s)", host.IP.String(), host.Hostname)
 
 	// get base info about connected target
 	targetInfo := conn.GetTargetInfo()
 	hostResult.IP = host.IP.String()
+	hostResult.Source = host.Source
+	if host.Credential.IsLAPS() {
+		hostResult.LAPS = host.Credential.Source
+	} else if host.Credential != nil && host.Credential.Source == CredentialSourceEncrypted {
+		// scanned with the global account
+		hostResult.LAPSEncrypted = true
+	}
 	hostResult.Time = time.Now()
+	hostResult.Status = HostStatusOK
+	hostResult.Transport = transport.Name
 	hostResult.Version = "unknown"
 	if targetInfo != nil {
 		if targetInfo.GuessedOSVersion != "" {
@@ -60,7 +89,25 @@ func enumerateHost(host DNHost, options *Options) (Host, error) {
 		hostResult.Domain = options.Domain
 	}
 	hostResult.Signing = isSigningRequired
-	if !options.NullSession {
+	hostResult.setSession(scanSessionType(options, nullSession), conn.SessionType())
+	if hostResult.SessionMapped() {
+		logger.Debugf("%s (%s) mapped the %s logon to a %s session", host.IP.String(), host.Hostname, hostResult.RequestedSession, hostResult.Session)
+	}
+	windowsVersion := guessedWindowsVersion(hostResult.Version)
+	if options.Fingerprint {
+		// the NTLM session of the scan account tells whether the server requires
+		// encryption, per-host credentials aren't tracked by the guard
+		var initiator *spnego.NTLMInitiator
+		if !kerberos && !nullSession && username != "" && !host.Credential.Usable() {
+			initiator = &spnego.NTLMInitiator{User: username, Password: password, Hash: hash, Domain: domain, LocalUser: localAuth}
+		}
+		fingerprint, fingerprintErr := FingerprintHost(host.IP, transport.Port, options.Timeout, transport.Dialer, initiator, guard)
+		if fingerprintErr != nil {
+			logger.Debugf("Failed to fingerprint %s: %v", host.IP.String(), fingerprintErr)
+		}
+		hostResult.Fingerprint = fingerprint
+	}
+	if !nullSession {
 		isAdmin, adminErr := conn.CheckLocalAdmin()
 		if adminErr != nil {
 			// Access-denied is the common, expected case for non-admin sessions; keep it debug-only.
@@ -68,41 +115,107 @@ func enumerateHost(host DNHost, options *Options) (Host, error) {
 		} else {
 			hostResult.Admin = &isAdmin
 			if isAdmin {
-				version, versionErr := conn.DetectWindowsVersion(hostResult.Version)
+				version, versionErr := conn.DetectWindowsVersion()
 				if versionErr != nil {
 					logger.Debugf("Failed to query registry version on %s: %v", host.IP.String(), versionErr)
 				} else {
-					hostResult.Version = version
+					hostResult.Version = version.String(hostResult.Version)
+					if version.Build != "" {
+						windowsVersion = version
+					}
+				}
+				if options.AdminAudit {
+					logger.Debugf("Trying to audit services and registry settings on %s (%s)", host.IP.String(), host.Hostname)
+					hostResult.Audit = conn.AuditHost()
 				}
 			}
 		}
 	}
+	hostResult.OS = options.Lifecycle.Assess(windowsVersion, hostResult.Time)
+	if options.Accounts {
+		// enumerated before the shares, so a denied srvsvc doesn't hide anonymous LSA and SAMR access
+		logger.Debugf("Trying to enumerate accounts on %s (%s)", host.IP.String(), host.Hostname)
+		hostResult.Accounts = conn.EnumerateAccounts(hostResult.Hostname, options.RIDFirst, options.RIDLast)
+		if hostResult.Accounts.LSAError != "" {
+			logger.Debugf("Failed to cycle RIDs on %s: %s", host.IP.String(), hostResult.Accounts.LSAError)
+		}
+		if hostResult.Accounts.SAMRError != "" {
+			logger.Debugf("Failed to enumerate SAMR accounts on %s: %s", host.IP.String(), hostResult.Accounts.SAMRError)
+		}
+	}
+
+	if options.Pipes {
+		logger.Debugf("Trying to probe named pipes on %s (%s)", host.IP.String(), host.Hostname)
+		pipes, pipesErr := conn.ProbePipes(hostResult.Session)
+		if pipesErr != nil {
+			logger.Debugf("Failed to probe named pipes on %s: %v", host.IP.String(), pipesErr)
+		}
+		hostResult.Pipes = pipes
+	}
 
 	// get a list of shares
 	logger.Debugf("Trying to list shares on %s (%s)", host.IP.String(), host.Hostname)
-	shares, err := conn.GetSharesList()
-	if err != nil {
-		return hostResult, err
+	shares, listErr := conn.GetSharesList()
+	if listErr != nil {
+		// the host is still recorded, with the reason its shares are missing
+		hostResult.Status = classifyListingError(listErr)
+		hostResult.Reason = listErr.Error()
+	} else {
+		logger.Debugf("Successfully listed shares on %s (%s)", host.IP.String(), host.Hostname)
 	}
-	logger.Debugf("Successfully listed shares on %s (%s)", host.IP.String(), host.Hostname)
 
-	// get permissions on shares
+	// hardened hosts deny srvsvc, but their shares can still be connected to by name
+	var bruteforced []string
+	if listErr != nil || options.BruteforceShares {
+		known := slices.Clone(options.Exclude)
+		for _, share := range shares {
+			known = append(known, share.Name)
+		}
+		logger.Debugf("Trying to brute-force share names on %s (%s)", host.IP.String(), host.Hostname)
+		bruteforced = conn.BruteforceShares(options.ShareWordlist, known)
+	}
+	if listErr != nil && len(bruteforced) == 0 {
+		hostResult.identifyVendor(nil)
+		return hostResult, newHostError(hostResult.Status, listErr)
+	}
+
+	var details map[string]*ShareDetails
+	if listErr == nil {
+		// path, uses and ACL of the shares are only returned to privileged sessions
+		var detailsErr error
+		details, detailsErr = conn.GetShareDetails()
+		if detailsErr != nil {
+			logger.Debugf("Failed to get share details on %s: %v", host.IP.String(), detailsErr)
+		}
+	}
+
+	var candidates []Share
 	for _, share := range shares {
+		candidates = append(candidates, Share{
+			ShareName:   share.Name,
+			Description: share.Comment,
+			Type:        ShareType(netShareType(share)),
+			Details:     details[share.Name],
+		})
+	}
+	for _, name := range bruteforced {
+		candidates = append(candidates, Share{ShareName: name, Discovery: ShareDiscoveryBruteforce})
+	}
+	hostResult.identifyVendor(candidates)
+
+	// get permissions on shares
+	for _, singleShare := range candidates {
 		// check if share is in exclude list
-		if slices.Contains(options.Exclude, share.Name) {
+		if slices.Contains(options.Exclude, singleShare.ShareName) {
 			continue
 		}
 
-		var singleShare Share
-		singleShare.ShareName = share.Name
-		singleShare.Description = share.Comment
-
 		// check read and write access
-		err := conn.CheckReadAccess(share.Name)
+		err := conn.CheckReadAccess(singleShare.ShareName)
 		if err == nil {
 			singleShare.ReadPermission = true
 		}
-		if conn.CheckWriteAccess(share.Name) {
+		if conn.CheckWriteAccess(singleShare.ShareName) {
 			singleShare.WritePermission = true
 		}
 
@@ -165,10 +278,140 @@ func enumerateHost(host DNHost, options *Options) (Host, error) {
 		}
 	}
 	hostResult.Shares = append(hostResult.Shares, shareResult...)
+	if listErr != nil {
+		return hostResult, newHostError(hostResult.Status, listErr)
+	}
+	return hostResult, nil
+}
+
+// infoHost reads the host information from the negotiate response and the
+// NTLM challenge without authenticating.
+func infoHost(host DNHost, options *Options) (Host, error) {
+	var hostResult Host
+
+	logger.Debugf("Trying to fingerprint %s (%s)", host.IP.String(), host.Hostname)
+	var fingerprint *Fingerprint
+	transport, err := connectSMB(host, options, func(transport smbTransport) error {
+		var fingerprintErr error
+		fingerprint, fingerprintErr = FingerprintHost(host.IP, transport.Port, options.Timeout, transport.Dialer, nil, nil)
+		if fingerprint == nil {
+			return newHostError(classifyConnectionError(fingerprintErr), fingerprintErr)
+		}
+		if fingerprintErr != nil {
+			// the negotiate response is still worth recording
+			logger.Debugf("Failed to read the NTLM challenge of %s: %v", host.IP.String(), fingerprintErr)
+		}
+		return nil
+	})
+	if err != nil {
+		return hostResult, newHostError(classifyConnectionError(err), err)
+	}
+
+	hostResult.IP = host.IP.String()
+	hostResult.Source = host.Source
+	hostResult.Time = time.Now()
+	hostResult.Status = HostStatusOK
+	hostResult.Transport = transport.Name
+	hostResult.Version = "unknown"
+	hostResult.Hostname = host.Hostname
+	hostResult.Signing = fingerprint.Signing
+	hostResult.Fingerprint = fingerprint
+	if fingerprint.NTLM != nil {
+		if version := fingerprint.NTLM.GuessedOSVersion(); version != "" {
+			hostResult.Version = version
+			hostResult.OS = options.Lifecycle.Assess(guessedWindowsVersion(version), hostResult.Time)
+		}
+		if fingerprint.NTLM.NetBIOSComputer != "" {
+			hostResult.Hostname = fingerprint.NTLM.NetBIOSComputer
+		}
+		hostResult.Domain = fingerprint.NTLM.DNSDomain
+	}
+	hostResult.identifyVendor(nil)
 	return hostResult, nil
 }
 
-func smbThread(s <-chan bool, options *Options, wg *sync.WaitGroup) {
+// enumerateHostWithCredentials enumerates the host once per account of the
+// credentials file and merges the results, attributing every share to the
+// account it was found with. Accounts stopped by the credential tracker are
+// skipped.
+func enumerateHostWithCredentials(host DNHost, options *Options) (Host, error) {
+	var merged Host
+	var results []CredentialResult
+	err := newHostError(HostStatusSkipped, errors.New("no usable credentials left"))
+
+	for _, credential := range options.Credentials {
+		if !options.CredentialTracker.Acquire(credential) {
+			continue
+		}
+
+		target := host
+		target.Credential = credential
+		credentialOptions := *options
+		if merged.IP != "" {
+			// the host is fingerprinted once
+			credentialOptions.Fingerprint = false
+		}
+		hostResult, enumErr := enumerateHost(target, &credentialOptions)
+		authenticated := hostResult.IP != ""
+		var authErr error
+		if !authenticated {
+			authErr = enumErr
+		}
+		if reason, stopped := options.CredentialTracker.Record(credential, authErr); stopped {
+			logger.Warnf("Stopped using %s: %s", credential, reason)
+		}
+
+		result := CredentialResult{Credential: credential.String(), Success: authenticated}
+		if enumErr != nil {
+			result.Error = enumErr.Error()
+		}
+		results = append(results, result)
+		if !authenticated {
+			err = enumErr
+			if !isNTStatus(enumErr) {
+				// the host is unreachable, other accounts would fail the same way
+				break
+			}
+			continue
+		}
+		results[len(results)-1].Admin = hostResult.Admin
+		results[len(results)-1].Session = hostResult.Session
+		merged.merge(hostResult, credential.String())
+	}
+
+	if merged.IP == "" {
+		return merged, err
+	}
+	merged.Credentials = results
+	return merged, nil
+}
+
+// merge adds the result of enumerating the host as another identity, its
+// shares are attributed to the identity.
+func (h *Host) merge(hostResult Host, identity string) {
+	if h.IP == "" {
+		*h = hostResult
+		h.Shares = nil
+	} else {
+		if hostResult.Admin != nil && *hostResult.Admin && (h.Admin == nil || !*h.Admin) {
+			// the registry of the host is only readable with admin rights
+			h.Version, h.OS, h.Audit = hostResult.Version, hostResult.OS, hostResult.Audit
+		}
+		if hostResult.Admin != nil && (h.Admin == nil || *hostResult.Admin) {
+			h.Admin = hostResult.Admin
+		}
+		if hostResult.Status == HostStatusOK {
+			// another account could list the shares
+			h.Status, h.Reason = HostStatusOK, ""
+		}
+	}
+	for _, share := range hostResult.Shares {
+		share.Credential = identity
+		h.Shares = append(h.Shares, share)
+	}
+}
+
+func smbThread(s <-chan bool, options *Options, targets <-chan DNHost, wg *sync.WaitGroup) {
 	// reduce the number of WaitGroup after returning from function
 	defer wg.Done()
 
@@ -179,27 +422,88 @@ func smbThread(s <-chan bool, options *Options, wg *sync.WaitGroup) {
 			return
 		default:
 			// receive a target from target channel
-			host, ok := <-options.Target
+			host, ok := <-targets
 			if !ok {
 				// stop if the target list is over
 				return
 			}
+			if guardErr := options.LockoutGuard.Err(); guardErr != nil {
+				// keep draining the targets without authenticating
+				writeXMLHost(failedHost(host, HostStatusSkipped, guardErr), options)
+				continue
+			}
+
+			if len(smbTransports(host, options)) == 0 {
+				err := fmt.Errorf("port %d is closed, open ports: %s", options.SmbPort, formatPorts(host.Ports))
+				logger.Error(errors.New(fmt.Sprintf("Error during authentication on %s (%s): %v", host.IP, HostStatusUnreachable, err)))
+				writeXMLHost(failedHost(host, HostStatusUnreachable, err), options)
+				continue
+			}
 
 			// enumerate the host. Will receive the Host struct or an error
-			hostResult, err := enumerateHost(host, options)
+			var hostResult Host
+			var err error
+			if options.Info {
+				hostResult, err = infoHost(host, options)
+			} else if options.Matrix {
+				hostResult, err = enumerateHostMatrix(host, options)
+			} else if options.CompareCredential != nil {
+				hostResult, err = enumerateHostCompared(host, options)
+			} else if len(options.Credentials) > 0 {
+				hostResult, err = enumerateHostWithCredentials(host, options)
+			} else {
+				hostResult, err = enumerateHost(host, options)
+			}
 
-			// failed on authentication
+			// failed before authentication, the host is recorded with the reason
 			if hostResult.IP == "" {
-				logger.Error(errors.New(fmt.Sprintf("Error during authentication on %s: %v", host.IP, err)))
+				status := HostStatus(err)
+				logger.Error(errors.New(fmt.Sprintf("Error during authentication on %s (%s): %v", host.IP, status, err)))
+				writeXMLHost(failedHost(host, status, err), options)
 				continue
 			}
 
 			// format and print results on enumerated host
 			printResult := SPrintHostInfoWithAdmin(hostResult.IP, hostResult.Version, hostResult.Hostname, hostResult.Domain, hostResult.Signing, hostResult.Admin)
+			if hostResult.LAPS != "" {
+				printResult += fmt.Sprintf(" (laps:%s)", hostResult.LAPS)
+			} else if hostResult.LAPSEncrypted {
+				printResult += " (laps:encrypted, not used)"
+			}
+			if hostResult.Transport == TransportNetBIOS {
+				printResult += fmt.Sprintf(" (transport:%s)", hostResult.Transport)
+			}
+			printResult += SprintVendor(hostResult.Vendor)
+			printResult += SprintOperatingSystem(hostResult.OS)
+			printResult += SprintFingerprint(hostResult.Fingerprint)
+			if hostResult.Status != HostStatusOK {
+				printResult += fmt.Sprintf(" (status:%s)", hostResult.Status)
+			}
+			printResult += SprintSession(hostResult)
+			if hostResult.Accounts != nil && len(hostResult.Accounts.Accounts) > 0 {
+				printResult += fmt.Sprintf(" (accounts:%d)", len(hostResult.Accounts.Accounts))
+			}
+			if hostResult.Pipes != nil {
+				printResult += fmt.Sprintf(" (pipes:%d)", len(hostResult.Pipes.Reachable()))
+			}
+			if hostResult.Audit != nil {
+				printResult += fmt.Sprintf(" (findings:%d)", len(hostResult.Audit.Findings()))
+			}
+			printResult += SprintCredentialResults(hostResult.Credentials)
+			printResult += SprintAccounts(hostResult.Accounts)
+			printResult += SprintPipes(hostResult.Pipes)
+			printResult += SprintAudit(hostResult.Audit)
 			if len(hostResult.Shares) > 0 {
-				printResult += SprintHost(hostResult, options.Exclude)
+				if options.Matrix {
+					printResult += SprintAccessMatrix(hostResult, options.Exclude)
+				} else if hostResult.Comparison != nil {
+					printResult += SprintComparison(hostResult.Comparison)
+				} else {
+					printResult += SprintHost(hostResult, options.Exclude)
+				}
 
-				if options.List {
+				// the differences of compared accounts include the listed files
+				if options.List && hostResult.Comparison == nil {
 					printResult += SprintShares(hostResult, options.Exclude)
 				}
 			}
@@ -209,19 +513,12 @@ func smbThread(s <-chan bool, options *Options, wg *sync.WaitGroup) {
 			if options.FileTXT != nil {
 				// try to write raw version
 				logger.Debugf("Writing the results in raw format to %s", options.OutputRawFileName)
-				err = options.Writer.Write(printResult, options.FileTXT)
-				if err != nil {
-					logger.Error(err)
-				}
-			}
-			if options.FileXML != nil {
-				// try to write XML version
-				logger.Debugf("Writing the results in XML format to %s", options.OutputXMLFileName)
-				err = options.Writer.WriteXMLHost(hostResult, options.FileXML)
-				if err != nil {
-					logger.Error(err)
+				writeErr := options.Writer.Write(printResult, options.FileTXT)
+				if writeErr != nil {
+					logger.Error(writeErr)
 				}
 			}
+			writeXMLHost(hostResult, options)
 
 			// got an error during shares enumeration
 			if err != nil {
@@ -231,3 +528,27 @@ func smbThread(s <-chan bool, options *Options, wg *sync.WaitGroup) {
 		}
 	}
 }
+
+// failedHost returns the record of a host that could not be enumerated.
+func failedHost(host DNHost, status string, err error) Host {
+	return Host{
+		Time:     time.Now(),
+		IP:       host.IP.String(),
+		Hostname: host.Hostname,
+		Source:   host.Source,
+		Status:   status,
+		Reason:   err.Error(),
+	}
+}
+
+// writeXMLHost writes a host record to the XML output if it is enabled.
+func writeXMLHost(hostResult Host, options *Options) {
+	if options.FileXML == nil {
+		return
+	}
+	logger.Debugf("Writing the results in XML format to %s", options.OutputXMLFileName)
+	err := options.Writer.WriteXMLHost(hostResult, options.FileXML)
+	if err != nil {
+		logger.Error(err)
+	}
+}
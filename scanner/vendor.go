@@ -0,0 +1,182 @@
+package scanner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SMB server vendors
+const (
+	VendorMicrosoft = "Microsoft"
+	VendorSamba     = "Samba"
+	VendorNetApp    = "NetApp"
+	VendorSynology  = "Synology"
+	VendorQNAP      = "QNAP"
+	VendorIsilon    = "Dell EMC Isilon"
+)
+
+// vendorProducts are the products reported for appliances without a more
+// specific version string
+var vendorProducts = map[string]string{
+	VendorNetApp:   "NetApp ONTAP",
+	VendorSynology: "Synology DSM",
+	VendorQNAP:     "QNAP QTS",
+	VendorIsilon:   "Isilon OneFS",
+}
+
+// vendorPriority orders the vendors from the most to the least specific,
+// appliances built on Samba show Samba traits as well
+var vendorPriority = []string{VendorNetApp, VendorIsilon, VendorSynology, VendorQNAP, VendorSamba}
+
+// vendorShareNames are share names only created by a vendor
+var vendorShareNames = map[string]string{
+	"ontap_admin$": VendorNetApp,
+	"ifs":          VendorIsilon,
+	"surveillance": VendorSynology,
+	"netbackup":    VendorSynology,
+	"web_packages": VendorSynology,
+	"qsync":        VendorQNAP,
+	"qweb":         VendorQNAP,
+	"qmultimedia":  VendorQNAP,
+	"qdownload":    VendorQNAP,
+	"qrecordings":  VendorQNAP,
+	"qusb":         VendorQNAP,
+}
+
+// vendorPaths match share paths of a vendor, Samba returns Unix paths below C:\
+var vendorPaths = []struct {
+	vendor  string
+	pattern *regexp.Regexp
+}{
+	{VendorSynology, regexp.MustCompile(`(?i)^(c:)?[\\/]volume(usb)?\d+([\\/]|$)`)},
+	{VendorQNAP, regexp.MustCompile(`(?i)^(c:)?[\\/]share[\\/](cachedev\d+|md\d+)_data([\\/]|$)`)},
+	{VendorIsilon, regexp.MustCompile(`(?i)^(c:)?[\\/]ifs([\\/]|$)`)},
+	{VendorNetApp, regexp.MustCompile(`(?i)^[\\/]vol[\\/]`)},
+}
+
+// sambaNTLMVersion is the version Samba sends in every NTLM challenge
+const sambaNTLMVersion = "6.1.0"
+
+// Vendor is the vendor of the SMB server of a host and the traits it was
+// identified by
+type Vendor struct {
+	Name     string   `xml:"name,attr"`
+	Product  string   `xml:"product,attr,omitempty"`
+	Evidence []string `xml:"evidence"`
+}
+
+// IsWindows reports whether the SMB server is Windows.
+func (v *Vendor) IsWindows() bool {
+	return v.Name == VendorMicrosoft
+}
+
+// IdentifyVendor returns the vendor of an SMB server from its negotiate and
+// NTLM fingerprint and its shares, nil if there are no traits of any vendor.
+func IdentifyVendor(fingerprint *Fingerprint, shares []Share) *Vendor {
+	evidence := make(map[string][]string)
+	var sambaVersion, netAppVersion string
+
+	if fingerprint != nil {
+		for _, native := range []string{fingerprint.NativeLanMan, fingerprint.NativeOS} {
+			lower := strings.ToLower(native)
+			switch {
+			case strings.Contains(lower, "samba"):
+				evidence[VendorSamba] = append(evidence[VendorSamba], "native:"+native)
+				if sambaVersion == "" {
+					sambaVersion = native[strings.Index(lower, "samba"):]
+				}
+			case strings.Contains(lower, "netapp"):
+				evidence[VendorNetApp] = append(evidence[VendorNetApp], "native:"+native)
+				netAppVersion = native
+			case strings.Contains(lower, "isilon") || strings.Contains(lower, "onefs"):
+				evidence[VendorIsilon] = append(evidence[VendorIsilon], "native:"+native)
+			}
+		}
+		if fingerprint.NTLM != nil && fingerprint.NTLM.OSVersion == sambaNTLMVersion {
+			evidence[VendorSamba] = append(evidence[VendorSamba], "ntlm:"+fingerprint.NTLM.OSVersion)
+		}
+	}
+
+	for _, share := range shares {
+		if vendor, found := vendorShareNames[strings.ToLower(share.ShareName)]; found {
+			evidence[vendor] = append(evidence[vendor], "share:"+share.ShareName)
+		}
+		// the server string of Samba is the remark of IPC$, e.g. IPC Service (Samba 4.17.12-Debian)
+		if strings.Contains(strings.ToLower(share.Description), "samba") {
+			evidence[VendorSamba] = append(evidence[VendorSamba], "remark:"+share.Description)
+		}
+		if share.Details == nil {
+			continue
+		}
+		for _, path := range vendorPaths {
+			if path.pattern.MatchString(share.Details.Path) {
+				evidence[path.vendor] = append(evidence[path.vendor], "path:"+share.Details.Path)
+			}
+		}
+	}
+
+	windows := windowsNTLMBuild(fingerprint)
+	for _, vendor := range vendorPriority {
+		if len(evidence[vendor]) == 0 {
+			continue
+		}
+		// Windows servers may share folders of the same name, so a Windows
+		// build in the NTLM challenge outweighs share names alone
+		if windows && shareNamesOnly(evidence[vendor]) {
+			continue
+		}
+		result := &Vendor{Name: vendor, Product: vendorProducts[vendor]}
+		for _, name := range vendorPriority {
+			result.Evidence = append(result.Evidence, evidence[name]...)
+		}
+		switch {
+		case vendor == VendorNetApp && netAppVersion != "":
+			result.Product = netAppVersion
+		case vendor == VendorSamba:
+			result.Product = "Samba"
+			if sambaVersion != "" {
+				result.Product = sambaVersion
+			}
+		case sambaVersion != "":
+			result.Product = fmt.Sprintf("%s (%s)", result.Product, sambaVersion)
+		}
+		return result
+	}
+
+	if windows {
+		return &Vendor{Name: VendorMicrosoft, Evidence: []string{"ntlm:" + fingerprint.NTLM.OSVersion}}
+	}
+	return nil
+}
+
+// windowsNTLMBuild reports whether the NTLM challenge carries a Windows build
+// number, which Samba doesn't send.
+func windowsNTLMBuild(fingerprint *Fingerprint) bool {
+	if fingerprint == nil || fingerprint.NTLM == nil || fingerprint.NTLM.OSVersion == sambaNTLMVersion {
+		return false
+	}
+	build := guessedWindowsVersion(fingerprint.NTLM.GuessedOSVersion()).Build
+	return build != "" && build != "0"
+}
+
+// shareNamesOnly reports whether all evidence of a vendor are share names
+func shareNamesOnly(evidence []string) bool {
+	for _, item := range evidence {
+		if !strings.HasPrefix(item, "share:") {
+			return false
+		}
+	}
+	return true
+}
+
+// identifyVendor records the vendor of the SMB server of the host. The
+// Windows version and lifecycle of appliances are replaced by their product.
+func (h *Host) identifyVendor(shares []Share) {
+	h.Vendor = IdentifyVendor(h.Fingerprint, shares)
+	if h.Vendor == nil || h.Vendor.IsWindows() {
+		return
+	}
+	h.Version = h.Vendor.Product
+	h.OS = nil
+}
@@ -0,0 +1,113 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdentifyVendor(t *testing.T) {
+	tests := []struct {
+		name        string
+		fingerprint *Fingerprint
+		shares      []Share
+		vendor      string
+		product     string
+	}{
+		{
+			name:        "samba native strings",
+			fingerprint: &Fingerprint{NativeOS: "Windows 6.1", NativeLanMan: "Samba 4.17.12-Debian", NTLM: &NTLMInfo{OSVersion: "6.1.0"}},
+			vendor:      VendorSamba,
+			product:     "Samba 4.17.12-Debian",
+		},
+		{
+			name:        "samba ntlm version",
+			fingerprint: &Fingerprint{NTLM: &NTLMInfo{OSVersion: "6.1.0"}},
+			vendor:      VendorSamba,
+			product:     "Samba",
+		},
+		{
+			name:        "synology paths",
+			fingerprint: &Fingerprint{NativeLanMan: "Samba 4.15.13"},
+			shares:      []Share{{ShareName: "IPC$", Description: "IPC Service (nas)"}, {ShareName: "Data", Details: &ShareDetails{Path: `C:\volume1\Data`}}},
+			vendor:      VendorSynology,
+			product:     "Synology DSM (Samba 4.15.13)",
+		},
+		{
+			name:    "qnap share names",
+			shares:  []Share{{ShareName: "Qsync"}, {ShareName: "IPC$", Description: "IPC Service (NAS Server)"}},
+			vendor:  VendorQNAP,
+			product: "QNAP QTS",
+		},
+		{
+			name:        "netapp native strings",
+			fingerprint: &Fingerprint{NativeOS: "NetApp Release 8.2.4P6 7-Mode", NativeLanMan: "NetApp Release 8.2.4P6 7-Mode"},
+			vendor:      VendorNetApp,
+			product:     "NetApp Release 8.2.4P6 7-Mode",
+		},
+		{
+			name:    "isilon path",
+			shares:  []Share{{ShareName: "ifs", Details: &ShareDetails{Path: "/ifs"}}},
+			vendor:  VendorIsilon,
+			product: "Isilon OneFS",
+		},
+		{
+			name:        "windows",
+			fingerprint: &Fingerprint{NTLM: &NTLMInfo{OSVersion: "10.0.20348"}},
+			shares:      []Share{{ShareName: "Data", Details: &ShareDetails{Path: `D:\Shares\Data`}}},
+			vendor:      VendorMicrosoft,
+		},
+		{
+			name:        "windows with appliance share names",
+			fingerprint: &Fingerprint{NTLM: &NTLMInfo{OSVersion: "10.0.20348"}},
+			shares:      []Share{{ShareName: "NetBackup", Details: &ShareDetails{Path: `E:\NetBackup`}}, {ShareName: "ifs"}},
+			vendor:      VendorMicrosoft,
+		},
+		{
+			name:        "synology path despite windows build",
+			fingerprint: &Fingerprint{NTLM: &NTLMInfo{OSVersion: "10.0.20348"}},
+			shares:      []Share{{ShareName: "NetBackup", Details: &ShareDetails{Path: `C:\volume1\NetBackup`}}},
+			vendor:      VendorSynology,
+			product:     "Synology DSM",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vendor := IdentifyVendor(tt.fingerprint, tt.shares)
+			if vendor == nil {
+				t.Fatal("expected a vendor")
+			}
+			if vendor.Name != tt.vendor || vendor.Product != tt.product || len(vendor.Evidence) == 0 {
+				t.Errorf("unexpected vendor %+v", vendor)
+			}
+		})
+	}
+
+	if IdentifyVendor(nil, []Share{{ShareName: "Data"}}) != nil {
+		t.Error("expected no vendor without traits")
+	}
+}
+
+func TestHostIdentifyVendor(t *testing.T) {
+	host := Host{Version: "Windows NT 6.1 Build 0", OS: &OperatingSystem{}, Fingerprint: &Fingerprint{NativeLanMan: "Samba 4.19.5-Ubuntu"}}
+	host.identifyVendor(nil)
+	if host.Version != "Samba 4.19.5-Ubuntu" || host.OS != nil {
+		t.Errorf("unexpected host %+v", host)
+	}
+	if got := SprintVendor(host.Vendor); got != " (vendor:Samba)" {
+		t.Errorf("unexpected output %q", got)
+	}
+
+	windows := Host{Version: "Windows NT 10.0 Build 20348", Fingerprint: &Fingerprint{NTLM: &NTLMInfo{OSVersion: "10.0.20348"}}}
+	windows.identifyVendor(nil)
+	if windows.Version != "Windows NT 10.0 Build 20348" || SprintVendor(windows.Vendor) != "" {
+		t.Errorf("unexpected host %+v", windows)
+	}
+
+	run := SharefinderRun{Hosts: []Host{host, windows, {IP: "10.0.0.3"}}}
+	if run.ApplianceHostCount() != 1 {
+		t.Errorf("expected 1 appliance, got %d", run.ApplianceHostCount())
+	}
+	if !strings.HasPrefix(host.Vendor.Evidence[0], "native:") {
+		t.Errorf("unexpected evidence %v", host.Vendor.Evidence)
+	}
+}
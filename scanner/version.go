@@ -5,6 +5,33 @@ import (
 	"strings"
 )
 
+// WindowsVersion is the Windows version of a host read from the registry, or
+// guessed from the NTLM target info
+type WindowsVersion struct {
+	ProductName    string
+	DisplayVersion string
+	ReleaseID      string
+	CurrentVersion string
+	Build          string
+	UBR            uint32
+}
+
+// String returns the version for the output, fallback if the registry lacks
+// the product name.
+func (v WindowsVersion) String(fallback string) string {
+	return buildWindowsVersionString(v.ProductName, v.DisplayVersion, v.ReleaseID, v.CurrentVersion, v.Build, v.UBR, fallback)
+}
+
+// guessedWindowsVersion parses a version guessed from the NTLM target info,
+// e.g. Windows NT 10.0 Build 20348.
+func guessedWindowsVersion(guessed string) WindowsVersion {
+	var major, minor, build int
+	if _, err := fmt.Sscanf(guessed, "Windows NT %d.%d Build %d", &major, &minor, &build); err != nil {
+		return WindowsVersion{}
+	}
+	return WindowsVersion{CurrentVersion: fmt.Sprintf("%d.%d", major, minor), Build: fmt.Sprintf("%d", build)}
+}
+
 func buildWindowsVersionString(productName, displayVersion, releaseID, currentVersion, build string, ubr uint32, fallback string) string {
 	productName = strings.TrimSpace(productName)
 	displayVersion = strings.TrimSpace(displayVersion)
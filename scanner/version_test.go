@@ -52,3 +52,13 @@ func TestBuildWindowsVersionString(t *testing.T) {
 		})
 	}
 }
+
+func TestGuessedWindowsVersion(t *testing.T) {
+	version := guessedWindowsVersion("Windows NT 10.0 Build 20348")
+	if version.CurrentVersion != "10.0" || version.Build != "20348" {
+		t.Errorf("unexpected version %+v", version)
+	}
+	if version := guessedWindowsVersion("unknown"); version.Build != "" {
+		t.Errorf("expected no build, got %+v", version)
+	}
+}
@@ -15,24 +15,103 @@ type Timestamp struct {
 
 // SharefinderRun contains all data for a single scan
 type SharefinderRun struct {
-	Version            string    `xml:"version,attr"`
-	Command            string    `xml:"command,attr"`
-	TimeStart          time.Time `xml:"time_start,attr"`
-	FormattedTimeStart string    `xml:"formatted_time_start,attr"`
-	Hosts              []Host    `xml:"hosts>host"`
-	TimeEnd            Timestamp `xml:"time_end"`
+	Version            string     `xml:"version,attr"`
+	Command            string     `xml:"command,attr"`
+	TimeStart          time.Time  `xml:"time_start,attr"`
+	FormattedTimeStart string     `xml:"formatted_time_start,attr"`
+	Hosts              []Host     `xml:"hosts>host"`
+	Summary            RunSummary `xml:"summary"`
+	TimeEnd            Timestamp  `xml:"time_end"`
+}
+
+// RunSummary contains run-level results, written after all hosts
+type RunSummary struct {
+	XMLName xml.Name       `xml:"summary"`
+	Trusts  []Trust        `xml:"trusts>trust,omitempty"`
+	Domains []DomainResult `xml:"domains>domain,omitempty"`
+	Sweep   *SweepResult   `xml:"sweep,omitempty"`
+}
+
+// Trust is a domain trust read from a trustedDomain object of Domain
+type Trust struct {
+	Domain     string `xml:"domain,attr"`
+	Partner    string `xml:"partner,attr"`
+	FlatName   string `xml:"flat_name,attr"`
+	Direction  string `xml:"direction,attr"`
+	Type       string `xml:"type,attr"`
+	Attributes string `xml:"attributes,attr"`
+	SID        string `xml:"sid,attr,omitempty"`
+}
+
+// AllowsAccess reports whether users of Domain can authenticate to Partner,
+// which requires the partner to trust Domain (inbound or bidirectional).
+func (t Trust) AllowsAccess() bool {
+	return t.Direction == "inbound" || t.Direction == "bidirectional"
+}
+
+// DomainResult records the outcome of computer discovery in a single domain
+type DomainResult struct {
+	Name      string `xml:"name,attr"`
+	Source    string `xml:"source,attr"`
+	Success   bool   `xml:"success,attr"`
+	Computers int    `xml:"computers,attr"`
+	Error     string `xml:"error,attr,omitempty"`
 }
 
 type Host struct {
-	XMLName  xml.Name  `xml:"host"`
-	Time     time.Time `xml:"time,attr"`
-	IP       string    `xml:"ip,attr"`
-	Version  string    `xml:"version,attr"`
-	Hostname string    `xml:"hostname,attr"`
-	Domain   string    `xml:"domain,attr"`
-	Signing  bool      `xml:"signing,attr"`
-	Admin    *bool     `xml:"admin,attr,omitempty"`
-	Shares   []Share   `xml:"share"`
+	XMLName          xml.Name            `xml:"host"`
+	Time             time.Time           `xml:"time,attr"`
+	IP               string              `xml:"ip,attr"`
+	Version          string              `xml:"version,attr"`
+	Hostname         string              `xml:"hostname,attr"`
+	Domain           string              `xml:"domain,attr"`
+	Signing          bool                `xml:"signing,attr"`
+	Admin            *bool               `xml:"admin,attr,omitempty"`
+	Source           string              `xml:"source,attr,omitempty"`
+	LAPS             string              `xml:"laps,attr,omitempty"`           // source of the LAPS password the host was authenticated with
+	LAPSEncrypted    bool                `xml:"laps_encrypted,attr,omitempty"` // only an encrypted LAPS password was readable
+	Status           string              `xml:"status,attr,omitempty"`
+	Reason           string              `xml:"reason,attr,omitempty"`
+	Transport        string              `xml:"transport,attr,omitempty"`
+	Session          string              `xml:"session,attr,omitempty"`
+	RequestedSession string              `xml:"requested_session,attr,omitempty"` // set if the server mapped the logon to Session
+	OS               *OperatingSystem    `xml:"os,omitempty"`
+	Vendor           *Vendor             `xml:"vendor,omitempty"`
+	Fingerprint      *Fingerprint        `xml:"fingerprint,omitempty"`
+	Accounts         *AccountEnumeration `xml:"accounts,omitempty"`
+	Pipes            *PipeEnumeration    `xml:"pipes,omitempty"`
+	Audit            *AdminAudit         `xml:"audit,omitempty"`
+	Credentials      []CredentialResult  `xml:"credential"`
+	Comparison       *Comparison         `xml:"comparison,omitempty"`
+	Shares           []Share             `xml:"share"`
+}
+
+// CredentialResult is the outcome of authenticating to a host with one account
+// from the credentials file.
+type CredentialResult struct {
+	Credential string `xml:"name,attr"`
+	Success    bool   `xml:"success,attr"`
+	Admin      *bool  `xml:"admin,attr,omitempty"`
+	Session    string `xml:"session,attr,omitempty"`
+	Error      string `xml:"error,attr,omitempty"`
+}
+
+// SessionMapped reports whether the server mapped the logon to another session
+// type than requested, e.g. an unknown user to Guest.
+func (h Host) SessionMapped() bool {
+	return h.RequestedSession != ""
+}
+
+// GuestSessionHostCount returns hosts that granted a guest session, insecure
+// guest logons current Windows clients refuse by default.
+func (r *SharefinderRun) GuestSessionHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.Session == SessionGuest {
+			n++
+		}
+	}
+	return n
 }
 
 func (h Host) AdminStatus() string {
@@ -42,9 +121,67 @@ func (h Host) AdminStatus() string {
 	return strconv.FormatBool(*h.Admin)
 }
 
+// Authenticated reports whether a session was set up with the host. Records
+// written before host statuses were introduced are successful.
+func (h Host) Authenticated() bool {
+	switch h.Status {
+	case "", HostStatusOK, HostStatusSrvsvcDenied, HostStatusListingError:
+		return true
+	}
+	return false
+}
+
+// HostStatusCount is the number of hosts with a status.
+type HostStatusCount struct {
+	Status string
+	Count  int
+}
+
 // HostCount returns the number of enumerated hosts.
 func (r *SharefinderRun) HostCount() int {
-	return len(r.Hosts)
+	return len(r.AuthenticatedHosts())
+}
+
+// AuthenticatedHosts returns the hosts a session was set up with.
+func (r *SharefinderRun) AuthenticatedHosts() []Host {
+	var hosts []Host
+	for _, h := range r.Hosts {
+		if h.Authenticated() {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// FailedHosts returns the hosts that could not be enumerated.
+func (r *SharefinderRun) FailedHosts() []Host {
+	var hosts []Host
+	for _, h := range r.Hosts {
+		if !h.Authenticated() {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// HostStatusCounts returns the number of hosts per status in report order,
+// statuses without hosts are omitted.
+func (r *SharefinderRun) HostStatusCounts() []HostStatusCount {
+	counts := make(map[string]int)
+	for _, h := range r.Hosts {
+		status := h.Status
+		if status == "" {
+			status = HostStatusOK
+		}
+		counts[status]++
+	}
+	var result []HostStatusCount
+	for _, status := range HostStatuses {
+		if counts[status] > 0 {
+			result = append(result, HostStatusCount{Status: status, Count: counts[status]})
+		}
+	}
+	return result
 }
 
 // ShareCount returns the total number of shares discovered across all hosts.
@@ -82,6 +219,20 @@ func (r *SharefinderRun) WritableShareCount() int {
 	return n
 }
 
+// ExposedShareCount returns shares that expose a volume root or a system
+// directory.
+func (r *SharefinderRun) ExposedShareCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		for _, s := range h.Shares {
+			if s.Details != nil && s.Details.Exposure != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
 // AdminHostCount returns hosts where the user has local admin access.
 func (r *SharefinderRun) AdminHostCount() int {
 	n := 0
@@ -93,13 +244,172 @@ func (r *SharefinderRun) AdminHostCount() int {
 	return n
 }
 
+// LAPSHostCount returns hosts that were authenticated with their LAPS password.
+func (r *SharefinderRun) LAPSHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.LAPS != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// LAPSEncryptedHostCount returns hosts with only an encrypted LAPS password,
+// they were scanned with the global account.
+func (r *SharefinderRun) LAPSEncryptedHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.LAPSEncrypted {
+			n++
+		}
+	}
+	return n
+}
+
+// FingerprintedHosts returns the hosts with an SMB fingerprint.
+func (r *SharefinderRun) FingerprintedHosts() []Host {
+	var hosts []Host
+	for _, h := range r.Hosts {
+		if h.Fingerprint != nil {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// SMBv1HostCount returns hosts that still accept SMBv1.
+func (r *SharefinderRun) SMBv1HostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.Fingerprint != nil && h.Fingerprint.SMBv1 {
+			n++
+		}
+	}
+	return n
+}
+
+// UnsupportedOSHostCount returns hosts running an operating system past its
+// end of support.
+func (r *SharefinderRun) UnsupportedOSHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.OS != nil && h.OS.EOL {
+			n++
+		}
+	}
+	return n
+}
+
+// ApplianceHostCount returns hosts whose SMB server isn't Windows.
+func (r *SharefinderRun) ApplianceHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.Vendor != nil && !h.Vendor.IsWindows() {
+			n++
+		}
+	}
+	return n
+}
+
+// UnencryptedHostCount returns fingerprinted hosts that don't support SMB encryption.
+func (r *SharefinderRun) UnencryptedHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.Fingerprint != nil && !h.Fingerprint.EncryptionSupported {
+			n++
+		}
+	}
+	return n
+}
+
+// AccountHosts returns the hosts with enumerated accounts or account
+// enumeration errors.
+func (r *SharefinderRun) AccountHosts() []Host {
+	var hosts []Host
+	for _, h := range r.Hosts {
+		if h.Accounts != nil {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// AccountCount returns the accounts enumerated over all hosts.
+func (r *SharefinderRun) AccountCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.Accounts != nil {
+			n += len(h.Accounts.Accounts)
+		}
+	}
+	return n
+}
+
+// PipeHosts returns the hosts with probed named pipes.
+func (r *SharefinderRun) PipeHosts() []Host {
+	var hosts []Host
+	for _, h := range r.Hosts {
+		if h.Pipes != nil && len(h.Pipes.Pipes) > 0 {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// CoercionHostCount returns hosts where the session could open a pipe of an
+// interface that allows authentication coercion.
+func (r *SharefinderRun) CoercionHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.Pipes != nil && h.Pipes.CoercionReachable() {
+			n++
+		}
+	}
+	return n
+}
+
+// AuditHosts returns the hosts audited with local admin rights.
+func (r *SharefinderRun) AuditHosts() []Host {
+	var hosts []Host
+	for _, h := range r.Hosts {
+		if h.Audit != nil {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// AuditFindingHostCount returns the audited hosts with at least one weak
+// service or registry setting.
+func (r *SharefinderRun) AuditFindingHostCount() int {
+	n := 0
+	for _, h := range r.Hosts {
+		if h.Audit != nil && len(h.Audit.Findings()) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (r CredentialResult) AdminStatus() string {
+	if r.Admin == nil {
+		return "unknown"
+	}
+	return strconv.FormatBool(*r.Admin)
+}
+
 type Share struct {
-	ShareName       string      `xml:"share_name,attr"`
-	Description     string      `xml:"description,attr"`
-	ReadPermission  bool        `xml:"read_permission,attr"`
-	WritePermission bool        `xml:"write_permission,attr"`
-	Directories     []Directory `xml:"directory"`
-	Files           []File      `xml:"file"`
+	ShareName       string        `xml:"share_name,attr"`
+	Description     string        `xml:"description,attr"`
+	Type            string        `xml:"type,attr,omitempty"`
+	ReadPermission  bool          `xml:"read_permission,attr"`
+	WritePermission bool          `xml:"write_permission,attr"`
+	Credential      string        `xml:"credential,attr,omitempty"`
+	Discovery       string        `xml:"discovery,attr,omitempty"`
+	Details         *ShareDetails `xml:"details,omitempty"`
+	Directories     []Directory   `xml:"directory"`
+	Files           []File        `xml:"file"`
 }
 
 type Directory struct {